@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/pkg/errors"
@@ -42,6 +43,7 @@ type FileDescriptor struct {
 	Descriptor  *descriptor.FileDescriptorProto
 	MessageType []*Descriptor
 	EnumType    []*EnumDescriptor
+	Locations   map[string]*descriptor.SourceCodeInfo_Location
 }
 
 type BaseGenerator struct {
@@ -75,6 +77,11 @@ func (g *BaseGenerator) Fail(messages ...string) {
 	os.Exit(1)
 }
 
+// 通过响应返回错误, 由 protoc 输出并以失败退出
+func (g *BaseGenerator) ResponseError(messages ...string) {
+	g.Response.Error = proto.String(strings.Join(messages, "\n"))
+}
+
 func (g *BaseGenerator) WrapTypes() {
 	g.Files = make([]*FileDescriptor, 0, len(g.Request.ProtoFile))
 	for _, f := range g.Request.ProtoFile {
@@ -86,6 +93,32 @@ func (g *BaseGenerator) WrapTypes() {
 	}
 }
 
+// 需要生成代码的文件, 不包括仅被导入的文件
+func (g *BaseGenerator) FilesToGenerate() []*FileDescriptor {
+	var files []*FileDescriptor
+	for _, name := range g.Request.FileToGenerate {
+		for _, f := range g.Files {
+			if f.Descriptor.GetName() == name {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// 按全名查找消息, 包括导入文件中的消息
+func (g *BaseGenerator) FindMessage(fullName string) (*FileDescriptor, *Descriptor) {
+	fullName = strings.TrimPrefix(fullName, ".")
+	for _, f := range g.Files {
+		for _, message := range f.MessageType {
+			if f.Descriptor.GetPackage()+"."+strings.Join(message.Name, ".") == fullName {
+				return f, message
+			}
+		}
+	}
+	return nil, nil
+}
+
 func (g *BaseGenerator) CommandLineParameters(parameter string) {
 	g.Parameters = make(map[string]string)
 	for _, p := range strings.Split(parameter, ",") {
@@ -111,6 +144,7 @@ func wrapFileDescriptor(f *descriptor.FileDescriptorProto) (*FileDescriptor, err
 		Descriptor:  f,
 		MessageType: descriptors,
 		EnumType:    enums,
+		Locations:   comments,
 	}
 	return fd, nil
 }
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// waka/options.proto 中定义的消息选项字段号
+const (
+	rpcOption       = 51000
+	directionOption = 51001
+	targetOption    = 51002
+)
+
+// 消息方向
+type Direction int32
+
+const (
+	DirectionNone    Direction = 0
+	DirectionPost    Direction = 1
+	DirectionReceive Direction = 2
+)
+
+// RPC 声明
+type RPCOption struct {
+	Response string
+	Target   string
+}
+
+// 消息上的 waka 选项
+type MessageOptions struct {
+	RPC       *RPCOption
+	Direction Direction
+	Target    string
+}
+
+// 是否声明了任何 waka 选项
+func (o *MessageOptions) Declared() bool {
+	return o.RPC != nil || o.Direction != DirectionNone || o.Target != ""
+}
+
+// 读取消息上的 waka 选项
+// 插件不依赖 options.proto 的生成代码, 直接从选项的编码中解析扩展字段
+func (d *Descriptor) WakaOptions() (*MessageOptions, error) {
+	r := new(MessageOptions)
+	options := d.Descriptor.GetOptions()
+	if options == nil {
+		return r, nil
+	}
+
+	data, err := proto.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkFields(data, func(field int32, wire int, value []byte, varint uint64) error {
+		switch {
+		case field == rpcOption && wire == proto.WireBytes:
+			r.RPC = new(RPCOption)
+			return walkFields(value, func(field int32, wire int, value []byte, varint uint64) error {
+				switch {
+				case field == 1 && wire == proto.WireBytes:
+					r.RPC.Response = string(value)
+				case field == 2 && wire == proto.WireBytes:
+					r.RPC.Target = string(value)
+				}
+				return nil
+			})
+		case field == directionOption && wire == proto.WireVarint:
+			r.Direction = Direction(varint)
+		case field == targetOption && wire == proto.WireBytes:
+			r.Target = string(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// 遍历编码中的字段, bytes 类型的值通过 value 传递, 其余类型的值通过 varint 传递
+func walkFields(data []byte, fn func(field int32, wire int, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("bad field key")
+		}
+		data = data[n:]
+		field, wire := int32(key>>3), int(key&7)
+
+		var value []byte
+		var varint uint64
+		switch wire {
+		case proto.WireVarint:
+			varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("bad varint for field %d", field)
+			}
+		case proto.WireFixed64:
+			if len(data) < 8 {
+				return fmt.Errorf("bad fixed64 for field %d", field)
+			}
+			varint, n = binary.LittleEndian.Uint64(data), 8
+		case proto.WireFixed32:
+			if len(data) < 4 {
+				return fmt.Errorf("bad fixed32 for field %d", field)
+			}
+			varint, n = uint64(binary.LittleEndian.Uint32(data)), 4
+		case proto.WireBytes:
+			length, m := binary.Uvarint(data)
+			if m <= 0 || uint64(len(data)-m) < length {
+				return fmt.Errorf("bad length for field %d", field)
+			}
+			value, n = data[m:m+int(length)], m+int(length)
+		default:
+			return fmt.Errorf("unsupported wire type %d for field %d", wire, field)
+		}
+		data = data[n:]
+
+		if err := fn(field, wire, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -33,8 +33,9 @@ type Generator struct {
 }
 
 func (g *Generator) GenerateAllFiles() {
-	for _, v := range g.Files {
-		name, content := g.printFile(v)
+	files := g.FilesToGenerate()
+	for _, v := range files {
+		name, content := g.printFile(files, v)
 		g.Response.File = append(g.Response.File, &plugin_go.CodeGeneratorResponse_File{
 			Name:    proto.String(name),
 			Content: proto.String(content),
@@ -42,7 +43,7 @@ func (g *Generator) GenerateAllFiles() {
 	}
 }
 
-func (g *Generator) printFile(f *plugin.FileDescriptor) (string, string) {
+func (g *Generator) printFile(files []*plugin.FileDescriptor, f *plugin.FileDescriptor) (string, string) {
 	tpl, err := template.New("protoc-gen-cellnet").Parse(codeTemplate)
 	if err != nil {
 		g.Error(err, "template parse failed")
@@ -51,7 +52,7 @@ func (g *Generator) printFile(f *plugin.FileDescriptor) (string, string) {
 	model := &FileDescriptor{
 		FileName:              f.Descriptor.GetName(),
 		Namespace:             named.BuildNamespace(f),
-		MetaProviderClassName: named.BuildMetaProviderClassName(f),
+		MetaProviderClassName: named.BuildUniqueMetaProviderClassName(files, f),
 	}
 
 	for _, message := range f.MessageType {
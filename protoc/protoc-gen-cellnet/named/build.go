@@ -2,13 +2,14 @@ package named
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/liuhan907/waka/protoc/plugin"
 )
 
 func BuildMetaProviderClassFileName(f *plugin.FileDescriptor) string {
-	files := strings.Split(strings.TrimSuffix(f.Descriptor.GetName(), ".proto"), "_")
+	files := strings.Split(strings.TrimSuffix(path.Base(f.Descriptor.GetName()), ".proto"), "_")
 	for i := range files {
 		files[i] = strings.Title(files[i])
 	}
@@ -27,15 +28,24 @@ func BuildCellnetFullName(f *plugin.FileDescriptor, message *plugin.Descriptor)
 	return fmt.Sprintf("%s.%s", f.Descriptor.GetPackage(), strings.Join(message.Name, "."))
 }
 
-func BuildFullName(f *plugin.FileDescriptor, message *plugin.Descriptor) string {
-	packages := strings.Split(f.Descriptor.GetPackage(), "_")
-	for i := range packages {
-		packages[i] = strings.Title(packages[i])
+// 同一包有多个文件一起生成时, 包名会重复, 改用文件名区分
+func BuildUniqueMetaProviderClassName(files []*plugin.FileDescriptor, f *plugin.FileDescriptor) string {
+	for _, other := range files {
+		if other != f && other.Descriptor.GetPackage() == f.Descriptor.GetPackage() {
+			return BuildMetaProviderClassFileName(f)
+		}
 	}
-	return fmt.Sprintf("%s.%s", strings.Join(packages, ""), strings.Join(message.Name, ".Types."))
+	return BuildMetaProviderClassName(f)
+}
+
+func BuildFullName(f *plugin.FileDescriptor, message *plugin.Descriptor) string {
+	return fmt.Sprintf("%s.%s", BuildNamespace(f), strings.Join(message.Name, ".Types."))
 }
 
 func BuildNamespace(f *plugin.FileDescriptor) string {
+	if namespace := f.Descriptor.GetOptions().GetCsharpNamespace(); namespace != "" {
+		return namespace
+	}
 	packages := strings.Split(f.Descriptor.GetPackage(), "_")
 	for i := range packages {
 		packages[i] = strings.Title(packages[i])
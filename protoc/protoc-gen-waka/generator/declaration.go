@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/liuhan907/waka/protoc/plugin"
+)
+
+// 消息的声明, @rpc, @post 或 @receive
+type declaration struct {
+	kind     string
+	params   map[string]string
+	comments []string
+}
+
+// 解析消息的声明, 优先使用 waka/options.proto 中的选项, 没有选项时解析前导注释
+func declare(message *plugin.Descriptor) *declaration {
+	d := new(declaration)
+
+	trimmed := strings.Trim(message.Location.GetLeadingComments(), "\r\n\t ")
+	if trimmed != "" {
+		for _, line := range strings.Split(trimmed, "\n") {
+			trimmed := strings.Trim(line, "\r\n\t ")
+			kind := declarationKind(trimmed)
+			if kind == "" {
+				d.comments = append(d.comments, strings.Trim(strings.TrimPrefix(trimmed, "@comments"), "\r\n\t "))
+			} else {
+				d.kind = kind
+				d.params = parameters(strings.Trim(strings.TrimPrefix(trimmed, kind), "\r\n\t "))
+			}
+		}
+	}
+
+	if options, err := message.WakaOptions(); err == nil && options.Declared() {
+		d.kind, d.params = optionDeclaration(options)
+	}
+
+	return d
+}
+
+func declarationKind(line string) string {
+	for _, kind := range []string{"@rpc", "@post", "@receive"} {
+		if strings.HasPrefix(line, kind) {
+			return kind
+		}
+	}
+	return ""
+}
+
+func optionDeclaration(options *plugin.MessageOptions) (string, map[string]string) {
+	switch {
+	case options.RPC != nil:
+		return "@rpc", map[string]string{
+			"response": options.RPC.Response,
+			"target":   options.RPC.Target,
+		}
+	case options.Direction == plugin.DirectionPost:
+		return "@post", map[string]string{
+			"target": options.Target,
+		}
+	case options.Direction == plugin.DirectionReceive:
+		return "@receive", map[string]string{}
+	}
+	return "", nil
+}
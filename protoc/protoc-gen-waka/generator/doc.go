@@ -0,0 +1,275 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	htemplate "html/template"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"github.com/liuhan907/waka/protoc/plugin"
+	"github.com/liuhan907/waka/protoc/protoc-gen-cellnet/hash"
+)
+
+type DocField struct {
+	Name     string
+	Number   int32
+	Label    string
+	Type     string
+	Link     string
+	Comments []string
+}
+
+type DocMessage struct {
+	ID       uint32
+	Name     string
+	Target   string
+	Comments []string
+	Fields   []*DocField
+}
+
+type DocRPC struct {
+	Name     string
+	Target   string
+	Comments []string
+	Request  *DocMessage
+	Response *DocMessage
+}
+
+type DocEnumValue struct {
+	Name     string
+	Number   int32
+	Comments []string
+}
+
+type DocEnum struct {
+	Name     string
+	Comments []string
+	Values   []*DocEnumValue
+}
+
+type DocPackage struct {
+	Name  string
+	Files []string
+
+	RPC     []*DocRPC
+	Post    []*DocMessage
+	Receive []*DocMessage
+	Others  []*DocMessage
+	Enums   []*DocEnum
+}
+
+type DocModel struct {
+	Packages []*DocPackage
+}
+
+var scalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "double",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "fixed64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "fixed32",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_BYTES:    "bytes",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "sfixed32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "sfixed64",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "sint32",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "sint64",
+}
+
+// 生成接口文档, 多个文件按包合并为一份
+func (g *Generator) generateDoc(prefix string, files []*plugin.FileDescriptor, lang string) {
+	packages := make(map[string]*DocPackage)
+	for _, f := range files {
+		pkg := packages[f.Descriptor.GetPackage()]
+		if pkg == nil {
+			pkg = &DocPackage{Name: f.Descriptor.GetPackage()}
+			packages[pkg.Name] = pkg
+		}
+		g.buildDocPackage(pkg, f)
+	}
+
+	model := new(DocModel)
+	for _, pkg := range packages {
+		model.Packages = append(model.Packages, pkg)
+	}
+	sort.Slice(model.Packages, func(i, j int) bool {
+		return model.Packages[i].Name < model.Packages[j].Name
+	})
+
+	switch lang {
+	case "markdown":
+		g.addFile(prefix+"api.md", g.printDoc(model, MarkdownDocTemplate))
+	case "html":
+		g.addFile(prefix+"api.html", g.printHTMLDoc(model, HTMLDocTemplate))
+	}
+}
+
+func (g *Generator) buildDocPackage(pkg *DocPackage, f *plugin.FileDescriptor) {
+	pkg.Files = append(pkg.Files, f.Descriptor.GetName())
+
+	documented := make(map[string]bool)
+	document := func(name string) *DocMessage {
+		documented[name] = true
+		return g.buildDocMessage(name)
+	}
+
+	for _, rpc := range g.analyseRPCs(f) {
+		pkg.RPC = append(pkg.RPC, &DocRPC{
+			Name:     rpc.Name,
+			Target:   rpc.Target,
+			Comments: rpc.Comments,
+			Request:  document(rpc.Input.Name),
+			Response: document(rpc.Output.Name),
+		})
+	}
+	for _, post := range g.analyseTransports(f, "@post") {
+		message := document(post.Ref.Name)
+		message.Target = post.Target
+		message.Comments = post.Comments
+		pkg.Post = append(pkg.Post, message)
+	}
+	for _, receive := range g.analyseTransports(f, "@receive") {
+		message := document(receive.Ref.Name)
+		message.Comments = receive.Comments
+		pkg.Receive = append(pkg.Receive, message)
+	}
+
+	for _, message := range f.MessageType {
+		name := fullName(f, message.Name)
+		if documented[name] || message.Descriptor.GetOptions().GetMapEntry() {
+			continue
+		}
+		pkg.Others = append(pkg.Others, g.buildDocMessage(name))
+	}
+
+	for _, enum := range f.EnumType {
+		e := &DocEnum{
+			Name:     fullName(f, enum.Name),
+			Comments: docComments(enum.Location),
+		}
+		for i, value := range enum.Descriptor.GetValue() {
+			e.Values = append(e.Values, &DocEnumValue{
+				Name:     value.GetName(),
+				Number:   value.GetNumber(),
+				Comments: docComments(f.Locations[fmt.Sprintf("%s,2,%d", enum.Path, i)]),
+			})
+		}
+		pkg.Enums = append(pkg.Enums, e)
+	}
+}
+
+func (g *Generator) buildDocMessage(name string) *DocMessage {
+	doc := &DocMessage{
+		ID:   hash.StringHash(name),
+		Name: name,
+	}
+
+	f, message := g.FindMessage(name)
+	if message == nil {
+		return doc
+	}
+	doc.Comments = docComments(message.Location)
+
+	for i, field := range message.Descriptor.GetField() {
+		d := &DocField{
+			Name:     field.GetName(),
+			Number:   field.GetNumber(),
+			Type:     scalarTypes[field.GetType()],
+			Comments: docComments(f.Locations[fmt.Sprintf("%s,2,%d", message.Path, i)]),
+		}
+		if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			d.Label = "repeated"
+		}
+		if d.Type == "" {
+			d.Type = strings.TrimPrefix(field.GetTypeName(), ".")
+			if _, entry := g.FindMessage(d.Type); entry != nil && entry.Descriptor.GetOptions().GetMapEntry() {
+				d.Label = ""
+				d.Type = fmt.Sprintf("map<%s, %s>", mapEntryType(entry, 0), mapEntryType(entry, 1))
+			} else {
+				d.Link = d.Type
+			}
+		}
+		doc.Fields = append(doc.Fields, d)
+	}
+
+	return doc
+}
+
+func mapEntryType(entry *plugin.Descriptor, index int) string {
+	field := entry.Descriptor.GetField()[index]
+	if t, ok := scalarTypes[field.GetType()]; ok {
+		return t
+	}
+	return strings.TrimPrefix(field.GetTypeName(), ".")
+}
+
+func fullName(f *plugin.FileDescriptor, name []string) string {
+	return f.Descriptor.GetPackage() + "." + strings.Join(name, ".")
+}
+
+// 取注释中的说明文字, 去掉注解行
+func docComments(location *descriptor.SourceCodeInfo_Location) []string {
+	text := location.GetLeadingComments()
+	if strings.Trim(text, "\r\n\t ") == "" {
+		text = location.GetTrailingComments()
+	}
+
+	var comments []string
+	for _, line := range strings.Split(strings.Trim(text, "\r\n\t "), "\n") {
+		line = strings.Trim(line, "\r\n\t ")
+		if line == "" || strings.HasPrefix(line, "@rpc") || strings.HasPrefix(line, "@post") || strings.HasPrefix(line, "@receive") {
+			continue
+		}
+		if strings.HasPrefix(line, "@validate") {
+			line = "校验: " + strings.Trim(strings.TrimPrefix(line, "@validate"), "\r\n\t ")
+		}
+		comments = append(comments, strings.Trim(strings.TrimPrefix(line, "@comments"), "\r\n\t "))
+	}
+	return comments
+}
+
+func (g *Generator) printDoc(model *DocModel, tpl string) string {
+	t, err := template.New("protoc-gen-waka").Funcs(template.FuncMap{
+		"cell": func(s []string) string {
+			return strings.Replace(strings.Join(s, "<br>"), "|", "\\|", -1)
+		},
+		"code": func(s string) string {
+			return "`" + s + "`"
+		},
+	}).Parse(tpl)
+	if err != nil {
+		g.Error(err, "template parse failed")
+	}
+
+	w := bytes.NewBuffer(make([]byte, 0, 1024))
+	err = t.Execute(w, model)
+	if err != nil {
+		g.Error(err, "execute template")
+	}
+
+	return w.String()
+}
+
+func (g *Generator) printHTMLDoc(model *DocModel, tpl string) string {
+	t, err := htemplate.New("protoc-gen-waka").Parse(tpl)
+	if err != nil {
+		g.Error(err, "template parse failed")
+	}
+
+	w := bytes.NewBuffer(make([]byte, 0, 1024))
+	w.WriteString(HTMLDocHeader)
+	err = t.Execute(w, model)
+	if err != nil {
+		g.Error(err, "execute template")
+	}
+
+	return w.String()
+}
@@ -2,36 +2,136 @@ package generator
 
 import (
 	"bytes"
+	"go/format"
+	"path"
 	"strings"
 	"text/template"
 
 	"github.com/golang/protobuf/proto"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
 	"github.com/golang/protobuf/protoc-gen-go/plugin"
 
 	"github.com/liuhan907/waka/protoc/plugin"
+	"github.com/liuhan907/waka/protoc/protoc-gen-cellnet/hash"
 	"github.com/liuhan907/waka/protoc/protoc-gen-cellnet/named"
 )
 
+const (
+	// 默认的消息处理者
+	defaultTarget = "hall"
+
+	// TypeScript 代码引用的 protobufjs 静态模块
+	defaultTSImport = "./proto"
+)
+
+// SDK 核心使用的 waka_proto 消息
+var coreMessages = []string{
+	"Heart",
+	"Transport",
+	"FutureRequest",
+	"FutureResponse",
+	"Negotiate",
+	"Negotiated",
+}
+
+// 导入后不需要在 SDK 中注册的包, 核心消息与选项定义
+var unregisteredPackages = []string{"waka_proto", "waka", "google.protobuf"}
+
+// 消息类型在各语言中的名称
+type TypeDescriptor struct {
+	Name        string
+	CSharp      string
+	TS          string
+	TSInterface string
+	TSPackage   string
+
+	GoName       string
+	GoPackage    string
+	GoImportPath string
+}
+
 type RPCDescriptor struct {
 	Name            string
+	Method          string
 	InputType       string
 	OutputType      string
+	Input           *TypeDescriptor
+	Output          *TypeDescriptor
+	Target          string
+	Comments        []string
 	LeadingComments string
 }
 
 type TransportDescriptor struct {
 	Type            string
+	Method          string
+	Ref             *TypeDescriptor
+	Target          string
+	Comments        []string
 	LeadingComments string
 }
 
+type GoRPCDescriptor struct {
+	InputType  string
+	OutputType string
+	Comments   []string
+}
+
+type GoTransportDescriptor struct {
+	Type     string
+	Comments []string
+}
+
+type TargetDescriptor struct {
+	Name  string
+	Title string
+
+	RPC  []*GoRPCDescriptor
+	Post []*GoTransportDescriptor
+}
+
+type GoImportDescriptor struct {
+	Alias string
+	Path  string
+}
+
+type GoFileDescriptor struct {
+	FileName   string
+	OutputName string
+	GoPackage  string
+
+	Imports    []*GoImportDescriptor
+	Targets    []*TargetDescriptor
+	Validators []*ValidatorDescriptor
+	ImportUTF8 bool
+}
+
+type MessageDescriptor struct {
+	ID   uint32
+	Name string
+}
+
+type MetaProviderDescriptor struct {
+	FileName  string
+	Namespace string
+	ClassName string
+	TSFile    string
+	Package   string
+	TSImport  string
+
+	Messages []*MessageDescriptor
+}
+
 type FileDescriptor struct {
-	FileName              string
-	MetaProviderClassName string
-	Namespace             string
+	FileName   string
+	TSImport   string
+	TSPackages string
 
-	RPC     []*RPCDescriptor
-	Post    []*TransportDescriptor
-	Receive []*TransportDescriptor
+	RPC           []*RPCDescriptor
+	Post          []*TransportDescriptor
+	Receive       []*TransportDescriptor
+	MetaProviders []*MetaProviderDescriptor
+	CoreMessages  []*MessageDescriptor
 }
 
 type Generator struct {
@@ -39,44 +139,282 @@ type Generator struct {
 }
 
 func (g *Generator) GenerateAllFiles() {
-	for _, v := range g.Files {
-		core, supervisor, dispatcher := g.printAllFiles(v)
-		g.Response.File = append(g.Response.File, &plugin_go.CodeGeneratorResponse_File{
-			Name:    proto.String("CoreSupervisor.cs"),
-			Content: proto.String(core),
-		})
-		g.Response.File = append(g.Response.File, &plugin_go.CodeGeneratorResponse_File{
-			Name:    proto.String("Supervisor.cs"),
-			Content: proto.String(supervisor),
-		})
-		g.Response.File = append(g.Response.File, &plugin_go.CodeGeneratorResponse_File{
-			Name:    proto.String("IDispatcher.cs"),
-			Content: proto.String(dispatcher),
-		})
-	}
-}
-
-func (g *Generator) printAllFiles(f *plugin.FileDescriptor) (core, supervisor string, dispatcher string) {
-	packages := strings.Split(f.Descriptor.GetPackage(), "_")
-	for i := range packages {
-		packages[i] = strings.Title(packages[i])
-	}
-	packageName := strings.Join(packages, "")
+	files := g.FilesToGenerate()
+
+	if problems := g.lint(files); len(problems) > 0 {
+		g.ResponseError(problems...)
+		return
+	}
+
+	switch g.Parameters["output"] {
+	case "", "merged":
+		g.generate("", files)
+	case "file":
+		for _, f := range files {
+			g.generate(strings.TrimSuffix(f.Descriptor.GetName(), ".proto")+"/", []*plugin.FileDescriptor{f})
+		}
+	default:
+		g.Fail("unknown output mode", g.Parameters["output"])
+	}
+}
+
+// 生成一组文件的代码, 全部输出文件名加上 prefix
+func (g *Generator) generate(prefix string, files []*plugin.FileDescriptor) {
+	lang := g.Parameters["lang"]
+	if lang == "markdown" || lang == "html" {
+		g.generateDoc(prefix, files, lang)
+		return
+	}
+
+	model := g.buildModel(files)
+	if lang == "" || lang == "all" || lang == "csharp" {
+		g.addFile(prefix+"CoreSupervisor.cs", g.printFile(model, CSharpSupervisorTemplate))
+		g.addFile(prefix+"Supervisor.cs", g.printFile(model, CSharpHandlerTemplate))
+		g.addFile(prefix+"IDispatcher.cs", g.printFile(model, CSharpIDispatcherTemplate))
+	}
+	if lang == "" || lang == "all" || lang == "go" {
+		for _, goModel := range g.buildGoModels(files) {
+			g.addFile(goModel.OutputName, g.printGoFile(goModel, GoHandlerTemplate))
+		}
+	}
+	if lang == "all" || lang == "ts" {
+		g.addFile(prefix+"CoreSupervisor.ts", g.printFile(model, TSSupervisorTemplate))
+		g.addFile(prefix+"Supervisor.ts", g.printFile(model, TSHandlerTemplate))
+		g.addFile(prefix+"IDispatcher.ts", g.printFile(model, TSIDispatcherTemplate))
+		for _, provider := range model.MetaProviders {
+			g.addFile(prefix+provider.TSFile+".ts", g.printFile(provider, TSMetaProviderTemplate))
+		}
+	}
+}
+
+func (g *Generator) addFile(name, content string) {
+	g.Response.File = append(g.Response.File, &plugin_go.CodeGeneratorResponse_File{
+		Name:    proto.String(name),
+		Content: proto.String(content),
+	})
+}
+
+func (g *Generator) buildModel(files []*plugin.FileDescriptor) *FileDescriptor {
 	model := &FileDescriptor{
-		FileName:              f.Descriptor.GetName(),
-		MetaProviderClassName: named.BuildMetaProviderClassName(f),
-		Namespace:             packageName,
-		RPC:                   g.analyseRPCs(f),
-		Post:                  g.analyseTransports(f, "@post"),
-		Receive:               g.analyseTransports(f, "@receive"),
+		TSImport: g.Parameters["ts_import"],
+	}
+	if model.TSImport == "" {
+		model.TSImport = defaultTSImport
+	}
+
+	var names []string
+	tsPackages := make(map[string]bool)
+	var tsPackageList []string
+	addTSPackage := func(pkg string) {
+		if !tsPackages[pkg] {
+			tsPackages[pkg] = true
+			tsPackageList = append(tsPackageList, pkg)
+		}
+	}
+
+	for _, f := range files {
+		names = append(names, f.Descriptor.GetName())
+		model.RPC = append(model.RPC, g.analyseRPCs(f)...)
+		model.Post = append(model.Post, g.analyseTransports(f, "@post")...)
+		model.Receive = append(model.Receive, g.analyseTransports(f, "@receive")...)
+		model.MetaProviders = append(model.MetaProviders, newMetaProviderDescriptor(files, f, model.TSImport))
+		addTSPackage(f.Descriptor.GetPackage())
+	}
+	model.FileName = strings.Join(names, ", ")
+
+	// 导入文件中的消息也可能出现在响应与推送中, 一并注册
+	for _, f := range g.dependencies(files) {
+		if contains(unregisteredPackages, f.Descriptor.GetPackage()) {
+			continue
+		}
+		model.MetaProviders = append(model.MetaProviders, newMetaProviderDescriptor([]*plugin.FileDescriptor{f}, f, model.TSImport))
+		addTSPackage(f.Descriptor.GetPackage())
+	}
+
+	for _, rpc := range model.RPC {
+		addTSPackage(rpc.Input.TSPackage)
+		addTSPackage(rpc.Output.TSPackage)
+	}
+	for _, transport := range append(append([]*TransportDescriptor{}, model.Post...), model.Receive...) {
+		addTSPackage(transport.Ref.TSPackage)
+	}
+	model.TSPackages = strings.Join(tsPackageList, ", ")
+
+	for _, name := range coreMessages {
+		model.CoreMessages = append(model.CoreMessages, newMessageDescriptor("waka_proto", []string{name}))
+	}
+
+	buildMethods(model)
+
+	return model
+}
+
+func newMetaProviderDescriptor(files []*plugin.FileDescriptor, f *plugin.FileDescriptor, tsImport string) *MetaProviderDescriptor {
+	return &MetaProviderDescriptor{
+		FileName:  f.Descriptor.GetName(),
+		Namespace: named.BuildNamespace(f),
+		ClassName: named.BuildUniqueMetaProviderClassName(files, f),
+		TSFile:    named.BuildMetaProviderClassFileName(f),
+		Package:   f.Descriptor.GetPackage(),
+		TSImport:  tsImport,
+		Messages:  buildMessages(f),
+	}
+}
+
+// 生成文件直接或间接导入的其它文件
+func (g *Generator) dependencies(files []*plugin.FileDescriptor) []*plugin.FileDescriptor {
+	seen := make(map[string]bool)
+	for _, f := range files {
+		seen[f.Descriptor.GetName()] = true
 	}
 
-	return g.printFile(model, CSharpSupervisorTemplate),
-		g.printFile(model, CSharpHandlerTemplate),
-		g.printFile(model, CSharpIDispatcherTemplate)
+	var r []*plugin.FileDescriptor
+	var visit func(f *plugin.FileDescriptor)
+	visit = func(f *plugin.FileDescriptor) {
+		for _, name := range f.Descriptor.GetDependency() {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, dep := range g.Files {
+				if dep.Descriptor.GetName() == name {
+					visit(dep)
+					r = append(r, dep)
+				}
+			}
+		}
+	}
+	for _, f := range files {
+		visit(f)
+	}
+	return r
 }
 
-func (g *Generator) printFile(model *FileDescriptor, tpl string) string {
+// 合并多个包时同名消息生成的方法会冲突, 冲突时以命名空间区分
+func buildMethods(model *FileDescriptor) {
+	rpcs := make(map[string]int)
+	for _, rpc := range model.RPC {
+		rpcs[rpc.Name]++
+	}
+	for _, rpc := range model.RPC {
+		rpc.Method = rpc.Name
+		if rpcs[rpc.Name] > 1 {
+			rpc.Method = strings.Replace(rpc.Input.CSharp, ".", "", -1)
+			rpc.Method = strings.TrimSuffix(rpc.Method, "Request")
+		}
+	}
+
+	for _, transports := range [][]*TransportDescriptor{model.Post, model.Receive} {
+		types := make(map[string]int)
+		for _, transport := range transports {
+			types[transport.Type]++
+		}
+		for _, transport := range transports {
+			transport.Method = transport.Type
+			if types[transport.Type] > 1 {
+				transport.Method = strings.Replace(transport.Ref.CSharp, ".", "", -1)
+			}
+		}
+	}
+}
+
+// Go 代码按 Go 包输出, 同一个包的多个文件合并为一份
+func (g *Generator) buildGoModels(files []*plugin.FileDescriptor) []*GoFileDescriptor {
+	var models []*GoFileDescriptor
+	groups := make(map[string][]*plugin.FileDescriptor)
+	var order []string
+	for _, f := range files {
+		key := path.Dir(f.Descriptor.GetName()) + "/" + goPackageName(f)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], f)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		first := group[0]
+
+		model := &GoFileDescriptor{
+			OutputName: strings.TrimSuffix(first.Descriptor.GetName(), ".proto") + ".waka.go",
+			GoPackage:  goPackageName(first),
+		}
+		if len(group) > 1 {
+			model.OutputName = path.Join(path.Dir(first.Descriptor.GetName()), model.GoPackage+".waka.go")
+		}
+
+		var names []string
+		var rpcs []*RPCDescriptor
+		var posts []*TransportDescriptor
+		for _, f := range group {
+			names = append(names, f.Descriptor.GetName())
+			rpcs = append(rpcs, g.analyseRPCs(f)...)
+			posts = append(posts, g.analyseTransports(f, "@post")...)
+			model.Validators = append(model.Validators, g.buildValidators(f)...)
+		}
+		for _, v := range model.Validators {
+			for _, field := range v.Fields {
+				if strings.Contains(field.Code, "utf8.") {
+					model.ImportUTF8 = true
+				}
+			}
+		}
+		model.FileName = strings.Join(names, ", ")
+
+		localPath := goImportPath(first)
+		imports := make(map[string]bool)
+		goType := func(t *TypeDescriptor) string {
+			if t.GoImportPath == localPath || t.GoImportPath == "" {
+				return t.GoName
+			}
+			if !imports[t.GoImportPath] {
+				imports[t.GoImportPath] = true
+				model.Imports = append(model.Imports, &GoImportDescriptor{
+					Alias: t.GoPackage,
+					Path:  t.GoImportPath,
+				})
+			}
+			return t.GoPackage + "." + t.GoName
+		}
+
+		find := func(name string) *TargetDescriptor {
+			for _, target := range model.Targets {
+				if target.Name == name {
+					return target
+				}
+			}
+			target := &TargetDescriptor{
+				Name:  name,
+				Title: gogen.CamelCase(name),
+			}
+			model.Targets = append(model.Targets, target)
+			return target
+		}
+
+		find(defaultTarget)
+		for _, rpc := range rpcs {
+			target := find(rpc.Target)
+			target.RPC = append(target.RPC, &GoRPCDescriptor{
+				InputType:  goType(rpc.Input),
+				OutputType: goType(rpc.Output),
+				Comments:   rpc.Comments,
+			})
+		}
+		for _, post := range posts {
+			target := find(post.Target)
+			target.Post = append(target.Post, &GoTransportDescriptor{
+				Type:     goType(post.Ref),
+				Comments: post.Comments,
+			})
+		}
+
+		models = append(models, model)
+	}
+
+	return models
+}
+
+func (g *Generator) printFile(model interface{}, tpl string) string {
 	t, err := template.New("protoc-gen-waka").Parse(tpl)
 	if err != nil {
 		g.Error(err, "template parse failed")
@@ -91,107 +429,165 @@ func (g *Generator) printFile(model *FileDescriptor, tpl string) string {
 	return w.String()
 }
 
+func (g *Generator) printGoFile(model interface{}, tpl string) string {
+	d, err := format.Source([]byte(g.printFile(model, tpl)))
+	if err != nil {
+		g.Error(err, "format go source")
+	}
+	return string(d)
+}
+
+// 查找消息类型, name 可以是当前包内的名称或带包名的全名
+func (g *Generator) resolveType(f *plugin.FileDescriptor, name string) *TypeDescriptor {
+	file, message := g.FindMessage(f.Descriptor.GetPackage() + "." + name)
+	if message == nil {
+		file, message = g.FindMessage(name)
+	}
+	if message == nil {
+		return nil
+	}
+
+	pkg := file.Descriptor.GetPackage()
+	last := len(message.Name) - 1
+	return &TypeDescriptor{
+		Name:         pkg + "." + strings.Join(message.Name, "."),
+		CSharp:       named.BuildFullName(file, message),
+		TS:           pkg + "." + strings.Join(message.Name, "."),
+		TSInterface:  pkg + "." + strings.Join(append(append([]string{}, message.Name[:last]...), "I"+message.Name[last]), "."),
+		TSPackage:    pkg,
+		GoName:       gogen.CamelCaseSlice(message.Name),
+		GoPackage:    goPackageName(file),
+		GoImportPath: goImportPath(file),
+	}
+}
+
 func (g *Generator) analyseRPCs(f *plugin.FileDescriptor) []*RPCDescriptor {
 	var descriptors []*RPCDescriptor
 	for _, message := range f.MessageType {
-		descriptors = g.analyseRPC(descriptors, message)
+		descriptors = g.analyseRPC(descriptors, f, message)
 	}
 	return descriptors
 }
 
-func (g *Generator) analyseRPC(descriptors []*RPCDescriptor, message *plugin.Descriptor) []*RPCDescriptor {
+func (g *Generator) analyseRPC(descriptors []*RPCDescriptor, f *plugin.FileDescriptor, message *plugin.Descriptor) []*RPCDescriptor {
 	if message.Parent != nil {
 		return descriptors
 	}
 
-	trimmed := strings.Trim(message.Location.GetLeadingComments(), "\r\n\t ")
-	if trimmed == "" {
+	d := declare(message)
+	if d.kind != "@rpc" || d.params["response"] == "" {
 		return descriptors
 	}
 
-	var inputType string
-	var outputType string
-	var comments string
-
-	split := strings.Split(trimmed, "\n")
-	for _, line := range split {
-		trimmed := strings.Trim(line, "\r\n\t ")
-		if !strings.HasPrefix(trimmed, "@rpc") {
-			comments += "        /// " + strings.Trim(strings.TrimPrefix(trimmed, "@comments"), "\r\n\t ") + "\n"
-		} else {
-			param := parameters(strings.Trim(strings.TrimPrefix(trimmed, "@rpc"), "\r\n\t "))
-			response := param["response"]
-			if response == "" {
-				return descriptors
-			}
-			inputType = "" + message.Name[len(message.Name)-1]
-			outputType = "" + response
-		}
-	}
+	inputType := message.Name[len(message.Name)-1]
+	outputType := d.params["response"]
 
-	if comments == "" {
-		comments = "        /// 没有注释"
-	} else {
-		comments = strings.Trim(comments, "\n")
+	input := g.resolveType(f, inputType)
+	output := g.resolveType(f, outputType)
+	if input == nil || output == nil {
+		return descriptors
 	}
 
-	if inputType == "" || outputType == "" {
-		return descriptors
+	target := d.params["target"]
+	if target == "" {
+		target = defaultTarget
 	}
 
 	return append(descriptors, &RPCDescriptor{
 		Name:            strings.TrimSuffix(inputType, "Request"),
 		InputType:       inputType,
 		OutputType:      outputType,
-		LeadingComments: comments,
+		Input:           input,
+		Output:          output,
+		Target:          target,
+		Comments:        d.comments,
+		LeadingComments: csharpComments(d.comments),
 	})
 }
 
 func (g *Generator) analyseTransports(f *plugin.FileDescriptor, prefix string) []*TransportDescriptor {
 	var descriptors []*TransportDescriptor
 	for _, message := range f.MessageType {
-		descriptors = g.analyseTransport(descriptors, message, prefix)
+		descriptors = g.analyseTransport(descriptors, f, message, prefix)
 	}
 	return descriptors
 }
 
-func (g *Generator) analyseTransport(descriptors []*TransportDescriptor, message *plugin.Descriptor, prefix string) []*TransportDescriptor {
+func (g *Generator) analyseTransport(descriptors []*TransportDescriptor, f *plugin.FileDescriptor, message *plugin.Descriptor, prefix string) []*TransportDescriptor {
 	if message.Parent != nil {
 		return descriptors
 	}
 
-	trimmed := strings.Trim(message.Location.GetLeadingComments(), "\r\n\t ")
-	if trimmed == "" {
+	d := declare(message)
+	if d.kind != prefix {
 		return descriptors
 	}
 
-	var typeName string
-	var comments string
+	typeName := message.Name[len(message.Name)-1]
+
+	target := d.params["target"]
+	if target == "" {
+		target = defaultTarget
+	}
+
+	return append(descriptors, &TransportDescriptor{
+		Type:            typeName,
+		Ref:             g.resolveType(f, typeName),
+		Target:          target,
+		Comments:        d.comments,
+		LeadingComments: csharpComments(d.comments),
+	})
+}
 
-	split := strings.Split(trimmed, "\n")
-	for _, line := range split {
-		trimmed := strings.Trim(line, "\r\n\t ")
-		if strings.HasPrefix(trimmed, prefix) {
-			typeName = "" + message.Name[len(message.Name)-1]
-		} else {
-			comments += "        /// " + strings.Trim(strings.TrimPrefix(trimmed, "@comments"), "\r\n\t ") + "\n"
+func buildMessages(f *plugin.FileDescriptor) []*MessageDescriptor {
+	var messages []*MessageDescriptor
+	for _, message := range f.MessageType {
+		if message.Descriptor.GetOptions().GetMapEntry() {
+			continue
 		}
+		messages = append(messages, newMessageDescriptor(f.Descriptor.GetPackage(), message.Name))
 	}
+	return messages
+}
 
-	if comments == "" {
-		comments = "        /// 没有注释"
-	} else {
-		comments = strings.Trim(comments, "\n")
+func newMessageDescriptor(pkg string, name []string) *MessageDescriptor {
+	fullName := pkg + "." + strings.Join(name, ".")
+	return &MessageDescriptor{
+		ID:   hash.StringHash(fullName),
+		Name: fullName,
 	}
+}
 
-	if typeName == "" {
-		return descriptors
+func goPackageName(f *plugin.FileDescriptor) string {
+	if pkg := f.Descriptor.GetOptions().GetGoPackage(); pkg != "" {
+		if i := strings.LastIndex(pkg, ";"); i >= 0 {
+			return pkg[i+1:]
+		}
+		return strings.Replace(path.Base(pkg), "-", "_", -1)
+	}
+	return strings.Replace(f.Descriptor.GetPackage(), ".", "_", -1)
+}
+
+// 导入路径, 未设置 go_package 时以 proto 文件所在目录代替
+func goImportPath(f *plugin.FileDescriptor) string {
+	if pkg := f.Descriptor.GetOptions().GetGoPackage(); pkg != "" {
+		if i := strings.LastIndex(pkg, ";"); i >= 0 {
+			return pkg[:i]
+		}
+		return pkg
 	}
+	return path.Dir(f.Descriptor.GetName())
+}
 
-	return append(descriptors, &TransportDescriptor{
-		Type:            typeName,
-		LeadingComments: comments,
-	})
+func csharpComments(comments []string) string {
+	if len(comments) == 0 {
+		return "        /// 没有注释"
+	}
+	var lines []string
+	for _, comment := range comments {
+		lines = append(lines, "        /// "+comment)
+	}
+	return strings.Join(lines, "\n")
 }
 
 func NewGenerator(name string) *Generator {
@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	"github.com/liuhan907/waka/protoc/plugin"
+)
+
+// 注解及其允许的参数
+var annotations = map[string][]string{
+	"@rpc":      {"response", "target"},
+	"@post":     {"target"},
+	"@receive":  {},
+	"@comments": nil,
+}
+
+// 字段上允许的注解
+var fieldAnnotations = []string{"@validate"}
+
+type annotation struct {
+	name   string
+	params map[string]string
+	rest   string
+	line   int
+}
+
+// 检查文件的注解, 返回带位置的错误
+func (g *Generator) lint(files []*plugin.FileDescriptor) []string {
+	var problems []string
+	for _, f := range files {
+		problems = append(problems, g.lintFile(f)...)
+	}
+	return problems
+}
+
+func (g *Generator) lintFile(f *plugin.FileDescriptor) []string {
+	var problems []string
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("%s:%d: %s", f.Descriptor.GetName(), line, fmt.Sprintf(format, args...)))
+	}
+
+	for _, message := range f.MessageType {
+		name := strings.Join(message.Name, ".")
+
+		var found []*annotation
+		for _, a := range parseAnnotations(message.Location) {
+			allowed, known := annotations[a.name]
+			if !known {
+				report(a.line, "%s: unknown annotation %s", name, a.name)
+				continue
+			}
+			if allowed == nil {
+				continue
+			}
+			for key := range a.params {
+				if !contains(allowed, key) {
+					report(a.line, "%s: unknown parameter %q for %s", name, key, a.name)
+				}
+			}
+			found = append(found, a)
+		}
+
+		for i, field := range message.Descriptor.GetField() {
+			if message.Descriptor.GetOptions().GetMapEntry() {
+				break
+			}
+			for _, a := range parseAnnotations(f.Locations[fmt.Sprintf("%s,2,%d", message.Path, i)]) {
+				if !contains(fieldAnnotations, a.name) {
+					report(a.line, "%s.%s: unknown field annotation %s", name, field.GetName(), a.name)
+					continue
+				}
+				for _, rule := range splitValidate(a.rest) {
+					if reason := checkValidateRule(field, rule); reason != "" {
+						report(a.line, "%s.%s: %s", name, field.GetName(), reason)
+					}
+				}
+			}
+		}
+
+		options, err := message.WakaOptions()
+		if err != nil {
+			report(messageLine(message), "%s: bad waka options: %v", name, err)
+			continue
+		}
+		if options.Declared() {
+			line := messageLine(message)
+			if options.RPC != nil && options.Direction != plugin.DirectionNone {
+				report(line, "%s: option rpc conflicts with option direction", name)
+			}
+			if options.Target != "" && options.Direction != plugin.DirectionPost {
+				report(line, "%s: option target only applies to post", name)
+			}
+			if len(found) > 0 {
+				report(found[0].line, "%s: declared by both option and comment annotation %s", name, found[0].name)
+			}
+
+			kind, params := optionDeclaration(options)
+			if kind == "" {
+				continue
+			}
+			found = []*annotation{{name: kind, params: params, line: line}}
+		}
+
+		if len(found) == 0 {
+			continue
+		}
+
+		if message.Parent != nil {
+			report(found[0].line, "%s: nested message can not carry %s", name, found[0].name)
+			continue
+		}
+		for _, a := range found[1:] {
+			report(a.line, "%s: duplicate declaration %s, already declared %s at line %d", name, a.name, found[0].name, found[0].line)
+		}
+
+		a := found[0]
+		if a.name == "@rpc" {
+			if !strings.HasSuffix(name, "Request") || name == "Request" {
+				report(a.line, "%s: rpc request name must end with Request", name)
+			}
+			response := a.params["response"]
+			if response == "" {
+				report(a.line, "%s: rpc missing response", name)
+			} else if g.resolveType(f, response) == nil {
+				report(a.line, "%s: rpc response %s not found", name, response)
+			}
+		}
+	}
+
+	return problems
+}
+
+// 消息声明所在行
+func messageLine(message *plugin.Descriptor) int {
+	if span := message.Location.GetSpan(); len(span) > 0 {
+		return int(span[0]) + 1
+	}
+	return 0
+}
+
+// 解析注释中的注解, 行号从 1 开始, 尾随注释与声明同一行
+func parseAnnotations(location *descriptor.SourceCodeInfo_Location) []*annotation {
+	first := 0
+	if span := location.GetSpan(); len(span) > 0 {
+		first = int(span[0])
+	}
+
+	var r []*annotation
+	if comments := location.GetLeadingComments(); strings.Trim(comments, "\r\n\t ") != "" {
+		lines := strings.Split(strings.TrimSuffix(comments, "\n"), "\n")
+		r = append(r, annotationLines(lines, first-len(lines))...)
+	}
+	if comments := location.GetTrailingComments(); strings.Trim(comments, "\r\n\t ") != "" {
+		lines := strings.Split(strings.TrimSuffix(comments, "\n"), "\n")
+		r = append(r, annotationLines(lines, first)...)
+	}
+	return r
+}
+
+func annotationLines(lines []string, first int) []*annotation {
+	var r []*annotation
+	for i, line := range lines {
+		trimmed := strings.Trim(line, "\r\n\t ")
+		if !strings.HasPrefix(trimmed, "@") {
+			continue
+		}
+		name := trimmed
+		rest := ""
+		if i := strings.IndexAny(trimmed, " \t"); i >= 0 {
+			name, rest = trimmed[:i], strings.Trim(trimmed[i+1:], "\r\n\t ")
+		}
+		r = append(r, &annotation{
+			name:   name,
+			params: lintParameters(rest),
+			rest:   rest,
+			line:   first + i + 1,
+		})
+	}
+	return r
+}
+
+func lintParameters(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	return parameters(s)
+}
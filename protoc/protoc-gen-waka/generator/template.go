@@ -8,6 +8,10 @@ using CellnetSDK.Meta;
 using Google.Protobuf;
 using System;
 using System.Linq;
+#if WAKA_ASYNC
+using System.Threading;
+using System.Threading.Tasks;
+#endif
 
 namespace WakaSDK
 {
@@ -20,23 +24,30 @@ namespace WakaSDK
         /// <summary>
 {{.LeadingComments}}
         /// </summary>
-        static public void Request{{.Name}}({{$.Namespace}}.{{.InputType}} request, Action< string, {{$.Namespace}}.{{.OutputType}} > andThen)
+        static public void Request{{.Method}}({{.Input.CSharp}} request, Action< string, {{.Output.CSharp}} > andThen)
         {
-            var req = BuildFutureRequest(request);
-            ThenTable.Add(req.Number, (status, x) =>
-            {
-                var ev = ({{$.Namespace}}.{{.OutputType}})x;
-                andThen(status, ev);
-            });
-            Session?.Send(req);
+            Request(request, null, (status, x) => andThen(status, x as {{.Output.CSharp}}));
         }
+#if WAKA_ASYNC
+
+        /// <summary>
+{{.LeadingComments}}
+        /// </summary>
+        /// <param name="cancellationToken">取消请求, 取消后忽略响应</param>
+        /// <param name="timeout">超时时长, 为空时使用 RequestTimeout</param>
+        /// <exception cref="FutureException">请求失败</exception>
+        static public Task<{{.Output.CSharp}}> Request{{.Method}}Async({{.Input.CSharp}} request, CancellationToken cancellationToken = default(CancellationToken), TimeSpan? timeout = null)
+        {
+            return RequestAsync<{{.Output.CSharp}}>(request, timeout, cancellationToken);
+        }
+#endif
         {{end}}
 
         {{range .Post}}
         /// <summary>
 {{.LeadingComments}}
         /// </summary>
-        static public void Post{{.Type}}({{$.Namespace}}.{{.Type}} request)
+        static public void Post{{.Method}}({{.Ref.CSharp}} request)
         {
             var req = BuildTransportRequest(request);
             Session?.Send(req);
@@ -58,8 +69,8 @@ namespace WakaSDK
             switch (meta.Name)
             {
                 {{range .Receive}}
-                case "{{$.Namespace}}.{{.Type}}":
-                    return Dispatcher.Event{{.Type}}(({{$.Namespace}}.{{.Type}})message);
+                case "{{.Ref.CSharp}}":
+                    return Dispatcher.Event{{.Method}}(({{.Ref.CSharp}})message);
                 {{end}}
                 default:
                     break;
@@ -100,7 +111,7 @@ namespace WakaSDK
         /// <summary>
 {{.LeadingComments}}
         /// </summary>
-        bool Event{{.Type}}({{$.Namespace}}.{{.Type}} ev);
+        bool Event{{.Method}}({{.Ref.CSharp}} ev);
         {{end}}
     }
 }
@@ -118,24 +129,126 @@ using System;
 using System.Collections.Generic;
 using System.Linq;
 using System.Threading;
+#if WAKA_ASYNC
+using System.Threading.Tasks;
+#endif
 
 namespace WakaSDK
 {
+    /// <summary>
+    /// 请求失败的原因
+    /// </summary>
+    public enum FutureError
+    {
+        /// <summary>
+        /// 服务器处理失败
+        /// </summary>
+        Remote,
+
+        /// <summary>
+        /// 请求未通过服务器校验
+        /// </summary>
+        InvalidRequest,
+
+        /// <summary>
+        /// 请求超时
+        /// </summary>
+        Timeout,
+
+        /// <summary>
+        /// 连接断开
+        /// </summary>
+        Closed,
+
+        /// <summary>
+        /// 未连接
+        /// </summary>
+        NotConnected,
+
+        /// <summary>
+        /// 未知的响应类型
+        /// </summary>
+        UnknownResponse,
+    }
+
+    /// <summary>
+    /// 请求失败
+    /// </summary>
+    public class FutureException : Exception
+    {
+        /// <summary>
+        /// 失败原因
+        /// </summary>
+        public FutureError Error { get; private set; }
+
+        /// <summary>
+        /// 响应状态
+        /// </summary>
+        public string Status { get; private set; }
+
+        public FutureException(string status) : base(status)
+        {
+            Status = status;
+            Error = ParseError(status);
+        }
+
+        /// <summary>
+        /// 由响应状态得到失败原因
+        /// </summary>
+        static public FutureError ParseError(string status)
+        {
+            switch (status)
+            {
+                case Supervisor.StatusTimeout:
+                    return FutureError.Timeout;
+                case Supervisor.StatusClosed:
+                    return FutureError.Closed;
+                case Supervisor.StatusNotConnected:
+                    return FutureError.NotConnected;
+                case Supervisor.StatusUnknownResponse:
+                    return FutureError.UnknownResponse;
+            }
+            if (status.StartsWith(Supervisor.StatusInvalidRequest))
+            {
+                return FutureError.InvalidRequest;
+            }
+            return FutureError.Remote;
+        }
+    }
+
     /// <summary>
     /// SDK接口
     /// </summary>
     public partial class Supervisor
     {
+        public const string StatusSuccess = "success";
+        public const string StatusTimeout = "failed: timeout";
+        public const string StatusClosed = "failed: closed";
+        public const string StatusNotConnected = "failed: not connected";
+        public const string StatusUnknownResponse = "failed: unknown response type";
+        public const string StatusInvalidRequest = "failed: invalid request";
+
+        private class PendingRequest
+        {
+            public Action<string, object> AndThen;
+            public DateTime Deadline;
+        }
+
         static private EventQueue Evq = null;
         static private Callback Callback = null;
         static private Connector Connector = null;
         static private ISession Session = null;
         static private IDispatcher Dispatcher = null;
-        static private Dictionary<ulong, Action<string, object>> ThenTable = null;
+        static private Dictionary<ulong, PendingRequest> ThenTable = null;
         static private long Number = 1;
         static private DateTime LastRemoteHeartTime = DateTime.UtcNow;
         static private DateTime LastLocalHeartTime = DateTime.UtcNow;
 
+        /// <summary>
+        /// 请求默认超时时长
+        /// </summary>
+        static public TimeSpan RequestTimeout = TimeSpan.FromSeconds(10);
+
         /// <summary>
         /// 设置推送消息处理器
         /// </summary>
@@ -153,7 +266,7 @@ namespace WakaSDK
         static public void Connect(string host, int port)
         {
             Evq.Clear();
-            ThenTable.Clear();
+            RejectAll(StatusClosed);
             Connector.Connect(host, port);
         }
 
@@ -164,6 +277,7 @@ namespace WakaSDK
         {
             Session?.Close();
             Session = null;
+            RejectAll(StatusClosed);
         }
 
         /// <summary>
@@ -180,6 +294,7 @@ namespace WakaSDK
                 Session?.Send(new WakaProto.Heart());
                 LastLocalHeartTime = DateTime.UtcNow;
             }
+            ExpirePending();
             Evq.Loop();
         }
 
@@ -193,23 +308,24 @@ namespace WakaSDK
         static private void ConnectFailed()
         {
             Session = null;
+            RejectAll(StatusNotConnected);
             Dispatcher?.ConnectFailed();
         }
 
         static private void Closed(ISession s)
         {
             Session = null;
+            RejectAll(StatusClosed);
             Dispatcher?.Closed();
         }
 
         static private bool RedirectFutureResponse(ISession ses, uint id, IMessage message, byte[] rawData)
         {
             var response = (WakaProto.FutureResponse)message;
-            if (ThenTable.TryGetValue(response.Number, out Action<string, object> andThen))
+            var andThen = TakePending(response.Number);
+            if (andThen != null)
             {
-                ThenTable.Remove(response.Number);
-
-                if (response.Status != "success")
+                if (response.Status != StatusSuccess)
                 {
                     andThen(response.Status, null);
                 }
@@ -219,12 +335,125 @@ namespace WakaSDK
                 }
                 else
                 {
-                    andThen("failed: unknown response type", null);
+                    andThen(StatusUnknownResponse, null);
                 }
             }
             return true;
         }
 
+        /// <summary>
+        /// 发送请求, 响应, 超时与断开都会调用 andThen, 返回请求编号
+        /// </summary>
+        static private ulong Request(IMessage request, TimeSpan? timeout, Action<string, object> andThen)
+        {
+            var req = BuildFutureRequest(request);
+            var session = Session;
+            if (session == null)
+            {
+                andThen(StatusNotConnected, null);
+                return req.Number;
+            }
+            lock (ThenTable)
+            {
+                ThenTable.Add(req.Number, new PendingRequest
+                {
+                    AndThen = andThen,
+                    Deadline = DateTime.UtcNow + (timeout ?? RequestTimeout),
+                });
+            }
+            session.Send(req);
+            return req.Number;
+        }
+
+#if WAKA_ASYNC
+        /// <summary>
+        /// 以 Task 发送请求, 只在 WakaSDK.Async (.NET 4.6) 中提供
+        /// </summary>
+        static private Task<T> RequestAsync<T>(IMessage request, TimeSpan? timeout, CancellationToken cancellationToken) where T : class
+        {
+            var source = new TaskCompletionSource<T>();
+            if (cancellationToken.IsCancellationRequested)
+            {
+                source.SetCanceled();
+                return source.Task;
+            }
+
+            var number = Request(request, timeout, (status, x) =>
+            {
+                var response = x as T;
+                if (status != StatusSuccess)
+                {
+                    source.TrySetException(new FutureException(status));
+                }
+                else if (response == null)
+                {
+                    source.TrySetException(new FutureException(StatusUnknownResponse));
+                }
+                else
+                {
+                    source.TrySetResult(response);
+                }
+            });
+
+            if (cancellationToken.CanBeCanceled)
+            {
+                var registration = cancellationToken.Register(() =>
+                {
+                    TakePending(number);
+                    source.TrySetCanceled();
+                });
+                source.Task.ContinueWith(t => registration.Dispose());
+            }
+            return source.Task;
+        }
+#endif
+
+        static private Action<string, object> TakePending(ulong number)
+        {
+            lock (ThenTable)
+            {
+                if (!ThenTable.TryGetValue(number, out PendingRequest pending))
+                {
+                    return null;
+                }
+                ThenTable.Remove(number);
+                return pending.AndThen;
+            }
+        }
+
+        static private void RejectAll(string status)
+        {
+            List<PendingRequest> rejected;
+            lock (ThenTable)
+            {
+                rejected = ThenTable.Values.ToList();
+                ThenTable.Clear();
+            }
+            foreach (var pending in rejected)
+            {
+                pending.AndThen(status, null);
+            }
+        }
+
+        static private void ExpirePending()
+        {
+            var now = DateTime.UtcNow;
+            List<PendingRequest> expired;
+            lock (ThenTable)
+            {
+                var numbers = ThenTable.Where(x => x.Value.Deadline <= now).Select(x => x.Key).ToList();
+                expired = numbers.Select(x => ThenTable[x]).ToList();
+                foreach (var number in numbers)
+                {
+                    ThenTable.Remove(number);
+                }
+            }
+            foreach (var pending in expired)
+            {
+                pending.AndThen(StatusTimeout, null);
+            }
+        }
+
         static private bool RedirectTransport(ISession ses, uint id, IMessage message, byte[] rawData)
         {
             return DispatchTransport(ses, id, message, rawData);
@@ -268,7 +497,9 @@ namespace WakaSDK
         static Supervisor()
         {
             WakaProto.WakaProtoMetaProvider.RegisterAll();
-            {{.Namespace}}.{{.MetaProviderClassName}}.RegisterAll();
+            {{- range .MetaProviders}}
+            {{.Namespace}}.{{.ClassName}}.RegisterAll();
+            {{- end}}
 
             Evq = new EventQueue();
             Callback = new Callback()
@@ -280,9 +511,770 @@ namespace WakaSDK
                 .RegisterMessage(new WakaProto.Heart().GetType(), RedirectHeart);
             Connector = new Connector(Evq, Callback);
 
-            ThenTable = new Dictionary<ulong, Action<string, object>>();
+            ThenTable = new Dictionary<ulong, PendingRequest>();
         }
     }
 }
 
 `
+
+const GoHandlerTemplate = `// Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka
+// Source: {{.FileName}}
+// DO NOT EDIT!!!
+
+package {{.GoPackage}}
+
+import (
+	{{- if .Validators}}
+	"fmt"
+	{{- end}}
+	{{- if .ImportUTF8}}
+	"unicode/utf8"
+	{{- end}}
+{{if .Validators}}
+{{end}}
+	"github.com/golang/protobuf/proto"
+	{{- range .Imports}}
+
+	{{.Alias}} "{{.Path}}"
+	{{- end}}
+)
+{{range .Targets}}
+// {{.Name}} 处理的客户端推送
+type {{.Title}}TransportHandler interface {
+	{{- range $i, $post := .Post}}
+	{{- if $i}}
+{{end}}
+	{{- range $post.Comments}}
+	// {{.}}
+	{{- else}}
+	// 没有注释
+	{{- end}}
+	{{$post.Type}}(player uint64, ev *{{$post.Type}})
+	{{- end}}
+}
+
+// {{.Name}} 处理的 RPC 请求
+type {{.Title}}FutureHandler interface {
+	{{- range $i, $rpc := .RPC}}
+	{{- if $i}}
+{{end}}
+	{{- range $rpc.Comments}}
+	// {{.}}
+	{{- else}}
+	// 没有注释
+	{{- end}}
+	{{$rpc.InputType}}(player uint64, request *{{$rpc.InputType}}, respond func(*{{$rpc.OutputType}}, error))
+	{{- end}}
+}
+
+// 分发客户端推送到 {{.Name}}, 不属于 {{.Name}} 的消息返回 false
+func Dispatch{{.Title}}Transport(handler {{.Title}}TransportHandler, player uint64, payload proto.Message) bool {
+	{{- if .Post}}
+	switch ev := payload.(type) {
+	{{- range .Post}}
+	case *{{.Type}}:
+		handler.{{.Type}}(player, ev)
+	{{- end}}
+	default:
+		return false
+	}
+	return true
+	{{- else}}
+	return false
+	{{- end}}
+}
+
+// 分发 RPC 请求到 {{.Name}}, 不属于 {{.Name}} 的消息返回 false
+func Dispatch{{.Title}}Future(handler {{.Title}}FutureHandler, player uint64, payload proto.Message, respond func(proto.Message, error)) bool {
+	{{- if .RPC}}
+	switch ev := payload.(type) {
+	{{- range .RPC}}
+	case *{{.InputType}}:
+		handler.{{.InputType}}(player, ev, func(response *{{.OutputType}}, err error) {
+			if err != nil || response == nil {
+				respond(nil, err)
+				return
+			}
+			respond(response, nil)
+		})
+	{{- end}}
+	default:
+		return false
+	}
+	return true
+	{{- else}}
+	return false
+	{{- end}}
+}
+{{end}}
+{{- range .Validators}}
+// Validate 按 @validate 规则校验 {{.Name}}
+func (m *{{.Type}}) Validate() error {
+	if m == nil {
+		return nil
+	}
+	{{- range .Fields}}
+	{{.Code}}
+	{{- end}}
+	return nil
+}
+{{end}}`
+
+const TSSupervisorTemplate = `// Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka
+// DO NOT EDIT!!!
+
+import { waka_proto } from "{{.TSImport}}";
+
+/**
+ * 消息元数据
+ */
+export interface MessageMeta {
+    id: number;
+    name: string;
+    decode(data: Uint8Array): any;
+}
+
+/**
+ * 消息元数据表
+ */
+export class MetaTable {
+    private static byID: { [id: number]: MessageMeta } = {};
+    private static byName: { [name: string]: MessageMeta } = {};
+
+    /**
+     * 注册消息
+     */
+    static register(meta: MessageMeta): void {
+        MetaTable.byID[meta.id] = meta;
+        MetaTable.byName[meta.name] = meta;
+    }
+
+    /**
+     * 按消息 ID 查找
+     */
+    static getByID(id: number): MessageMeta | undefined {
+        return MetaTable.byID[id];
+    }
+
+    /**
+     * 按消息全名查找
+     */
+    static getByName(name: string): MessageMeta | undefined {
+        return MetaTable.byName[name];
+    }
+
+    /**
+     * 取消息 ID, 未注册时抛出异常
+     */
+    static idOf(name: string): number {
+        const meta = MetaTable.byName[name];
+        if (meta === undefined) {
+            throw new Error("unknown message: " + name);
+        }
+        return meta.id;
+    }
+}
+{{range .CoreMessages}}
+MetaTable.register({ id: {{.ID}}, name: "{{.Name}}", decode: (d: Uint8Array) => {{.Name}}.decode(d) });
+{{- end}}
+
+/**
+ * 封包
+ */
+export interface Frame {
+    id: number;
+    data: Uint8Array;
+}
+
+/**
+ * 写 TLV 封包: 消息 ID (4 字节) + 负载长度 (4 字节) + 负载, 小端
+ */
+export function encodeFrame(id: number, data: Uint8Array): Uint8Array {
+    const frame = new Uint8Array(8 + data.length);
+    const view = new DataView(frame.buffer);
+    view.setUint32(0, id, true);
+    view.setUint32(4, data.length, true);
+    frame.set(data, 8);
+    return frame;
+}
+
+/**
+ * 读 TLV 封包, 处理粘包与半包
+ */
+export class FrameReader {
+    private buffer = new Uint8Array(0);
+
+    /**
+     * 写入收到的数据, 返回其中全部完整的封包
+     */
+    feed(data: Uint8Array): Frame[] {
+        const merged = new Uint8Array(this.buffer.length + data.length);
+        merged.set(this.buffer, 0);
+        merged.set(data, this.buffer.length);
+
+        const frames: Frame[] = [];
+        const view = new DataView(merged.buffer);
+        let offset = 0;
+        while (merged.length - offset >= 8) {
+            const id = view.getUint32(offset, true);
+            const size = view.getUint32(offset + 4, true);
+            if (merged.length - offset - 8 < size) {
+                break;
+            }
+            frames.push({ id: id, data: merged.slice(offset + 8, offset + 8 + size) });
+            offset += 8 + size;
+        }
+        this.buffer = merged.slice(offset);
+        return frames;
+    }
+}
+
+/**
+ * 连接事件回调
+ */
+export interface CoreCallback {
+    connected(): void;
+    connectFailed(): void;
+    closed(): void;
+    transport(id: number, payload: Uint8Array): void;
+}
+
+/**
+ * RPC 回调, status 为 "success" 时 message 有效
+ */
+export type AndThen = (status: string, message: any) => void;
+
+function toNumber(n: number | { toNumber(): number }): number {
+    return typeof n === "number" ? n : n.toNumber();
+}
+
+/**
+ * 连接, 心跳与 RPC 管理
+ *
+ * 连接网关的 WebSocket 监听地址 (配置 websocket/websocket4), 每个二进制帧承载一个 TLV 封包
+ * 网关的 TCP 地址不能直接连接
+ */
+export class CoreSupervisor {
+    private socket: WebSocket | null = null;
+    private opened = false;
+    private reader = new FrameReader();
+    private thenTable: { [number: number]: AndThen } = {};
+    private number = 1;
+    private lastRemoteHeartTime = 0;
+    private lastLocalHeartTime = 0;
+    private timer: any = null;
+
+    constructor(private callback: CoreCallback) {
+    }
+
+    /**
+     * 连接服务器, url 为网关的 WebSocket 地址, 例如 ws://127.0.0.1:30013/
+     */
+    connect(url: string): void {
+        this.close();
+
+        const socket = new WebSocket(url);
+        socket.binaryType = "arraybuffer";
+        socket.onopen = () => {
+            if (this.socket !== socket) {
+                return;
+            }
+            this.opened = true;
+            this.lastRemoteHeartTime = Date.now();
+            this.lastLocalHeartTime = Date.now();
+            this.timer = setInterval(() => this.heart(), 1000);
+            this.callback.connected();
+        };
+        socket.onclose = () => {
+            if (this.socket !== socket) {
+                return;
+            }
+            const opened = this.opened;
+            this.reset();
+            if (opened) {
+                this.callback.closed();
+            } else {
+                this.callback.connectFailed();
+            }
+        };
+        socket.onmessage = (ev: MessageEvent) => {
+            if (this.socket !== socket) {
+                return;
+            }
+            this.receive(new Uint8Array(ev.data as ArrayBuffer));
+        };
+        this.socket = socket;
+    }
+
+    /**
+     * 关闭连接, 未完成的 RPC 以失败结束
+     */
+    close(): void {
+        const socket = this.socket;
+        if (socket === null) {
+            return;
+        }
+        const opened = this.opened;
+        this.reset();
+        socket.close();
+        if (opened) {
+            this.callback.closed();
+        }
+    }
+
+    /**
+     * 发起 RPC 请求
+     */
+    request(name: string, payload: Uint8Array, andThen: AndThen): void {
+        if (!this.opened) {
+            andThen("failed: not connected", null);
+            return;
+        }
+        const number = ++this.number;
+        this.thenTable[number] = andThen;
+        this.send("waka_proto.FutureRequest", waka_proto.FutureRequest.encode({
+            id: MetaTable.idOf(name),
+            payload: payload,
+            number: number,
+        }).finish());
+    }
+
+    /**
+     * 推送消息
+     */
+    post(name: string, payload: Uint8Array): void {
+        this.send("waka_proto.Transport", waka_proto.Transport.encode({
+            id: MetaTable.idOf(name),
+            payload: payload,
+        }).finish());
+    }
+
+    private send(name: string, data: Uint8Array): void {
+        if (this.socket === null || !this.opened) {
+            return;
+        }
+        this.socket.send(encodeFrame(MetaTable.idOf(name), data));
+    }
+
+    private reset(): void {
+        if (this.timer !== null) {
+            clearInterval(this.timer);
+            this.timer = null;
+        }
+        this.socket = null;
+        this.opened = false;
+        this.reader = new FrameReader();
+
+        const thenTable = this.thenTable;
+        this.thenTable = {};
+        Object.keys(thenTable).forEach((number: string) => thenTable[Number(number)]("failed: closed", null));
+    }
+
+    private heart(): void {
+        if (Date.now() - this.lastRemoteHeartTime >= 10000) {
+            this.close();
+            return;
+        }
+        if (Date.now() - this.lastLocalHeartTime >= 3000) {
+            this.send("waka_proto.Heart", waka_proto.Heart.encode({}).finish());
+            this.lastLocalHeartTime = Date.now();
+        }
+    }
+
+    private receive(data: Uint8Array): void {
+        for (const frame of this.reader.feed(data)) {
+            const meta = MetaTable.getByID(frame.id);
+            if (meta === undefined) {
+                continue;
+            }
+            switch (meta.name) {
+                case "waka_proto.Heart":
+                    this.lastRemoteHeartTime = Date.now();
+                    break;
+                case "waka_proto.Transport": {
+                    const transport = waka_proto.Transport.decode(frame.data);
+                    this.callback.transport(transport.id, transport.payload);
+                    break;
+                }
+                case "waka_proto.FutureResponse":
+                    this.redirectFutureResponse(waka_proto.FutureResponse.decode(frame.data));
+                    break;
+                default:
+                    break;
+            }
+            if (this.socket === null) {
+                return;
+            }
+        }
+    }
+
+    private redirectFutureResponse(response: waka_proto.FutureResponse): void {
+        const number = toNumber(response.number);
+        const andThen = this.thenTable[number];
+        if (andThen === undefined) {
+            return;
+        }
+        delete this.thenTable[number];
+
+        if (response.status !== "success") {
+            andThen(response.status, null);
+            return;
+        }
+        const meta = MetaTable.getByID(response.id);
+        if (meta === undefined) {
+            andThen("failed: unknown response type", null);
+            return;
+        }
+        andThen(response.status, meta.decode(response.payload));
+    }
+}
+`
+
+const TSMetaProviderTemplate = `// Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka
+// Source: {{.FileName}}
+// DO NOT EDIT!!!
+
+import { {{.Package}} } from "{{.TSImport}}";
+import { MetaTable } from "./CoreSupervisor";
+
+/**
+ * {{.FileName}} 元数据提供者
+ */
+export class {{.ClassName}} {
+    /**
+     * 注册消息
+     */
+    static registerAll(): void {
+        {{- range .Messages}}
+        MetaTable.register({ id: {{.ID}}, name: "{{.Name}}", decode: (d: Uint8Array) => {{.Name}}.decode(d) });
+        {{- end}}
+    }
+}
+`
+
+const TSIDispatcherTemplate = `// Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka
+// DO NOT EDIT!!!
+
+import { {{.TSPackages}} } from "{{.TSImport}}";
+
+/**
+ * 消息分发器接口
+ */
+export interface IDispatcher {
+    /**
+     * 连接建立
+     */
+    connected(): void;
+
+    /**
+     * 连接失败
+     */
+    connectFailed(): void;
+
+    /**
+     * 连接断开
+     */
+    closed(): void;
+{{range .Receive}}
+    /**
+{{- range .Comments}}
+     * {{.}}
+{{- else}}
+     * 没有注释
+{{- end}}
+     */
+    event{{.Method}}(ev: {{.Ref.TS}}): void;
+{{end}}}
+`
+
+const TSHandlerTemplate = `// Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka
+// Source: {{.FileName}}
+// DO NOT EDIT!!!
+
+import { {{.TSPackages}} } from "{{.TSImport}}";
+import { CoreSupervisor, MetaTable } from "./CoreSupervisor";
+import { IDispatcher } from "./IDispatcher";
+{{- range .MetaProviders}}
+import { {{.ClassName}} } from "./{{.TSFile}}";
+{{- end}}
+{{range .MetaProviders}}
+{{.ClassName}}.registerAll();
+{{- end}}
+
+/**
+ * SDK接口
+ */
+export class Supervisor {
+    private static dispatcher: IDispatcher | null = null;
+    private static core = new CoreSupervisor({
+        connected: () => {
+            if (Supervisor.dispatcher !== null) {
+                Supervisor.dispatcher.connected();
+            }
+        },
+        connectFailed: () => {
+            if (Supervisor.dispatcher !== null) {
+                Supervisor.dispatcher.connectFailed();
+            }
+        },
+        closed: () => {
+            if (Supervisor.dispatcher !== null) {
+                Supervisor.dispatcher.closed();
+            }
+        },
+        transport: (id: number, payload: Uint8Array) => Supervisor.dispatchTransport(id, payload),
+    });
+
+    /**
+     * 设置推送消息处理器
+     */
+    static setDispatcher(dispatcher: IDispatcher): void {
+        Supervisor.dispatcher = dispatcher;
+    }
+
+    /**
+     * 连接服务器
+     */
+    static connect(url: string): void {
+        Supervisor.core.connect(url);
+    }
+
+    /**
+     * 关闭连接
+     */
+    static close(): void {
+        Supervisor.core.close();
+    }
+{{range .RPC}}
+    /**
+{{- range .Comments}}
+     * {{.}}
+{{- else}}
+     * 没有注释
+{{- end}}
+     */
+    static request{{.Method}}(request: {{.Input.TSInterface}}, andThen: (status: string, response: {{.Output.TS}} | null) => void): void {
+        Supervisor.core.request("{{.Input.Name}}", {{.Input.TS}}.encode(request).finish(), andThen);
+    }
+{{end}}
+{{- range .Post}}
+    /**
+{{- range .Comments}}
+     * {{.}}
+{{- else}}
+     * 没有注释
+{{- end}}
+     */
+    static post{{.Method}}(request: {{.Ref.TSInterface}}): void {
+        Supervisor.core.post("{{.Ref.Name}}", {{.Ref.TS}}.encode(request).finish());
+    }
+{{end}}
+    private static dispatchTransport(id: number, payload: Uint8Array): void {
+        const dispatcher = Supervisor.dispatcher;
+        if (dispatcher === null) {
+            return;
+        }
+        const meta = MetaTable.getByID(id);
+        if (meta === undefined) {
+            throw new Error("unknown message");
+        }
+        const message = meta.decode(payload);
+        switch (meta.name) {
+            {{- range .Receive}}
+            case "{{.Ref.Name}}":
+                dispatcher.event{{.Method}}(message as {{.Ref.TS}});
+                break;
+            {{- end}}
+            default:
+                break;
+        }
+    }
+}
+`
+
+const MarkdownDocTemplate = `<!-- Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka -->
+<!-- DO NOT EDIT!!! -->
+
+# 接口文档
+{{define "fields"}}
+{{- if .Fields}}
+| 字段 | 类型 | 编号 | 说明 |
+| --- | --- | --- | --- |
+{{- range .Fields}}
+| {{.Name}} | {{if .Label}}{{.Label}} {{end}}{{if .Link}}[{{.Type}}](#{{.Link}}){{else}}{{code .Type}}{{end}} | {{.Number}} | {{cell .Comments}} |
+{{- end}}
+{{- else}}
+没有字段
+{{- end}}
+{{end}}
+{{- define "comments"}}
+{{- range .}}
+{{.}}
+{{- else}}
+没有注释
+{{- end}}
+{{end}}
+{{- range .Packages}}
+## {{.Name}}
+
+来源: {{range $i, $file := .Files}}{{if $i}}, {{end}}{{$file}}{{end}}
+{{- if .RPC}}
+
+### RPC 请求
+{{range .RPC}}
+#### {{.Name}}
+{{template "comments" .Comments}}
+处理者: {{.Target}}
+
+<a name="{{.Request.Name}}"></a>请求 ` + "`{{.Request.Name}}`" + ` (ID {{.Request.ID}})
+{{template "fields" .Request}}
+<a name="{{.Response.Name}}"></a>响应 ` + "`{{.Response.Name}}`" + ` (ID {{.Response.ID}})
+{{template "fields" .Response}}
+{{- end}}
+{{- end}}
+{{- if .Post}}
+
+### 客户端推送
+{{range .Post}}
+<a name="{{.Name}}"></a>
+#### {{.Name}}
+
+ID {{.ID}}, 处理者: {{.Target}}
+{{template "comments" .Comments}}{{template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Receive}}
+
+### 服务器推送
+{{range .Receive}}
+<a name="{{.Name}}"></a>
+#### {{.Name}}
+
+ID {{.ID}}
+{{template "comments" .Comments}}{{template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Others}}
+
+### 其它消息
+{{range .Others}}
+<a name="{{.Name}}"></a>
+#### {{.Name}}
+
+ID {{.ID}}
+{{template "comments" .Comments}}{{template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+
+### 枚举
+{{range .Enums}}
+<a name="{{.Name}}"></a>
+#### {{.Name}}
+{{template "comments" .Comments}}
+| 名称 | 值 | 说明 |
+| --- | --- | --- |
+{{- range .Values}}
+| {{.Name}} | {{.Number}} | {{cell .Comments}} |
+{{- end}}
+{{end}}
+{{- end}}
+{{end}}`
+
+const HTMLDocHeader = `<!DOCTYPE html>
+<!-- Generated by github.com/liuhan907/waka/protoc/protoc-gen-waka -->
+<!-- DO NOT EDIT!!! -->
+`
+
+const HTMLDocTemplate = `<html>
+<head>
+<meta charset="utf-8">
+<title>接口文档</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+code { background: #f4f4f4; padding: 0 4px; }
+</style>
+</head>
+<body>
+<h1>接口文档</h1>
+{{- define "fields"}}
+{{- if .Fields}}
+<table>
+<tr><th>字段</th><th>类型</th><th>编号</th><th>说明</th></tr>
+{{- range .Fields}}
+<tr><td>{{.Name}}</td><td>{{if .Label}}{{.Label}} {{end}}{{if .Link}}<a href="#{{.Link}}">{{.Type}}</a>{{else}}{{.Type}}{{end}}</td><td>{{.Number}}</td><td>{{range $i, $c := .Comments}}{{if $i}}<br>{{end}}{{$c}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>没有字段</p>
+{{- end}}
+{{- end}}
+{{- define "comments"}}
+{{- range .}}
+<p>{{.}}</p>
+{{- else}}
+<p>没有注释</p>
+{{- end}}
+{{- end}}
+{{- range .Packages}}
+<h2>{{.Name}}</h2>
+<p>来源: {{range $i, $file := .Files}}{{if $i}}, {{end}}{{$file}}{{end}}</p>
+{{- if .RPC}}
+<h3>RPC 请求</h3>
+{{- range .RPC}}
+<h4>{{.Name}}</h4>
+{{- template "comments" .Comments}}
+<p>处理者: {{.Target}}</p>
+<p id="{{.Request.Name}}">请求 <code>{{.Request.Name}}</code> (ID {{.Request.ID}})</p>
+{{- template "fields" .Request}}
+<p id="{{.Response.Name}}">响应 <code>{{.Response.Name}}</code> (ID {{.Response.ID}})</p>
+{{- template "fields" .Response}}
+{{- end}}
+{{- end}}
+{{- if .Post}}
+<h3>客户端推送</h3>
+{{- range .Post}}
+<h4 id="{{.Name}}">{{.Name}}</h4>
+<p>ID {{.ID}}, 处理者: {{.Target}}</p>
+{{- template "comments" .Comments}}
+{{- template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Receive}}
+<h3>服务器推送</h3>
+{{- range .Receive}}
+<h4 id="{{.Name}}">{{.Name}}</h4>
+<p>ID {{.ID}}</p>
+{{- template "comments" .Comments}}
+{{- template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Others}}
+<h3>其它消息</h3>
+{{- range .Others}}
+<h4 id="{{.Name}}">{{.Name}}</h4>
+<p>ID {{.ID}}</p>
+{{- template "comments" .Comments}}
+{{- template "fields" .}}
+{{- end}}
+{{- end}}
+{{- if .Enums}}
+<h3>枚举</h3>
+{{- range .Enums}}
+<h4 id="{{.Name}}">{{.Name}}</h4>
+{{- template "comments" .Comments}}
+<table>
+<tr><th>名称</th><th>值</th><th>说明</th></tr>
+{{- range .Values}}
+<tr><td>{{.Name}}</td><td>{{.Number}}</td><td>{{range $i, $c := .Comments}}{{if $i}}<br>{{end}}{{$c}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`
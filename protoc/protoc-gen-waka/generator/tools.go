@@ -6,10 +6,19 @@ func parameters(parameter string) map[string]string {
 	parameters := make(map[string]string)
 	for _, p := range strings.Split(parameter, ",") {
 		if i := strings.Index(p, "="); i < 0 {
-			parameters[p] = ""
+			parameters[strings.TrimSpace(p)] = ""
 		} else {
-			parameters[p[0:i]] = p[i+1:]
+			parameters[strings.TrimSpace(p[0:i])] = strings.TrimSpace(p[i+1:])
 		}
 	}
 	return parameters
 }
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
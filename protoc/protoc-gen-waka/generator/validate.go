@@ -0,0 +1,280 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+
+	"github.com/liuhan907/waka/protoc/plugin"
+)
+
+// 字段校验规则:
+//
+//	min, max          数值下限与上限, repeated 字段校验每个元素
+//	len, min_len, max_len
+//	                  string 按字符数, bytes 按字节数, repeated 与 map 按元素个数
+//	in                允许的取值, 以 | 分隔, repeated 字段校验每个元素
+//	required          string 与 bytes 不为空, 消息不为 nil, repeated 与 map 至少一个元素
+var validateRules = []string{"min", "max", "len", "min_len", "max_len", "in", "required"}
+
+type ValidateRule struct {
+	Key   string
+	Value string
+}
+
+type FieldValidator struct {
+	Code string
+}
+
+type ValidatorDescriptor struct {
+	Type   string
+	Name   string
+	Fields []*FieldValidator
+}
+
+// 解析字段注释中的 @validate, 参数以空格或逗号分隔
+func parseValidate(location *descriptor.SourceCodeInfo_Location) []*ValidateRule {
+	var rules []*ValidateRule
+	for _, a := range parseAnnotations(location) {
+		if a.name != "@validate" {
+			continue
+		}
+		rules = append(rules, splitValidate(a.rest)...)
+	}
+	return rules
+}
+
+func splitValidate(s string) []*ValidateRule {
+	var rules []*ValidateRule
+	for _, token := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	}) {
+		rule := &ValidateRule{Key: token}
+		if i := strings.Index(token, "="); i >= 0 {
+			rule.Key, rule.Value = token[:i], token[i+1:]
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func isRepeated(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+}
+
+func isNumeric(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL,
+		descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	}
+	return true
+}
+
+func isFloat(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_FLOAT ||
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_DOUBLE
+}
+
+func isUnsigned(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return true
+	}
+	return false
+}
+
+func isMessage(field *descriptor.FieldDescriptorProto) bool {
+	return field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE
+}
+
+// 检查一条规则能否用于字段, 不能时返回原因
+func checkValidateRule(field *descriptor.FieldDescriptorProto, rule *ValidateRule) string {
+	if !contains(validateRules, rule.Key) {
+		return fmt.Sprintf("unknown rule %q", rule.Key)
+	}
+
+	str := field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING
+	bytes := field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES
+
+	switch rule.Key {
+	case "required":
+		if rule.Value != "" {
+			return "required takes no value"
+		}
+		if !isRepeated(field) && !str && !bytes && !isMessage(field) {
+			return "required only applies to string, bytes, message or repeated field"
+		}
+	case "min", "max":
+		if isMessage(field) || !isNumeric(field) {
+			return fmt.Sprintf("%s only applies to numeric field", rule.Key)
+		}
+		if reason := checkNumber(field, rule.Value); reason != "" {
+			return fmt.Sprintf("%s %s", rule.Key, reason)
+		}
+	case "len", "min_len", "max_len":
+		if !isRepeated(field) && !str && !bytes {
+			return fmt.Sprintf("%s only applies to string, bytes or repeated field", rule.Key)
+		}
+		if n, err := strconv.ParseUint(rule.Value, 10, 32); err != nil || n > 1<<31-1 {
+			return fmt.Sprintf("%s must be a non-negative integer", rule.Key)
+		}
+	case "in":
+		if rule.Value == "" {
+			return "in requires values"
+		}
+		if isNumeric(field) && !isMessage(field) {
+			for _, value := range strings.Split(rule.Value, "|") {
+				if reason := checkNumber(field, value); reason != "" {
+					return fmt.Sprintf("in %s", reason)
+				}
+			}
+		} else if !str {
+			return "in only applies to numeric or string field"
+		}
+	}
+	return ""
+}
+
+func checkNumber(field *descriptor.FieldDescriptorProto, value string) string {
+	var err error
+	switch {
+	case isFloat(field):
+		_, err = strconv.ParseFloat(value, 64)
+	case isUnsigned(field):
+		_, err = strconv.ParseUint(value, 10, 64)
+	default:
+		_, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return fmt.Sprintf("value %q is not valid for %s", value, scalarTypes[field.GetType()])
+	}
+	return ""
+}
+
+// 消息是否需要生成校验, 自身字段有规则或包含需要校验的消息字段
+func (g *Generator) needValidate(name string, visiting map[string]bool) bool {
+	if visiting[name] {
+		return false
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	f, message := g.FindMessage(name)
+	if message == nil || message.Descriptor.GetOptions().GetMapEntry() {
+		return false
+	}
+	for i, field := range message.Descriptor.GetField() {
+		if len(parseValidate(f.Locations[fmt.Sprintf("%s,2,%d", message.Path, i)])) > 0 {
+			return true
+		}
+		if isMessage(field) && g.needValidate(strings.TrimPrefix(field.GetTypeName(), "."), visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// 生成文件中所有需要校验的消息
+func (g *Generator) buildValidators(f *plugin.FileDescriptor) []*ValidatorDescriptor {
+	var validators []*ValidatorDescriptor
+	for _, message := range f.MessageType {
+		if !g.needValidate(fullName(f, message.Name), make(map[string]bool)) {
+			continue
+		}
+
+		v := &ValidatorDescriptor{
+			Type: gogen.CamelCaseSlice(message.Name),
+			Name: strings.Join(message.Name, "."),
+		}
+		for i, field := range message.Descriptor.GetField() {
+			rules := parseValidate(f.Locations[fmt.Sprintf("%s,2,%d", message.Path, i)])
+			nested := isMessage(field) && g.needValidate(strings.TrimPrefix(field.GetTypeName(), "."), make(map[string]bool))
+			code := validateField(v.Name+"."+field.GetName(), field, rules, nested)
+			if code != "" && field.OneofIndex != nil {
+				oneof := message.Descriptor.GetOneofDecl()[field.GetOneofIndex()]
+				code = fmt.Sprintf("if _, ok := m.Get%s().(*%s_%s); ok {\n%s\n}",
+					gogen.CamelCase(oneof.GetName()), v.Type, gogen.CamelCase(field.GetName()), code)
+			}
+			if code != "" {
+				v.Fields = append(v.Fields, &FieldValidator{Code: code})
+			}
+		}
+		validators = append(validators, v)
+	}
+	return validators
+}
+
+// 生成单个字段的校验代码, repeated 字段的元素规则在循环中逐个校验, oneof 字段仅在选中时校验
+func validateField(label string, field *descriptor.FieldDescriptorProto, rules []*ValidateRule, nested bool) string {
+	getter := "m.Get" + gogen.CamelCase(field.GetName()) + "()"
+	value := getter
+	if isRepeated(field) {
+		value = "v"
+	}
+	fail := func(format string, args ...interface{}) string {
+		return fmt.Sprintf("return fmt.Errorf(%s)", strconv.Quote(label+": "+fmt.Sprintf(format, args...)))
+	}
+
+	var whole []string
+	var each []string
+	for _, rule := range rules {
+		switch rule.Key {
+		case "required":
+			if isMessage(field) && !isRepeated(field) {
+				whole = append(whole, fmt.Sprintf("if %s == nil {\n%s\n}", getter, fail("required")))
+			} else {
+				whole = append(whole, fmt.Sprintf("if len(%s) == 0 {\n%s\n}", getter, fail("required")))
+			}
+		case "min":
+			each = append(each, fmt.Sprintf("if %s < %s {\n%s\n}", value, rule.Value, fail("must not be less than %s", rule.Value)))
+		case "max":
+			each = append(each, fmt.Sprintf("if %s > %s {\n%s\n}", value, rule.Value, fail("must not be greater than %s", rule.Value)))
+		case "len", "min_len", "max_len":
+			length := fmt.Sprintf("len(%s)", getter)
+			if !isRepeated(field) && field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING {
+				length = fmt.Sprintf("utf8.RuneCountInString(%s)", getter)
+			}
+			op, text := "!=", "length must be %s"
+			if rule.Key == "min_len" {
+				op, text = "<", "length must not be less than %s"
+			} else if rule.Key == "max_len" {
+				op, text = ">", "length must not be greater than %s"
+			}
+			whole = append(whole, fmt.Sprintf("if %s %s %s {\n%s\n}", length, op, rule.Value, fail(text, rule.Value)))
+		case "in":
+			values := strings.Split(rule.Value, "|")
+			cases := values
+			if field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING {
+				cases = nil
+				for _, v := range values {
+					cases = append(cases, strconv.Quote(v))
+				}
+			}
+			each = append(each, fmt.Sprintf("switch %s {\ncase %s:\ndefault:\n%s\n}", value, strings.Join(cases, ", "), fail("must be one of %s", strings.Join(values, ", "))))
+		}
+	}
+
+	if nested {
+		each = append(each, fmt.Sprintf("if err := %s.Validate(); err != nil {\nreturn fmt.Errorf(\"%s: %%v\", err)\n}", value, label))
+	}
+
+	code := whole
+	if len(each) > 0 {
+		if isRepeated(field) {
+			code = append(code, fmt.Sprintf("for _, v := range %s {\n%s\n}", getter, strings.Join(each, "\n")))
+		} else {
+			code = append(code, each...)
+		}
+	}
+	return strings.Join(code, "\n")
+}
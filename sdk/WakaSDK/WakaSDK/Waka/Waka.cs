@@ -28,14 +28,18 @@ namespace WakaProto {
             "dBIKCgJpZBgBIAEoBxIPCgdwYXlsb2FkGAIgASgMIjwKDUZ1dHVyZVJlcXVl",
             "c3QSCgoCaWQYASABKAcSDwoHcGF5bG9hZBgCIAEoDBIOCgZudW1iZXIYAyAB",
             "KAQiTQoORnV0dXJlUmVzcG9uc2USDgoGc3RhdHVzGAEgASgJEgoKAmlkGAIg",
-            "ASgHEg8KB3BheWxvYWQYAyABKAwSDgoGbnVtYmVyGAQgASgEYgZwcm90bzM="));
+            "ASgHEg8KB3BheWxvYWQYAyABKAwSDgoGbnVtYmVyGAQgASgEIhoKCU5lZ290",
+            "aWF0ZRINCgVjb2RlYxgBIAEoCSIbCgpOZWdvdGlhdGVkEg0KBWNvZGVjGAEg",
+            "ASgJYgZwcm90bzM="));
       descriptor = pbr::FileDescriptor.FromGeneratedCode(descriptorData,
           new pbr::FileDescriptor[] { },
           new pbr::GeneratedClrTypeInfo(null, new pbr::GeneratedClrTypeInfo[] {
             new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.Heart), global::WakaProto.Heart.Parser, null, null, null, null),
             new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.Transport), global::WakaProto.Transport.Parser, new[]{ "Id", "Payload" }, null, null, null),
             new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.FutureRequest), global::WakaProto.FutureRequest.Parser, new[]{ "Id", "Payload", "Number" }, null, null, null),
-            new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.FutureResponse), global::WakaProto.FutureResponse.Parser, new[]{ "Status", "Id", "Payload", "Number" }, null, null, null)
+            new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.FutureResponse), global::WakaProto.FutureResponse.Parser, new[]{ "Status", "Id", "Payload", "Number" }, null, null, null),
+            new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.Negotiate), global::WakaProto.Negotiate.Parser, new[]{ "Codec" }, null, null, null),
+            new pbr::GeneratedClrTypeInfo(typeof(global::WakaProto.Negotiated), global::WakaProto.Negotiated.Parser, new[]{ "Codec" }, null, null, null)
           }));
     }
     #endregion
@@ -737,6 +741,278 @@ namespace WakaProto {
 
   }
 
+  /// <summary>
+  /// 负载编码协商, 只能在连接建立后首个传输之前发送
+  /// </summary>
+  public sealed partial class Negotiate : pb::IMessage<Negotiate> {
+    private static readonly pb::MessageParser<Negotiate> _parser = new pb::MessageParser<Negotiate>(() => new Negotiate());
+    private pb::UnknownFieldSet _unknownFields;
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public static pb::MessageParser<Negotiate> Parser { get { return _parser; } }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public static pbr::MessageDescriptor Descriptor {
+      get { return global::WakaProto.WakaReflection.Descriptor.MessageTypes[4]; }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    pbr::MessageDescriptor pb::IMessage.Descriptor {
+      get { return Descriptor; }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiate() {
+      OnConstruction();
+    }
+
+    partial void OnConstruction();
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiate(Negotiate other) : this() {
+      codec_ = other.codec_;
+      _unknownFields = pb::UnknownFieldSet.Clone(other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiate Clone() {
+      return new Negotiate(this);
+    }
+
+    /// <summary>Field number for the "codec" field.</summary>
+    public const int CodecFieldNumber = 1;
+    private string codec_ = "";
+    /// <summary>
+    /// 编码名称
+    /// protobuf     默认
+    /// json         调试用
+    /// </summary>
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public string Codec {
+      get { return codec_; }
+      set {
+        codec_ = pb::ProtoPreconditions.CheckNotNull(value, "value");
+      }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override bool Equals(object other) {
+      return Equals(other as Negotiate);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public bool Equals(Negotiate other) {
+      if (ReferenceEquals(other, null)) {
+        return false;
+      }
+      if (ReferenceEquals(other, this)) {
+        return true;
+      }
+      if (Codec != other.Codec) return false;
+      return Equals(_unknownFields, other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override int GetHashCode() {
+      int hash = 1;
+      if (Codec.Length != 0) hash ^= Codec.GetHashCode();
+      if (_unknownFields != null) {
+        hash ^= _unknownFields.GetHashCode();
+      }
+      return hash;
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override string ToString() {
+      return pb::JsonFormatter.ToDiagnosticString(this);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void WriteTo(pb::CodedOutputStream output) {
+      if (Codec.Length != 0) {
+        output.WriteRawTag(10);
+        output.WriteString(Codec);
+      }
+      if (_unknownFields != null) {
+        _unknownFields.WriteTo(output);
+      }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public int CalculateSize() {
+      int size = 0;
+      if (Codec.Length != 0) {
+        size += 1 + pb::CodedOutputStream.ComputeStringSize(Codec);
+      }
+      if (_unknownFields != null) {
+        size += _unknownFields.CalculateSize();
+      }
+      return size;
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void MergeFrom(Negotiate other) {
+      if (other == null) {
+        return;
+      }
+      if (other.Codec.Length != 0) {
+        Codec = other.Codec;
+      }
+      _unknownFields = pb::UnknownFieldSet.MergeFrom(_unknownFields, other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void MergeFrom(pb::CodedInputStream input) {
+      uint tag;
+      while ((tag = input.ReadTag()) != 0) {
+        switch(tag) {
+          default:
+            _unknownFields = pb::UnknownFieldSet.MergeFieldFrom(_unknownFields, input);
+            break;
+          case 10: {
+            Codec = input.ReadString();
+            break;
+          }
+        }
+      }
+    }
+
+  }
+
+  /// <summary>
+  /// 负载编码协商结果
+  /// </summary>
+  public sealed partial class Negotiated : pb::IMessage<Negotiated> {
+    private static readonly pb::MessageParser<Negotiated> _parser = new pb::MessageParser<Negotiated>(() => new Negotiated());
+    private pb::UnknownFieldSet _unknownFields;
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public static pb::MessageParser<Negotiated> Parser { get { return _parser; } }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public static pbr::MessageDescriptor Descriptor {
+      get { return global::WakaProto.WakaReflection.Descriptor.MessageTypes[5]; }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    pbr::MessageDescriptor pb::IMessage.Descriptor {
+      get { return Descriptor; }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiated() {
+      OnConstruction();
+    }
+
+    partial void OnConstruction();
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiated(Negotiated other) : this() {
+      codec_ = other.codec_;
+      _unknownFields = pb::UnknownFieldSet.Clone(other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public Negotiated Clone() {
+      return new Negotiated(this);
+    }
+
+    /// <summary>Field number for the "codec" field.</summary>
+    public const int CodecFieldNumber = 1;
+    private string codec_ = "";
+    /// <summary>
+    /// 实际使用的编码名称
+    /// </summary>
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public string Codec {
+      get { return codec_; }
+      set {
+        codec_ = pb::ProtoPreconditions.CheckNotNull(value, "value");
+      }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override bool Equals(object other) {
+      return Equals(other as Negotiated);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public bool Equals(Negotiated other) {
+      if (ReferenceEquals(other, null)) {
+        return false;
+      }
+      if (ReferenceEquals(other, this)) {
+        return true;
+      }
+      if (Codec != other.Codec) return false;
+      return Equals(_unknownFields, other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override int GetHashCode() {
+      int hash = 1;
+      if (Codec.Length != 0) hash ^= Codec.GetHashCode();
+      if (_unknownFields != null) {
+        hash ^= _unknownFields.GetHashCode();
+      }
+      return hash;
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public override string ToString() {
+      return pb::JsonFormatter.ToDiagnosticString(this);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void WriteTo(pb::CodedOutputStream output) {
+      if (Codec.Length != 0) {
+        output.WriteRawTag(10);
+        output.WriteString(Codec);
+      }
+      if (_unknownFields != null) {
+        _unknownFields.WriteTo(output);
+      }
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public int CalculateSize() {
+      int size = 0;
+      if (Codec.Length != 0) {
+        size += 1 + pb::CodedOutputStream.ComputeStringSize(Codec);
+      }
+      if (_unknownFields != null) {
+        size += _unknownFields.CalculateSize();
+      }
+      return size;
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void MergeFrom(Negotiated other) {
+      if (other == null) {
+        return;
+      }
+      if (other.Codec.Length != 0) {
+        Codec = other.Codec;
+      }
+      _unknownFields = pb::UnknownFieldSet.MergeFrom(_unknownFields, other._unknownFields);
+    }
+
+    [global::System.Diagnostics.DebuggerNonUserCodeAttribute]
+    public void MergeFrom(pb::CodedInputStream input) {
+      uint tag;
+      while ((tag = input.ReadTag()) != 0) {
+        switch(tag) {
+          default:
+            _unknownFields = pb::UnknownFieldSet.MergeFieldFrom(_unknownFields, input);
+            break;
+          case 10: {
+            Codec = input.ReadString();
+            break;
+          }
+        }
+      }
+    }
+
+  }
+
   #endregion
 
 }
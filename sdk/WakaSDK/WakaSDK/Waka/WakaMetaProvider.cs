@@ -25,6 +25,10 @@ namespace WakaProto
             
             MetaTable.RegisterMessageMeta("WakaProto.FutureResponse", 1912887258, new WakaProto.FutureResponse().GetType(), (d) => WakaProto.FutureResponse.Parser.ParseFrom(d));
             
+            MetaTable.RegisterMessageMeta("WakaProto.Negotiate", 700413017, new WakaProto.Negotiate().GetType(), (d) => WakaProto.Negotiate.Parser.ParseFrom(d));
+            
+            MetaTable.RegisterMessageMeta("WakaProto.Negotiated", 3856028727, new WakaProto.Negotiated().GetType(), (d) => WakaProto.Negotiated.Parser.ParseFrom(d));
+            
         }
     }
 }
@@ -20,7 +20,7 @@ goto done
 cd %currentPath%
 %currentDisk%
 
-msbuild WakaSDK.sln /t:WakaSDK:Rebuild /p:Configuration=Release;Platform="Any CPU"
+msbuild WakaSDK.sln /t:WakaSDK:Rebuild;WakaSDK_Async:Rebuild /p:Configuration=Release;Platform="Any CPU"
 if ERRORLEVEL 0 goto release
 :failed
 echo "rebuild project failed, now exit"
@@ -39,9 +39,14 @@ copy WakaSDK\bin\Release\Google.Protobuf.pdb Release /Y
 copy WakaSDK\bin\Release\WakaSDK.dll Release\WakaSDK.dll /Y
 copy WakaSDK\bin\Release\WakaSDK.pdb Release\WakaSDK.pdb /Y
 copy WakaSDK\bin\Release\WakaSDK.xml Release\WakaSDK.xml /Y
+copy WakaSDK.Async\bin\Release\WakaSDK.Async.dll Release\WakaSDK.Async.dll /Y
+copy WakaSDK.Async\bin\Release\WakaSDK.Async.pdb Release\WakaSDK.Async.pdb /Y
+copy WakaSDK.Async\bin\Release\WakaSDK.Async.xml Release\WakaSDK.Async.xml /Y
 
 rd /s /q WakaSDK\bin
 rd /s /q WakaSDK\obj
+rd /s /q WakaSDK.Async\bin
+rd /s /q WakaSDK.Async\obj
 
 set PATH=%PATH%;C:\Program Files\7-Zip
 set NOWTIME="WakaSDK_%date:~0,4%.%date:~5,2%.%date:~8,2%-%time:~0,2%.%time:~3,2%.%time:~6,2%"
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+// 归档战绩查询
+//
+//	GET /history/archive/:source/:id            source 为来源表, 如 history_cow, id 为原记录的主键
+//	GET /history/summary/:id?from=2006-01-02&to=2006-01-02
+//
+// from 与 to 均包含在内, 默认为最近 30 天
+func registerArchive(router *gin.Engine) {
+	router.GET("/history/archive/:source/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"Err": "illegal history id"})
+			return
+		}
+
+		history, err := database.QueryArchivedHistory(c.Param("source"), id)
+		switch errors.Cause(err) {
+		case nil:
+			c.JSON(200, history)
+		case database.ErrUnknownArchiveSource:
+			c.JSON(400, gin.H{"Err": err.Error()})
+		case database.ErrArchiveNotFound:
+			c.JSON(404, gin.H{"Err": err.Error()})
+		default:
+			c.JSON(500, gin.H{"Err": err.Error()})
+		}
+	})
+	router.GET("/history/summary/:id", func(c *gin.Context) {
+		player, from, to, ok := reportArgs(c)
+		if !ok {
+			return
+		}
+
+		summaries, err := database.QueryHistorySummaries(player, from, to)
+		if err != nil {
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
+		c.JSON(200, summaries)
+	})
+}
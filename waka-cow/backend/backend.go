@@ -11,6 +11,7 @@ import (
 	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/modules/hall/hall_message"
 	"github.com/liuhan907/waka/waka-cow/proto"
+	"github.com/liuhan907/waka/waka/codec"
 )
 
 var (
@@ -28,6 +29,9 @@ type Option struct {
 	// 创建器
 	TargetCreator TargetCreator
 
+	// 重新加载配置文件中的运行时设置
+	Reload func() error
+
 	// 监听地址
 	Address string
 }
@@ -52,12 +56,34 @@ func Start(option Option) {
 		c.Status(200)
 	})
 	router.GET("/configuration/changed/", func(c *gin.Context) {
-		database.RefreshConfiguration()
+		if err := database.RefreshConfiguration(); err != nil {
+			log.WithFields(logrus.Fields{
+				"err": err,
+			}).Warnln("refresh configuration failed")
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
 
 		log.Debug("configuration changed")
 
 		c.Status(200)
 	})
+	router.GET("/configuration/reload", func(c *gin.Context) {
+		if err := option.Reload(); err != nil {
+			log.WithFields(logrus.Fields{
+				"err": err,
+			}).Warnln("reload settings failed")
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
+
+		log.Debug("settings reloaded")
+
+		c.Status(200)
+	})
+	router.GET("/codec/registry", func(c *gin.Context) {
+		c.JSON(200, codec.Registry())
+	})
 	router.GET("/room/flowing/query", func(c *gin.Context) {
 		ch := make(chan interface{})
 		defer close(ch)
@@ -146,6 +172,10 @@ func Start(option Option) {
 		}
 	})
 
+	registerReport(router)
+	registerFreeze(router)
+	registerArchive(router)
+
 	go func() {
 		err := router.Run(option.Address)
 		if err != nil {
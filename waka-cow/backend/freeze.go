@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+// 恢复失败的冻结记录
+//
+//	GET  /freeze/stuck                               列出启动时恢复失败且仍未恢复的冻结
+//	POST /freeze/resolve/:id  action=refund|discard  退还给玩家或直接作废, 会改动钱, 只接受 POST
+func registerFreeze(router *gin.Engine) {
+	router.GET("/freeze/stuck", func(c *gin.Context) {
+		freezes, err := database.QueryStuckFreezes()
+		if err != nil {
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
+		c.JSON(200, freezes)
+	})
+	router.POST("/freeze/resolve/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"Err": "illegal freeze id"})
+			return
+		}
+		var refund bool
+		switch c.PostForm("action") {
+		case "refund":
+			refund = true
+		case "discard":
+			refund = false
+		default:
+			c.JSON(400, gin.H{"Err": "action must be refund or discard"})
+			return
+		}
+
+		err = database.ResolveStuckFreeze(database.Freeze(id), refund)
+		switch errors.Cause(err) {
+		case nil:
+			c.Status(200)
+		case database.ErrFreezeNotFound:
+			c.JSON(404, gin.H{"Err": err.Error()})
+		case database.ErrFreezeRecovered, database.ErrFreezeNotStuck:
+			c.JSON(409, gin.H{"Err": err.Error()})
+		default:
+			c.JSON(500, gin.H{"Err": err.Error()})
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+const (
+	// 默认统计最近的天数
+	kReportDays = 30
+	// 默认与最大的下线级数
+	kDownlineDepth    = 3
+	kDownlineMaxDepth = 10
+)
+
+// 代理报表
+//
+//	GET /agent/commission/:id?period=day|week|month&from=2006-01-02&to=2006-01-02&format=json|csv
+//	GET /agent/downline/:id?depth=3&from=2006-01-02&to=2006-01-02&format=json|csv
+//
+// from 与 to 均包含在内, 默认为最近 30 天
+func registerReport(router *gin.Engine) {
+	router.GET("/agent/commission/:id", func(c *gin.Context) {
+		agent, from, to, ok := reportArgs(c)
+		if !ok {
+			return
+		}
+		period := database.Period(c.DefaultQuery("period", string(database.PeriodDay)))
+		if _, err := period.Start(from); err != nil {
+			c.JSON(400, gin.H{"Err": err.Error()})
+			return
+		}
+
+		statements, err := database.QueryCommissionStatements(agent, period, from, to)
+		if err != nil {
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(200, statements)
+			return
+		}
+
+		levels := 0
+		for _, statement := range statements {
+			if len(statement.Levels) > levels {
+				levels = len(statement.Levels)
+			}
+		}
+		header := []string{"period"}
+		for i := 1; i <= levels; i++ {
+			header = append(header, fmt.Sprintf("level%d", i))
+		}
+		header = append(header, "total", "count")
+
+		records := [][]string{header}
+		for _, statement := range statements {
+			record := []string{statement.Period.Format("2006-01-02")}
+			for i := 0; i < levels; i++ {
+				var number int64
+				if i < len(statement.Levels) {
+					number = statement.Levels[i]
+				}
+				record = append(record, strconv.FormatInt(number, 10))
+			}
+			record = append(record,
+				strconv.FormatInt(statement.Total, 10),
+				strconv.Itoa(int(statement.Count)))
+			records = append(records, record)
+		}
+		writeCSV(c, fmt.Sprintf("commission_%d_%s.csv", agent, period), records)
+	})
+	router.GET("/agent/downline/:id", func(c *gin.Context) {
+		agent, from, to, ok := reportArgs(c)
+		if !ok {
+			return
+		}
+		depth, err := strconv.Atoi(c.DefaultQuery("depth", strconv.Itoa(kDownlineDepth)))
+		if err != nil || depth <= 0 || depth > kDownlineMaxDepth {
+			c.JSON(400, gin.H{"Err": fmt.Sprintf("depth must be within [1, %d]", kDownlineMaxDepth)})
+			return
+		}
+
+		downline, err := database.QueryDownline(agent, int32(depth), from, to)
+		if err != nil {
+			c.JSON(500, gin.H{"Err": err.Error()})
+			return
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(200, downline)
+			return
+		}
+
+		records := [][]string{{"player", "nickname", "level", "supervisor", "created_at", "money", "payments", "paid", "commission"}}
+		for _, player := range downline {
+			records = append(records, []string{
+				strconv.Itoa(int(player.Player)),
+				player.Nickname,
+				strconv.Itoa(int(player.Level)),
+				strconv.Itoa(int(player.Supervisor)),
+				player.CreatedAt.Format("2006-01-02 15:04:05"),
+				strconv.FormatInt(player.Money, 10),
+				strconv.Itoa(int(player.Payments)),
+				strconv.FormatInt(player.Paid, 10),
+				strconv.FormatInt(player.Commission, 10),
+			})
+		}
+		writeCSV(c, fmt.Sprintf("downline_%d.csv", agent), records)
+	})
+}
+
+// 解析代理与统计区间, 返回的 to 为结束日期的次日零点
+func reportArgs(c *gin.Context) (agent database.Player, from, to time.Time, ok bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"Err": "illegal agent id"})
+		return
+	}
+	agent = database.Player(id)
+
+	now := time.Now()
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	if param := c.Query("to"); param != "" {
+		if to, err = time.ParseInLocation("2006-01-02", param, time.Local); err != nil {
+			c.JSON(400, gin.H{"Err": "illegal to date"})
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	from = to.AddDate(0, 0, -kReportDays)
+	if param := c.Query("from"); param != "" {
+		if from, err = time.ParseInLocation("2006-01-02", param, time.Local); err != nil {
+			c.JSON(400, gin.H{"Err": "illegal from date"})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(400, gin.H{"Err": "from must not be after to"})
+		return
+	}
+
+	return agent, from, to, true
+}
+
+func writeCSV(c *gin.Context, name string, records [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Status(200)
+
+	// 带 BOM 以便 Excel 识别 UTF-8
+	c.Writer.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(records)
+}
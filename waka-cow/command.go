@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka-cow/conf"
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+// 执行命令行命令后退出
+//
+//	migrate [version]   迁移到指定版本, 默认为最新版本
+//	rollback [steps]    回滚最近应用的迁移, 默认回滚 1 个, 生产环境中存在玩家时拒绝删除保存数据的表
+//	status              查看迁移状态
+//	reconcile           按账本分录核对玩家余额与冻结, 存在差异时以状态 1 退出
+//	archive             按 archive 配置归档过期的战绩并删除过期的归档
+//	config              打印生效的配置, 密钥显示为 ******
+func runCommand(args []string) {
+	option := databaseOption()
+	option.EnableLog = false
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		version := 0
+		if len(args) > 1 {
+			version, err = strconv.Atoi(args[1])
+			if err != nil {
+				break
+			}
+		}
+		err = database.Migrate(option, int32(version))
+	case "rollback":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				break
+			}
+		}
+		err = database.Rollback(option, steps)
+	case "status":
+		err = printMigrations(option)
+	case "reconcile":
+		err = reconcile(option)
+	case "archive":
+		err = archive(option)
+	case "config":
+		err = conf.Print(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, available: migrate [version], rollback [steps], status, reconcile, archive, config\n", args[0])
+		os.Exit(2)
+	}
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"command": args[0],
+			"err":     err,
+		}).Fatalln("command failed")
+	}
+}
+
+func printMigrations(option database.Option) error {
+	migrations, err := database.Migrations(option)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		status := "pending"
+		if m.Applied {
+			status = "applied at " + m.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %-32s %s\n", m.Version, m.Name, status)
+	}
+	return nil
+}
+
+func reconcile(option database.Option) error {
+	drifts, unbalanced, err := database.Reconcile(option)
+	if err != nil {
+		return err
+	}
+	for _, posting := range unbalanced {
+		fmt.Printf("posting %d unbalanced\n", posting)
+	}
+	for _, drift := range drifts {
+		fmt.Printf("player %-8d %-8s ledger %-12d actual %-12d drift %d\n",
+			drift.Player, drift.Account, drift.Ledger, drift.Actual, drift.Actual-drift.Ledger)
+	}
+	if len(drifts) > 0 || len(unbalanced) > 0 {
+		fmt.Printf("%d drifts, %d unbalanced postings\n", len(drifts), len(unbalanced))
+		os.Exit(1)
+	}
+	fmt.Println("all balances match the ledger")
+	return nil
+}
+
+func archive(option database.Option) error {
+	report, err := database.ArchiveHistories(option)
+	if report != nil {
+		var sources []string
+		for source := range report.Archived {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			fmt.Printf("%-24s %d archived\n", source, report.Archived[source])
+		}
+		fmt.Printf("%d expired archives purged\n", report.Purged)
+	}
+	return err
+}
@@ -1,12 +1,5 @@
 package conf
 
-import (
-	"fmt"
-	"io/ioutil"
-
-	"github.com/BurntSushi/toml"
-)
-
 type Mode struct {
 	Mode string `toml:"mode"`
 }
@@ -21,15 +14,31 @@ type Install struct {
 }
 
 type Database struct {
+	Driver   string `toml:"driver"`
 	Host     string `toml:"host"`
 	User     string `toml:"user"`
 	Password string `toml:"password"`
 	Name     string `toml:"name"`
 }
 
+type Cache struct {
+	TTL  int32 `toml:"ttl"`
+	Size int32 `toml:"size"`
+	Poll int32 `toml:"poll"`
+}
+
+type Archive struct {
+	Age       int32 `toml:"age"`
+	Retention int32 `toml:"retention"`
+	Interval  int32 `toml:"interval"`
+	Batch     int32 `toml:"batch"`
+}
+
 type Listen struct {
-	Gateway string `toml:"gateway"`
-	Backend string `toml:"backend"`
+	Gateway   string   `toml:"gateway"`
+	WebSocket string   `toml:"websocket"`
+	Backend   string   `toml:"backend"`
+	Codecs    []string `toml:"codecs"`
 }
 
 type Hall struct {
@@ -38,27 +47,27 @@ type Hall struct {
 	BindMoney     int32  `toml:"bind_money"`
 }
 
+type CommissionRule struct {
+	Rates []int32 `toml:"rates"`
+}
+
+type Commission struct {
+	Rates   []int32                   `toml:"rates"`
+	Reasons map[string]CommissionRule `toml:"reasons"`
+}
+
 type T struct {
-	Mode     Mode     `toml:"mode"`
-	Log      Logger   `toml:"log"`
-	Install  Install  `toml:"install"`
-	Database Database `toml:"database"`
-	Gateway  Listen   `toml:"listen"`
-	Hall     Hall     `toml:"hall"`
+	Mode       Mode       `toml:"mode"`
+	Log        Logger     `toml:"log"`
+	Install    Install    `toml:"install"`
+	Database   Database   `toml:"database"`
+	Cache      Cache      `toml:"cache"`
+	Archive    Archive    `toml:"archive"`
+	Gateway    Listen     `toml:"listen"`
+	Hall       Hall       `toml:"hall"`
+	Commission Commission `toml:"commission"`
 }
 
 var (
 	Option T
 )
-
-func init() {
-	d, err := ioutil.ReadFile("conf.toml")
-	if err != nil {
-		panic(fmt.Sprintf("read config file \"conf.toml\" failed: %s\n", err.Error()))
-	}
-
-	_, err = toml.Decode(string(d), &Option)
-	if err != nil {
-		panic(fmt.Sprintf("decode config file \"conf.toml\" failed: %s\n", err.Error()))
-	}
-}
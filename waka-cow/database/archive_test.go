@@ -0,0 +1,179 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/liuhan907/waka/waka-cow/proto"
+)
+
+func countTestRows(t *testing.T, model interface{}) int {
+	var count int
+	if err := store.(*gormStore).db.Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func marshalTestDetail(t *testing.T, pb proto.Message) []byte {
+	d, err := proto.Marshal(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+type testSummary struct {
+	Source string
+	Mode   int32
+	Total  int32
+	Number int64
+}
+
+func assertSummaries(t *testing.T, player Player, day time.Time, want []testSummary) {
+	summaries, err := QueryHistorySummaries(player, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != len(want) {
+		t.Fatalf("player %d summaries %d, want %d", player, len(summaries), len(want))
+	}
+	for i, summary := range summaries {
+		got := testSummary{summary.Source, summary.Mode, summary.Total, summary.Number}
+		if got != want[i] || !summary.Day.Equal(day) {
+			t.Errorf("player %d summary %d = %+v on %v, want %+v on %v", player, i, got, summary.Day, want[i], day)
+		}
+	}
+}
+
+func TestArchiveHistories(t *testing.T) {
+	openTestStore(t)
+	first := createTestPlayer(t, 0, DefaultSupervisor)
+	second := createTestPlayer(t, 0, DefaultSupervisor)
+
+	day, _ := PeriodDay.Start(time.Now().AddDate(0, 0, -3))
+	old := day.Add(time.Hour)
+	detail := &cow_proto.NiuniuHistory{RoomId: 7, CreatedAt: "old"}
+
+	histories := store.Histories()
+	oldCow := &CowHistory{Player: first, Payload: marshalTestDetail(t, detail), CreatedAt: old}
+	recentCow := &CowHistory{Player: first, Payload: marshalTestDetail(t, detail), CreatedAt: time.Now()}
+	for _, err := range []error{
+		histories.CreateCow(oldCow),
+		histories.CreateCow(&CowHistory{Player: first, CreatedAt: old.Add(time.Minute)}),
+		histories.CreateCow(recentCow),
+		histories.CreateGomoku(&GomokuHistory{Player: first, Opponent: second, Cost: 30, CreatedAt: old}),
+		histories.CreateGomoku(&GomokuHistory{Player: first, Opponent: second, Cost: 20, CreatedAt: old.Add(time.Minute)}),
+		histories.CreateGomoku(&GomokuHistory{Player: second, Opponent: first, Cost: 5, CreatedAt: old}),
+		histories.CreateLever28(&Lever28History{Player: first, Mode: 0, Bag: marshalTestDetail(t, &cow_proto.Lever28BagClear{Id: 1}), CreatedAt: old}),
+		histories.CreateLever28(&Lever28History{Player: first, Mode: 1, Bag: marshalTestDetail(t, &cow_proto.Lever28BagClear{Id: 1}), CreatedAt: old}),
+		histories.CreateRed(&RedHistory{Player: second, Mode: 1, Bag: marshalTestDetail(t, &cow_proto.RedBagClear{Id: 2}), CreatedAt: old}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 每批两条, 覆盖分批归档
+	option := Option{ArchiveAge: time.Hour * 24, ArchiveBatch: 2}
+	report, err := archiveHistories(store, option, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := map[string]int{"history_cow": 2, "history_gomoku": 3, "history_lever28": 2, "history_red": 1}
+	for source, count := range archived {
+		if report.Archived[source] != count {
+			t.Errorf("archived %d from %s, want %d", report.Archived[source], source, count)
+		}
+	}
+
+	// 原记录删除, 未过期的保留
+	if n := countTestRows(t, new(CowHistory)); n != 1 {
+		t.Fatalf("history_cow rows %d, want 1", n)
+	}
+	for _, model := range []interface{}{new(GomokuHistory), new(Lever28History), new(RedHistory)} {
+		if n := countTestRows(t, model); n != 0 {
+			t.Fatalf("%T rows %d, want 0", model, n)
+		}
+	}
+	if n := countTestRows(t, new(HistoryArchiveData)); n != 8 {
+		t.Fatalf("archives %d, want 8", n)
+	}
+
+	firstSummaries := []testSummary{
+		{"history_cow", 0, 2, 0},
+		{"history_gomoku", 0, 2, 50},
+		{"history_lever28", 0, 1, 0},
+		{"history_lever28", 1, 1, 0},
+	}
+	secondSummaries := []testSummary{
+		{"history_gomoku", 0, 1, 5},
+		{"history_red", 1, 1, 0},
+	}
+	assertSummaries(t, first, day, firstSummaries)
+	assertSummaries(t, second, day, secondSummaries)
+
+	// 按来源表与原记录的主键取回归档
+	history, err := QueryArchivedHistory("history_cow", int64(oldCow.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, ok := history.Row.(*CowHistory)
+	if !ok || row.Id != oldCow.Id || row.Player != first || history.Player != first || !history.CreatedAt.Equal(old) {
+		t.Fatalf("archived history %+v, row %+v", history, history.Row)
+	}
+	if !proto.Equal(history.Detail, detail) {
+		t.Fatalf("archived detail %v, want %v", history.Detail, detail)
+	}
+	if _, err := QueryArchivedHistory("history_cow", int64(recentCow.Id)); err != ErrArchiveNotFound {
+		t.Fatalf("query recent history: %v", err)
+	}
+	if _, err := QueryArchivedHistory("history_player", 1); err != ErrUnknownArchiveSource {
+		t.Fatalf("query unknown source: %v", err)
+	}
+
+	// 再次归档没有可归档的记录
+	report, err = archiveHistories(store, option, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, count := range report.Archived {
+		if count != 0 {
+			t.Errorf("archived %d from %s again", count, source)
+		}
+	}
+
+	// 另一进程已归档同一记录时违反唯一索引, 整批回滚
+	if err := histories.CreateCow(&CowHistory{Id: oldCow.Id, Player: first, CreatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveBatch(store, findArchiveSource("history_cow"), time.Now().Add(-option.ArchiveAge), 2); err == nil {
+		t.Fatal("archived a row twice")
+	}
+	if n := countTestRows(t, new(CowHistory)); n != 2 {
+		t.Fatalf("history_cow rows %d after duplicate, want 2", n)
+	}
+	if n := countTestRows(t, new(HistoryArchiveData)); n != 8 {
+		t.Fatalf("archives %d after duplicate, want 8", n)
+	}
+	assertSummaries(t, first, day, firstSummaries)
+
+	// 删除过期归档时保留汇总
+	if err := store.(*gormStore).db.Model(new(HistoryArchiveData)).UpdateColumn("archived_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	report, err = archiveHistories(store, Option{ArchiveRetention: time.Hour * 24}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Purged != 8 {
+		t.Fatalf("purged %d, want 8", report.Purged)
+	}
+	if _, err := QueryArchivedHistory("history_cow", int64(oldCow.Id)); err != ErrArchiveNotFound {
+		t.Fatalf("query purged history: %v", err)
+	}
+	assertSummaries(t, first, day, firstSummaries)
+	assertSummaries(t, second, day, secondSummaries)
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liuhan907/waka/waka-cow/conf"
+)
+
+// 比例的基数, 所有比例均为万分比
+const rateBase = 10000
+
+// 未配置时的各级提成比例
+var defaultCommissionRates = []int32{3000, 900, 600}
+
+// 提成计算结果, Income, Tips 与 System 之和总是等于交易数额
+type commission struct {
+	// 收款人实收
+	Income int64
+	// 各级上级的提成
+	Tips []int64
+	// 系统收取的剩余部分
+	System int64
+}
+
+// 按万分比计算, 四舍五入, number 不能为负
+func applyRate(number int64, rate int32) int64 {
+	return (number*int64(rate) + rateBase/2) / rateBase
+}
+
+// 计算一笔交易的提成, 收款人实收扣除 loss 后的部分, 手续费按 rates 逐级分配, 每一级按前几级分完后的剩余计算
+func computeCommission(number int64, loss int32, rates []int32) commission {
+	c := commission{
+		Income: applyRate(number, rateBase-loss),
+	}
+	remain := number - c.Income
+	for _, rate := range rates {
+		tip := applyRate(remain, rate)
+		c.Tips = append(c.Tips, tip)
+		remain -= tip
+	}
+	c.System = remain
+	return c
+}
+
+// 交易原因对应的各级提成比例, 按原因的最长前缀覆盖, 如 red 覆盖 red.grab 与 red.pay
+func commissionRates(reason string) []int32 {
+	option := conf.Option.Commission
+	for key := reason; ; {
+		if rule, being := option.Reasons[key]; being {
+			return rule.Rates
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	if option.Rates == nil {
+		return defaultCommissionRates
+	}
+	return option.Rates
+}
+
+// 检查提成配置, 返回所有错误的比例
+func validateCommission() error {
+	var bad []string
+	check := func(name string, rates []int32) {
+		for i, rate := range rates {
+			if rate < 0 || rate > rateBase {
+				bad = append(bad, fmt.Sprintf("%s[%d] = %d", name, i, rate))
+			}
+		}
+	}
+
+	option := conf.Option.Commission
+	check("commission.rates", option.Rates)
+	for reason, rule := range option.Reasons {
+		check(fmt.Sprintf("commission.reasons.%q.rates", reason), rule.Rates)
+	}
+
+	if len(bad) > 0 {
+		return fmt.Errorf("commission rates must be within [0, %d]: %s", rateBase, strings.Join(bad, ", "))
+	}
+	return nil
+}
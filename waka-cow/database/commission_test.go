@@ -0,0 +1,188 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/liuhan907/waka/waka-cow/conf"
+)
+
+func TestApplyRate(t *testing.T) {
+	cases := []struct {
+		number int64
+		rate   int32
+		want   int64
+	}{
+		{0, 3000, 0},
+		{1, 0, 0},
+		{1, rateBase, 1},
+		{10000, 3000, 3000},
+		// 0.5 进位, 0.4999 舍去
+		{5, 1000, 1},
+		{4, 1000, 0},
+		{15, 3000, 5},
+		{14999, 1, 1},
+		{14999, 3333, 4999},
+		{499, 500, 25},
+		{7, 5000, 4},
+		{3, 5000, 2},
+		{1000000007, 9999, 999900007},
+	}
+	for _, c := range cases {
+		if got := applyRate(c.number, c.rate); got != c.want {
+			t.Errorf("applyRate(%d, %d) = %d, want %d", c.number, c.rate, got, c.want)
+		}
+	}
+}
+
+func TestComputeCommission(t *testing.T) {
+	cases := []struct {
+		name   string
+		number int64
+		loss   int32
+		rates  []int32
+		want   commission
+	}{
+		{"zero", 0, 500, defaultCommissionRates, commission{0, []int64{0, 0, 0}, 0}},
+		{"no loss", 10000, 0, defaultCommissionRates, commission{10000, []int64{0, 0, 0}, 0}},
+		{"all loss", 10000, rateBase, nil, commission{0, nil, 10000}},
+		{"depth 0", 10000, 500, nil, commission{9500, nil, 500}},
+		{"depth 1", 10000, 500, []int32{3000}, commission{9500, []int64{150}, 350}},
+		{"depth 2", 10000, 500, []int32{3000, 900}, commission{9500, []int64{150, 32}, 318}},
+		{"depth 3", 10000, 500, defaultCommissionRates, commission{9500, []int64{150, 32, 19}, 299}},
+		{"depth 4", 10000, 500, []int32{3000, 900, 600, rateBase}, commission{9500, []int64{150, 32, 19, 299}, 0}},
+		// 手续费 1 时各级按剩余的 1 计算, 比例不低于一半才能分到
+		{"one", 1, rateBase, []int32{5000, 5000}, commission{0, []int64{1, 0}, 0}},
+		{"odd", 333, 500, defaultCommissionRates, commission{316, []int64{5, 1, 1}, 10}},
+		{"odd rounding", 12345, 500, defaultCommissionRates, commission{11728, []int64{185, 39, 24}, 369}},
+		{"full tip", 999, 500, []int32{rateBase, rateBase}, commission{949, []int64{50, 0}, 0}},
+	}
+	for _, c := range cases {
+		got := computeCommission(c.number, c.loss, c.rates)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: computeCommission(%d, %d, %v) = %+v, want %+v", c.name, c.number, c.loss, c.rates, got, c.want)
+		}
+
+		sum := got.Income + got.System
+		for _, tip := range got.Tips {
+			sum += tip
+		}
+		if sum != c.number {
+			t.Errorf("%s: income %d + tips %v + system %d = %d, want %d", c.name, got.Income, got.Tips, got.System, sum, c.number)
+		}
+	}
+}
+
+// 任意数额与比例下收款人, 各级上级与系统分到的总和都等于交易数额
+func TestComputeCommissionConserves(t *testing.T) {
+	rates := [][]int32{nil, {1}, {3000, 900, 600}, {5000, 5000, 5000, 5000}, {rateBase, 1, 9999}}
+	for number := int64(0); number <= 2000; number++ {
+		for _, loss := range []int32{0, 1, 500, 4999, 5000, rateBase} {
+			for _, r := range rates {
+				c := computeCommission(number, loss, r)
+				sum := c.Income + c.System
+				for _, tip := range c.Tips {
+					if tip < 0 {
+						t.Fatalf("computeCommission(%d, %d, %v) tips %v", number, loss, r, c.Tips)
+					}
+					sum += tip
+				}
+				if sum != number || c.Income < 0 || c.System < 0 || len(c.Tips) != len(r) {
+					t.Fatalf("computeCommission(%d, %d, %v) = %+v", number, loss, r, c)
+				}
+			}
+		}
+	}
+}
+
+func TestCommissionRates(t *testing.T) {
+	defer func(option conf.T) { conf.Option = option }(conf.Option)
+
+	conf.Option = conf.Default()
+	conf.Option.Commission.Rates = []int32{2000, 1000}
+	conf.Option.Commission.Reasons = map[string]conf.CommissionRule{
+		"red":         {Rates: []int32{5000}},
+		"red.grab":    {Rates: []int32{1000, 1000, 1000}},
+		"lever28":     {Rates: []int32{}},
+		"cow.flowing": {Rates: []int32{4000}},
+	}
+
+	cases := []struct {
+		reason string
+		want   []int32
+	}{
+		{"red.grab", []int32{1000, 1000, 1000}},
+		{"red.grab.extra", []int32{1000, 1000, 1000}},
+		{"red.pay", []int32{5000}},
+		{"red", []int32{5000}},
+		{"lever28.cost", []int32{}},
+		// 只按 . 分隔的完整前缀匹配
+		{"redx.pay", []int32{2000, 1000}},
+		{"cow.flowing_cost", []int32{2000, 1000}},
+		{"cow.order_cost", []int32{2000, 1000}},
+		{"", []int32{2000, 1000}},
+	}
+	for _, c := range cases {
+		if got := commissionRates(c.reason); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("commissionRates(%q) = %v, want %v", c.reason, got, c.want)
+		}
+	}
+
+	// 没有配置 rates 时使用默认比例
+	conf.Option.Commission.Rates = nil
+	if got := commissionRates("cow.order_cost"); !reflect.DeepEqual(got, defaultCommissionRates) {
+		t.Errorf("commissionRates without rates = %v, want %v", got, defaultCommissionRates)
+	}
+	if got := commissionRates("red.grab"); !reflect.DeepEqual(got, []int32{1000, 1000, 1000}) {
+		t.Errorf("commissionRates(red.grab) without rates = %v", got)
+	}
+}
+
+// 付款人没有上级时提成归系统玩家, 所有人的钱之和不变
+func TestSupervisorOfFallsBackToSystem(t *testing.T) {
+	openTestStore(t)
+
+	top := createTestPlayer(t, 0, 0)
+	agent := createTestPlayer(t, 0, top)
+	loser := createTestPlayer(t, 100000, agent)
+	orphan := createTestPlayer(t, 100000, 0)
+	victory := createTestPlayer(t, 0, DefaultSupervisor)
+
+	players := []Player{DefaultSupervisor, top, agent, loser, orphan, victory}
+	total := func() int64 {
+		var sum int64
+		for _, player := range players {
+			sum += testMoney(t, player)
+		}
+		return sum
+	}
+	before := total()
+	system := testMoney(t, DefaultSupervisor)
+
+	if _, err := CowFlowingCostSettle(1, 1, []*CowFlowingCostData{
+		{Victory: victory, Loser: loser, Number: 10000},
+		{Victory: victory, Loser: orphan, Number: 10000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// loser -> agent -> top -> 系统, orphan -> 系统 -> 系统 -> 系统
+	want := map[Player]int64{
+		loser:   90000,
+		orphan:  90000,
+		victory: 9500 * 2,
+		agent:   150,
+		top:     32,
+		// loser 的第三级与剩余部分, 加上 orphan 的全部手续费
+		DefaultSupervisor: system + 19 + 299 + 500,
+	}
+	for player, money := range want {
+		if got := testMoney(t, player); got != money {
+			t.Errorf("player %d money = %d, want %d", player, got, money)
+		}
+	}
+	if after := total(); after != before {
+		t.Errorf("total money %d, want %d", after, before)
+	}
+	assertReconciled(t)
+}
@@ -1,12 +1,9 @@
 package database
 
 import (
-	"fmt"
 	"os"
 	"time"
 
-	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/mysql"
 	"github.com/sirupsen/logrus"
 
 	"github.com/liuhan907/waka/waka-cow/conf"
@@ -18,102 +15,110 @@ var (
 		"module": "cow.database",
 	})
 
-	mysql *gorm.DB
+	store Store
 )
 
-func init() {
-	db, err := gorm.Open("mysql", fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local`,
-		conf.Option.Database.User, conf.Option.Database.Password, conf.Option.Database.Host, conf.Option.Database.Name))
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	mysql = db
-
-	mysql.LogMode(true)
+// 数据库选项
+type Option struct {
+	// 驱动, mysql 或 sqlite3, 默认为 mysql
+	Driver string
+	// MySQL 地址
+	Host string
+	// MySQL 用户名
+	User string
+	// MySQL 密码
+	Password string
+	// MySQL 数据库名, SQLite 数据库文件路径
+	Name string
+
+	// 重建所有表, 内存数据库总是重建
+	Reset bool
+	// 执行未应用的迁移
+	Migrate bool
+	// 生产环境, 数据库非空时拒绝重建
+	Production bool
+
+	// 玩家缓存有效期, 为 0 时使用默认值
+	CacheTTL time.Duration
+	// 最多缓存的玩家数, 为 0 时使用默认值
+	CacheSize int
+	// 轮询玩家变更通知的间隔, 为 0 时使用默认值
+	ChangePoll time.Duration
+
+	// 归档早于该时长的战绩, 为 0 时不归档
+	ArchiveAge time.Duration
+	// 删除早于该时长的归档, 为 0 时一直保留
+	ArchiveRetention time.Duration
+	// 归档间隔, 为 0 时使用默认值
+	ArchiveInterval time.Duration
+	// 每个事务归档的记录数, 为 0 时使用默认值
+	ArchiveBatch int32
+
+	EnableLog bool
+}
 
-	tables := []interface{}{
-		new(Configuration),
-		new(PlayerData),
-		new(FreezeData),
-		new(TransactionData),
+func (option Option) memory() bool {
+	return option.Driver == DriverSQLite && option.Name == MemorySource
+}
 
-		new(CowHistory),
-		new(GomokuHistory),
-		new(Lever28History),
-		new(RedHistory),
-	}
-	if conf.Option.Install.Reset {
-		if err := mysql.DropTableIfExists(tables...).Error; err != nil {
-			log.Panic(err)
-		}
-		if err := mysql.CreateTable(tables...).Error; err != nil {
-			log.Panic(err)
-		}
-		if err := mysql.Exec("alter table players AUTO_INCREMENT = 100000;").Error; err != nil {
-			log.Panic(err)
-		}
+// 打开数据库, 初始化系统玩家与配置, 恢复上次运行遗留的冻结并开始轮询玩家变更通知
+func Open(option Option) error {
+	if err := validateCommission(); err != nil {
+		return err
 	}
-	if conf.Option.Install.Update {
-		if err := mysql.AutoMigrate(tables...).Error; err != nil {
-			log.Panic(err)
-		}
+
+	s, err := openGormStore(option)
+	if err != nil {
+		return err
 	}
 
-	if conf.Option.Install.Reset || conf.Option.Install.Update {
-		systemPlayerCount := 0
-		if err := mysql.Model(new(PlayerData)).Where("id = ?", DefaultSupervisor).Count(&systemPlayerCount).Error; err != nil {
-			log.Panic(err)
+	cache = newPlayerCache(option.CacheTTL, option.CacheSize)
+	Use(s)
+
+	if option.Reset || option.Migrate || option.memory() {
+		systemPlayer, err := s.Players().Find(DefaultSupervisor)
+		if err != nil {
+			return err
 		}
-		if systemPlayerCount == 0 {
-			if err := mysql.Create(&PlayerData{
+		if systemPlayer == nil {
+			if err := s.Players().Create(&PlayerData{
 				Id:            DefaultSupervisor,
 				Nickname:      "__system",
 				CreatedAt:     time.Now(),
 				Vip:           time.Now(),
 				Supervisor:    DefaultSupervisor,
 				VictoryWeight: DefaultVictoryWeight,
-			}).Error; err != nil {
-				log.Panic(err)
+			}); err != nil {
+				return err
 			}
 		}
 	}
 
-	RefreshConfiguration()
+	if err := ReloadSettings(conf.Option.Hall); err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Warnln("load settings failed")
+	}
 
-	recoverFreezeMoneyAfterLast()
-}
+	recoverFreezeMoneyAfterLast().log()
 
-func recoverFreezeMoneyAfterLast() {
-	var freezes []*FreezeData
+	startArchive(s, option)
 
-	ts := mysql.Begin()
+	return startWatchChanges(s, option.ChangePoll)
+}
 
-	if err := ts.Where("recovered = ?", false).Find(&freezes).Error; err != nil {
-		log.WithFields(logrus.Fields{
-			"err": err,
-		}).Warnln("query last freeze money records failed")
-		ts.Rollback()
-		return
-	}
+// 使用指定的存储, 替换已打开的存储并清空玩家缓存
+func Use(s Store) {
+	store = s
+	cache.reset()
+}
 
-	for _, freeze := range freezes {
-		player, number, err := recoverFreezeMoney(ts, freeze.Id)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"freeze": freeze.Id,
-				"player": freeze.Player,
-				"number": freeze.Number,
-				"err":    err,
-			}).Warnln("recover freeze money failed")
-			ts.Rollback()
-			return
-		}
-		log.WithFields(logrus.Fields{
-			"player": player,
-			"number": number,
-		}).Warnln("found freeze and recovered")
+// 关闭数据库
+func Close() error {
+	if store == nil {
+		return nil
 	}
-
-	ts.Commit()
+	stopWatchChanges()
+	stopArchive()
+	return store.Close()
 }
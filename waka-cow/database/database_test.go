@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/liuhan907/waka/waka-cow/conf"
+)
+
+// 打开内存数据库, 测试结束时关闭
+func openTestStore(t testing.TB) {
+	conf.Option = conf.Default()
+	if err := Open(Option{Driver: DriverSQLite, Name: MemorySource}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+var testPlayerSerial int
+
+// 创建玩家, supervisor 为 0 时没有上级
+func createTestPlayer(t testing.TB, money int64, supervisor Player) Player {
+	testPlayerSerial++
+	player := &PlayerData{
+		CreatedAt:     time.Now(),
+		WechatUnionid: fmt.Sprintf("test-unionid-%d", testPlayerSerial),
+		Token:         fmt.Sprintf("test-token-%d", testPlayerSerial),
+		Vip:           time.Now(),
+		Money:         money,
+		Supervisor:    supervisor,
+		VictoryWeight: DefaultVictoryWeight,
+	}
+	if err := store.Transaction(func(s Store) error {
+		if err := s.Players().Create(player); err != nil {
+			return err
+		}
+		return post(s, "test.opening",
+			ledgerEntry{AccountExternal, 0, money * (-1)},
+			ledgerEntry{AccountWallet, player.Id, money},
+		)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return player.Id
+}
+
+func testMoney(t testing.TB, player Player) int64 {
+	playerData, err := store.Players().Find(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playerData == nil {
+		t.Fatalf("player %d not found", player)
+	}
+	return playerData.Money
+}
+
+// 账本应当全部平衡, 并与玩家的钱和未恢复的冻结一致
+func assertReconciled(t testing.TB) {
+	drifts, unbalanced, err := reconcile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, drift := range drifts {
+		t.Errorf("drift %+v", drift)
+	}
+	if len(unbalanced) > 0 {
+		t.Errorf("unbalanced postings %v", unbalanced)
+	}
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 每条冻结记录的最多恢复次数
+	freezeRecoverAttempts = 3
+	// 恢复重试间隔, 按已尝试次数递增
+	freezeRecoverInterval = time.Millisecond * 200
+)
+
+var (
+	ErrFreezeNotStuck = errors.New("freeze is not stuck")
+)
+
+// 启动时恢复冻结的结果
+type FreezeRecoveryReport struct {
+	// 未恢复的冻结记录数
+	Total int
+	// 成功恢复的记录数
+	Recovered int
+	// 成功恢复的钱
+	Number int64
+	// 恢复失败的记录
+	Failures []*FreezeData
+}
+
+func (report *FreezeRecoveryReport) log() {
+	fields := logrus.Fields{
+		"total":     report.Total,
+		"recovered": report.Recovered,
+		"number":    report.Number,
+		"failed":    len(report.Failures),
+	}
+	if len(report.Failures) > 0 {
+		var failures []Freeze
+		for _, freeze := range report.Failures {
+			failures = append(failures, freeze.Id)
+		}
+		fields["failures"] = failures
+		log.WithFields(fields).Warnln("freeze recovery finished with failures")
+	} else {
+		log.WithFields(fields).Infoln("freeze recovery finished")
+	}
+}
+
+// 恢复上次运行遗留的冻结, 每条记录在单独的事务中恢复, 失败时重试, 最终失败的记录写入错误
+func recoverFreezeMoneyAfterLast() *FreezeRecoveryReport {
+	report := &FreezeRecoveryReport{}
+
+	freezes, err := store.Freezes().FindUnrecovered()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Warnln("query last freeze money records failed")
+		return report
+	}
+	report.Total = len(freezes)
+
+	for _, freeze := range freezes {
+		err := recoverFreezeWithRetry(freeze.Id)
+		if err == nil {
+			report.Recovered++
+			report.Number += freeze.Number
+			log.WithFields(logrus.Fields{
+				"freeze": freeze.Id,
+				"player": freeze.Player,
+				"number": freeze.Number,
+			}).Warnln("found freeze and recovered")
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"freeze": freeze.Id,
+			"player": freeze.Player,
+			"number": freeze.Number,
+			"err":    err,
+		}).Warnln("recover freeze money failed")
+
+		if e := store.Freezes().RecordFailure(freeze.Id, err.Error()); e != nil {
+			log.WithFields(logrus.Fields{
+				"freeze": freeze.Id,
+				"err":    e,
+			}).Warnln("record freeze failure failed")
+		}
+		freeze.RecoverAttempts++
+		freeze.RecoverError = err.Error()
+		report.Failures = append(report.Failures, freeze)
+	}
+
+	return report
+}
+
+func recoverFreezeWithRetry(id Freeze) error {
+	var err error
+	for attempt := 1; attempt <= freezeRecoverAttempts; attempt++ {
+		var player Player
+		err = store.Transaction(func(s Store) (err error) {
+			player, _, err = recoverFreezeMoney(s, id)
+			return err
+		})
+		switch errors.Cause(err) {
+		case nil:
+			RefreshCache(player)
+			return nil
+		case ErrFreezeRecovered:
+			return nil
+		case ErrFreezeNotFound, ErrPlayerNotFound, ErrUnbalanced:
+			return err
+		}
+		if attempt < freezeRecoverAttempts {
+			time.Sleep(freezeRecoverInterval * time.Duration(attempt))
+		}
+	}
+	return err
+}
+
+// 查询恢复失败且仍未恢复的冻结记录
+func QueryStuckFreezes() ([]*FreezeData, error) {
+	return store.Freezes().FindStuck()
+}
+
+// 手动处理恢复失败的冻结记录, refund 为 true 时退还给玩家, 否则直接作废
+func ResolveStuckFreeze(id Freeze, refund bool) error {
+	var player Player
+	err := store.Transaction(func(s Store) error {
+		freeze, err := s.Freezes().Find(id)
+		if err != nil {
+			return err
+		}
+		if freeze == nil {
+			return ErrFreezeNotFound
+		}
+		if freeze.Recovered {
+			return ErrFreezeRecovered
+		}
+		if freeze.RecoverAttempts == 0 {
+			return ErrFreezeNotStuck
+		}
+		player = freeze.Player
+
+		if refund {
+			_, _, err := recoverFreezeMoney(s, id)
+			return err
+		}
+
+		freeze.Recovered = true
+		if err := s.Freezes().Save(freeze); err != nil {
+			return err
+		}
+		return post(s, "freeze.discard",
+			ledgerEntry{AccountFrozen, freeze.Player, freeze.Number * (-1)},
+			ledgerEntry{AccountExternal, 0, freeze.Number},
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	RefreshCache(player)
+
+	log.WithFields(logrus.Fields{
+		"freeze": id,
+		"player": player,
+		"refund": refund,
+	}).Infoln("stuck freeze resolved")
+
+	return nil
+}
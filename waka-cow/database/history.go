@@ -8,6 +8,60 @@ import (
 	"github.com/liuhan907/waka/waka-cow/proto"
 )
 
+const (
+	// 每页最多的战绩数
+	maxHistoryLimit = 50
+)
+
+// 战绩分页游标, 按 (created_at, id) 从新到旧翻页, 为 nil 时从最新的记录开始
+type HistoryCursor struct {
+	CreatedAt time.Time
+	Id        int64
+}
+
+// 从客户端传入的游标转换, 为空时返回 nil
+func HistoryCursorFromProto(pb *cow_proto.HistoryCursor) *HistoryCursor {
+	if pb == nil || (pb.CreatedAt == 0 && pb.Id == 0) {
+		return nil
+	}
+	return &HistoryCursor{
+		CreatedAt: time.Unix(0, pb.CreatedAt),
+		Id:        pb.Id,
+	}
+}
+
+// 转换为返回给客户端的游标, nil 表示没有更多记录
+func (cursor *HistoryCursor) Proto() *cow_proto.HistoryCursor {
+	if cursor == nil {
+		return nil
+	}
+	return &cow_proto.HistoryCursor{
+		CreatedAt: cursor.CreatedAt.UnixNano(),
+		Id:        cursor.Id,
+	}
+}
+
+// 每页的数量, 为 0 时使用默认数量, 不超过 maxHistoryLimit
+func historyLimit(limit, defaultLimit int32) int32 {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxHistoryLimit {
+		return maxHistoryLimit
+	}
+	return limit
+}
+
+// 取满一页时以最后一条记录作为下一页的游标, 否则没有更多记录
+func nextHistoryCursor(count int, limit int32, createdAt time.Time, id int32) *HistoryCursor {
+	if count < int(limit) {
+		return nil
+	}
+	return &HistoryCursor{CreatedAt: createdAt, Id: int64(id)}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
 type CowHistory struct {
 	// 主键
 	Id int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
@@ -23,21 +77,26 @@ func (CowHistory) TableName() string {
 	return "history_cow"
 }
 
-// 查询牛牛战绩
-func CowQueryHistory(player Player, limit int32) ([]*cow_proto.NiuniuHistory, error) {
-	var d []*CowHistory
-	if err := mysql.Where("player = ?", player).Order("created_at desc").Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
+// 查询牛牛战绩, 同时返回下一页的游标
+func CowQueryHistory(player Player, cursor *HistoryCursor, limit int32) ([]*cow_proto.NiuniuHistory, *HistoryCursor, error) {
+	limit = historyLimit(limit, 20)
+	d, err := store.Histories().FindCow(player, cursor, limit)
+	if err != nil {
+		return nil, nil, err
 	}
 	var x []*cow_proto.NiuniuHistory
 	for _, v := range d {
 		k := &cow_proto.NiuniuHistory{}
 		if err := proto.Unmarshal(v.Payload, k); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		x = append(x, k)
 	}
-	return x, nil
+	if len(d) == 0 {
+		return x, nil, nil
+	}
+	last := d[len(d)-1]
+	return x, nextHistoryCursor(len(d), limit, last.CreatedAt, last.Id), nil
 }
 
 // 添加牛牛约战战绩
@@ -57,11 +116,11 @@ func CowAddHistory(player Player, roomId int32, roomType cow_proto.NiuniuRoomTyp
 	if err != nil {
 		return err
 	}
-	if err := mysql.Create(&CowHistory{
+	if err := store.Histories().CreateCow(&CowHistory{
 		Player:    player,
 		Payload:   d,
 		CreatedAt: time.Now(),
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 	return nil
@@ -83,11 +142,11 @@ func CowAddFlowingHistory(player Player, roomId int32, finally *cow_proto.Niuniu
 	if err != nil {
 		return err
 	}
-	if err := mysql.Create(&CowHistory{
+	if err := store.Histories().CreateCow(&CowHistory{
 		Player:    player,
 		Payload:   d,
 		CreatedAt: time.Now(),
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 	return nil
@@ -112,38 +171,38 @@ func (GomokuHistory) TableName() string {
 	return "history_gomoku"
 }
 
-// 查询五子棋战绩
-func GomokuQueryHistory(player Player, limit int32) ([]*GomokuHistory, error) {
-	var d []*GomokuHistory
-	if err := mysql.Where("player = ?", player).Order("created_at desc").Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
+// 查询五子棋战绩, 同时返回下一页的游标
+func GomokuQueryHistory(player Player, cursor *HistoryCursor, limit int32) ([]*GomokuHistory, *HistoryCursor, error) {
+	limit = historyLimit(limit, 20)
+	d, err := store.Histories().FindGomoku(player, cursor, limit)
+	if err != nil || len(d) == 0 {
+		return d, nil, err
 	}
-	return d, nil
+	last := d[len(d)-1]
+	return d, nextHistoryCursor(len(d), limit, last.CreatedAt, last.Id), nil
 }
 
 // 添加五子棋战绩
 func GomokuAddWarHistory(master, student Player, cost int32) error {
-	ts := mysql.Begin()
-	if err := ts.Create(&GomokuHistory{
-		Player:    master,
-		Opponent:  student,
-		Cost:      cost,
-		CreatedAt: time.Now(),
-	}).Error; err != nil {
-		ts.Rollback()
-		return err
-	}
-	if err := ts.Create(&GomokuHistory{
-		Player:    student,
-		Opponent:  master,
-		Cost:      cost * (-1),
-		CreatedAt: time.Now(),
-	}).Error; err != nil {
-		ts.Rollback()
-		return err
-	}
-	ts.Commit()
-	return nil
+	return store.Transaction(func(s Store) error {
+		if err := s.Histories().CreateGomoku(&GomokuHistory{
+			Player:    master,
+			Opponent:  student,
+			Cost:      cost,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+		if err := s.Histories().CreateGomoku(&GomokuHistory{
+			Player:    student,
+			Opponent:  master,
+			Cost:      cost * (-1),
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -159,26 +218,29 @@ type Lever28History struct {
 	Mode int32
 	// 历史数据
 	Bag []byte `gorm:"type:mediumblob"`
+	// 时间, 加入该列之前的记录为迁移时间
+	CreatedAt time.Time
 }
 
 func (Lever28History) TableName() string {
 	return "history_lever28"
 }
 
-// 查询我发的红包历史
-func Lever28QueryHandHistory(player Player, limit int32) ([]*cow_proto.Lever28BagClear, error) {
-	return lever28QueryHistory(player, 0, limit)
+// 查询我发的红包历史, 同时返回下一页的游标
+func Lever28QueryHandHistory(player Player, cursor *HistoryCursor, limit int32) ([]*cow_proto.Lever28BagClear, *HistoryCursor, error) {
+	return lever28QueryHistory(player, 0, cursor, limit)
 }
 
-// 查询我抢的红包历史
-func Lever28QueryGrabHistory(player Player, limit int32) ([]*cow_proto.Lever28BagClear, error) {
-	return lever28QueryHistory(player, 1, limit)
+// 查询我抢的红包历史, 同时返回下一页的游标
+func Lever28QueryGrabHistory(player Player, cursor *HistoryCursor, limit int32) ([]*cow_proto.Lever28BagClear, *HistoryCursor, error) {
+	return lever28QueryHistory(player, 1, cursor, limit)
 }
 
-func lever28QueryHistory(player Player, mode int32, limit int32) ([]*cow_proto.Lever28BagClear, error) {
-	var d []*Lever28History
-	if err := mysql.Where("player = ? and mode = ?", player, mode).Order("id desc").Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
+func lever28QueryHistory(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*cow_proto.Lever28BagClear, *HistoryCursor, error) {
+	limit = historyLimit(limit, 10)
+	d, err := store.Histories().FindLever28(player, mode, cursor, limit)
+	if err != nil {
+		return nil, nil, err
 	}
 	var r []*cow_proto.Lever28BagClear
 	for _, bag := range d {
@@ -190,7 +252,11 @@ func lever28QueryHistory(player Player, mode int32, limit int32) ([]*cow_proto.L
 			r = append(r, pb)
 		}
 	}
-	return r, nil
+	if len(d) == 0 {
+		return r, nil, nil
+	}
+	last := d[len(d)-1]
+	return r, nextHistoryCursor(len(d), limit, last.CreatedAt, last.Id), nil
 }
 
 // 添加我发的红包记录
@@ -208,11 +274,12 @@ func lever28AddHistory(player Player, mode int32, bag *cow_proto.Lever28BagClear
 	if err != nil {
 		return err
 	}
-	if err := mysql.Create(&Lever28History{
-		Player: player,
-		Mode:   mode,
-		Bag:    d,
-	}).Error; err != nil {
+	if err := store.Histories().CreateLever28(&Lever28History{
+		Player:    player,
+		Mode:      mode,
+		Bag:       d,
+		CreatedAt: time.Now(),
+	}); err != nil {
 		return err
 	}
 	return nil
@@ -231,26 +298,29 @@ type RedHistory struct {
 	Mode int32
 	// 历史数据
 	Bag []byte `gorm:"type:mediumblob"`
+	// 时间, 加入该列之前的记录为迁移时间
+	CreatedAt time.Time
 }
 
 func (RedHistory) TableName() string {
 	return "history_red"
 }
 
-// 查询我发的红包历史
-func RedQueryHandHistory(player Player, limit int32) ([]*cow_proto.RedBagClear, error) {
-	return redQueryHistory(player, 0, limit)
+// 查询我发的红包历史, 同时返回下一页的游标
+func RedQueryHandHistory(player Player, cursor *HistoryCursor, limit int32) ([]*cow_proto.RedBagClear, *HistoryCursor, error) {
+	return redQueryHistory(player, 0, cursor, limit)
 }
 
-// 查询我抢的红包历史
-func RedQueryGrabHistory(player Player, limit int32) ([]*cow_proto.RedBagClear, error) {
-	return redQueryHistory(player, 1, limit)
+// 查询我抢的红包历史, 同时返回下一页的游标
+func RedQueryGrabHistory(player Player, cursor *HistoryCursor, limit int32) ([]*cow_proto.RedBagClear, *HistoryCursor, error) {
+	return redQueryHistory(player, 1, cursor, limit)
 }
 
-func redQueryHistory(player Player, mode int32, limit int32) ([]*cow_proto.RedBagClear, error) {
-	var d []*RedHistory
-	if err := mysql.Where("player = ? and mode = ?", player, mode).Order("id desc").Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
+func redQueryHistory(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*cow_proto.RedBagClear, *HistoryCursor, error) {
+	limit = historyLimit(limit, 10)
+	d, err := store.Histories().FindRed(player, mode, cursor, limit)
+	if err != nil {
+		return nil, nil, err
 	}
 	var r []*cow_proto.RedBagClear
 	for _, bag := range d {
@@ -262,7 +332,11 @@ func redQueryHistory(player Player, mode int32, limit int32) ([]*cow_proto.RedBa
 			r = append(r, pb)
 		}
 	}
-	return r, nil
+	if len(d) == 0 {
+		return r, nil, nil
+	}
+	last := d[len(d)-1]
+	return r, nextHistoryCursor(len(d), limit, last.CreatedAt, last.Id), nil
 }
 
 // 添加我发的红包记录
@@ -280,11 +354,12 @@ func redAddHistory(player Player, mode int32, bag *cow_proto.RedBagClear) error
 	if err != nil {
 		return err
 	}
-	if err := mysql.Create(&RedHistory{
-		Player: player,
-		Mode:   mode,
-		Bag:    d,
-	}).Error; err != nil {
+	if err := store.Histories().CreateRed(&RedHistory{
+		Player:    player,
+		Mode:      mode,
+		Bag:       d,
+		CreatedAt: time.Now(),
+	}); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnbalanced = errors.New("unbalanced ledger posting")
+)
+
+// 账户类型
+type Account int32
+
+const (
+	// 玩家余额, 对应 PlayerData.Money
+	AccountWallet Account = 1
+	// 玩家被冻结的钱, 对应未恢复的冻结记录
+	AccountFrozen Account = 2
+	// 系统外部, 注册赠送等凭空进入系统的钱记在这里, 余额为负
+	AccountExternal Account = 3
+)
+
+func (account Account) String() string {
+	switch account {
+	case AccountWallet:
+		return "wallet"
+	case AccountFrozen:
+		return "frozen"
+	case AccountExternal:
+		return "external"
+	}
+	return "unknown"
+}
+
+// 记账凭证
+type Posting int64
+
+// 记账凭证数据, 每次资金变动对应一个凭证
+type PostingData struct {
+	// 主键
+	Id Posting `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 原因
+	Reason string
+	// 创建时间
+	CreatedAt time.Time
+}
+
+func (PostingData) TableName() string {
+	return "ledger_postings"
+}
+
+// 分录数据, 同一凭证下所有分录的数额之和为 0
+type EntryData struct {
+	// 主键
+	Id int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 所属凭证
+	Posting Posting `gorm:"index"`
+	// 账户类型
+	Account Account `gorm:"index:idx_ledger_entries_account"`
+	// 账户所属玩家, 外部账户为 0
+	Player Player `gorm:"index:idx_ledger_entries_account"`
+	// 数额, 正数为转入
+	Number int64
+}
+
+func (EntryData) TableName() string {
+	return "ledger_entries"
+}
+
+// 对账差异
+type Drift struct {
+	Player  Player
+	Account Account
+	// 按分录计算的余额
+	Ledger int64
+	// 玩家表或冻结记录中的余额
+	Actual int64
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type ledgerEntry struct {
+	Account Account
+	Player  Player
+	Number  int64
+}
+
+// 待记账的凭证, 收集同一笔交易的所有分录后一次写入
+type ledgerPosting struct {
+	Reason  string
+	Entries []ledgerEntry
+}
+
+// 记账, 所有分录的数额之和必须为 0, 数额全为 0 时不记账
+func post(s Store, reason string, entries ...ledgerEntry) error {
+	var sum int64
+	empty := true
+	for _, entry := range entries {
+		sum += entry.Number
+		if entry.Number != 0 {
+			empty = false
+		}
+	}
+	if sum != 0 {
+		return errors.WithMessage(ErrUnbalanced, reason)
+	}
+	if empty {
+		return nil
+	}
+
+	posting := &PostingData{
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if err := s.Ledger().CreatePosting(posting); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Number == 0 {
+			continue
+		}
+		if err := s.Ledger().CreateEntry(&EntryData{
+			Posting: posting.Id,
+			Account: entry.Account,
+			Player:  entry.Player,
+			Number:  entry.Number,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 按分录重新计算每个玩家的余额与冻结, 返回与玩家表及冻结记录不一致的差异和不平衡的凭证
+func Reconcile(option Option) ([]*Drift, []Posting, error) {
+	db, err := openGorm(option)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	var drifts []*Drift
+	var unbalanced []Posting
+	err = (&gormStore{db: db}).Transaction(func(s Store) (err error) {
+		drifts, unbalanced, err = reconcile(s)
+		return err
+	})
+	return drifts, unbalanced, err
+}
+
+func reconcile(s Store) ([]*Drift, []Posting, error) {
+	unbalanced, err := s.Ledger().FindUnbalanced()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wallets, err := s.Ledger().Balances(AccountWallet)
+	if err != nil {
+		return nil, nil, err
+	}
+	frozen, err := s.Ledger().Balances(AccountFrozen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var drifts []*Drift
+	compare := func(account Account, ledger, actual map[Player]int64) {
+		for player, number := range actual {
+			if ledger[player] != number {
+				drifts = append(drifts, &Drift{player, account, ledger[player], number})
+			}
+		}
+		for player, number := range ledger {
+			if _, being := actual[player]; !being && number != 0 {
+				drifts = append(drifts, &Drift{player, account, number, 0})
+			}
+		}
+	}
+
+	money := make(map[Player]int64, len(wallets))
+	for after := Player(0); ; {
+		players, err := s.Players().FindAfter(after, 1000)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(players) == 0 {
+			break
+		}
+		for _, player := range players {
+			money[player.Id] = player.Money
+		}
+		after = players[len(players)-1].Id
+	}
+	compare(AccountWallet, wallets, money)
+
+	freezes, err := s.Freezes().FindUnrecovered()
+	if err != nil {
+		return nil, nil, err
+	}
+	freezing := make(map[Player]int64, len(frozen))
+	for _, freeze := range freezes {
+		freezing[freeze.Player] += freeze.Number
+	}
+	compare(AccountFrozen, frozen, freezing)
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Player != drifts[j].Player {
+			return drifts[i].Player < drifts[j].Player
+		}
+		return drifts[i].Account < drifts[j].Account
+	})
+
+	return drifts, unbalanced, nil
+}
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrResetNotEmpty    = errors.New("refuse to reset non-empty production database")
+	ErrRollbackNotEmpty = errors.New("refuse to drop tables of non-empty production database")
+)
+
+// 已应用的迁移
+type SchemaVersion struct {
+	// 版本号
+	Version int32 `gorm:"primary_key;auto_increment:false"`
+	// 名称
+	Name string
+	// 应用时间
+	AppliedAt time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_versions"
+}
+
+// 迁移步骤, 版本号从 1 开始连续递增, 已发布的步骤不能再修改
+type migration struct {
+	Version int32
+	Name    string
+	Up      func(db *gorm.DB) error
+	Down    func(db *gorm.DB) error
+	// 回滚时删除保存数据的表, 生产环境中存在系统玩家以外的玩家时拒绝回滚
+	DropsData bool
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version   int32
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// 迁移到指定版本, version 为 0 时迁移到最新版本
+func Migrate(option Option, version int32) error {
+	db, err := openGorm(option)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrateUp(db, version)
+}
+
+// 回滚最近应用的 steps 个迁移
+func Rollback(option Option, steps int) error {
+	db, err := openGorm(option)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrateDown(db, steps, option.Production)
+}
+
+// 查询所有迁移的状态
+func Migrations(option Option) ([]*MigrationStatus, error) {
+	db, err := openGorm(option)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []*MigrationStatus
+	for _, m := range migrations {
+		status := &MigrationStatus{
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if version, being := applied[m.Version]; being {
+			status.Applied = true
+			status.AppliedAt = version.AppliedAt
+		}
+		r = append(r, status)
+	}
+	return r, nil
+}
+
+func appliedVersions(db *gorm.DB) (map[int32]*SchemaVersion, error) {
+	if err := db.AutoMigrate(new(SchemaVersion)).Error; err != nil {
+		return nil, err
+	}
+
+	var versions []*SchemaVersion
+	if err := db.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	r := make(map[int32]*SchemaVersion, len(versions))
+	for _, version := range versions {
+		r[version.Version] = version
+	}
+	return r, nil
+}
+
+func migrateUp(db *gorm.DB, version int32) error {
+	latest := migrations[len(migrations)-1].Version
+	if version == 0 {
+		version = latest
+	}
+	if version < 0 || version > latest {
+		return fmt.Errorf("unknown schema version %d, latest is %d", version, latest)
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if _, being := applied[m.Version]; being {
+			continue
+		}
+
+		ts := db.Begin()
+		if err := m.Up(ts); err != nil {
+			ts.Rollback()
+			return errors.WithMessage(err, fmt.Sprintf("migrate %d %s failed", m.Version, m.Name))
+		}
+		if err := ts.Create(&SchemaVersion{
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: time.Now(),
+		}).Error; err != nil {
+			ts.Rollback()
+			return err
+		}
+		if err := ts.Commit().Error; err != nil {
+			return err
+		}
+
+		log.WithFields(logrus.Fields{
+			"version": m.Version,
+			"name":    m.Name,
+		}).Infoln("migration applied")
+	}
+
+	return nil
+}
+
+func migrateDown(db *gorm.DB, steps int, production bool) error {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	var versions []int32
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+
+	var rollbacks []*migration
+	dropsData := false
+	for i := 0; i < steps && i < len(versions); i++ {
+		var m *migration
+		for _, x := range migrations {
+			if x.Version == versions[i] {
+				m = x
+			}
+		}
+		if m == nil {
+			return fmt.Errorf("unknown applied schema version %d", versions[i])
+		}
+		rollbacks = append(rollbacks, m)
+		dropsData = dropsData || m.DropsData
+	}
+
+	// 在回滚任何步骤之前检查, 不会只回滚一部分
+	if dropsData {
+		count, err := countPlayers(db)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if production {
+				return ErrRollbackNotEmpty
+			}
+			log.WithFields(logrus.Fields{
+				"players": count,
+			}).Warnln("rollback non-empty database")
+		}
+	}
+
+	for _, m := range rollbacks {
+		ts := db.Begin()
+		if err := m.Down(ts); err != nil {
+			ts.Rollback()
+			return errors.WithMessage(err, fmt.Sprintf("rollback %d %s failed", m.Version, m.Name))
+		}
+		if err := ts.Delete(&SchemaVersion{Version: m.Version}).Error; err != nil {
+			ts.Rollback()
+			return err
+		}
+		if err := ts.Commit().Error; err != nil {
+			return err
+		}
+
+		log.WithFields(logrus.Fields{
+			"version": m.Version,
+			"name":    m.Name,
+		}).Infoln("migration rolled back")
+	}
+
+	return nil
+}
+
+// 系统玩家以外的玩家数量, 还没有 players 表时为 0
+func countPlayers(db *gorm.DB) (int, error) {
+	if !db.HasTable("players") {
+		return 0, nil
+	}
+	count := 0
+	if err := db.Table("players").Where("id <> ?", DefaultSupervisor).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// 删除所有表, 生产环境中存在系统玩家以外的玩家时拒绝执行
+func resetTables(db *gorm.DB, production bool) error {
+	count, err := countPlayers(db)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		if production {
+			return ErrResetNotEmpty
+		}
+		log.WithFields(logrus.Fields{
+			"players": count,
+		}).Warnln("reset non-empty database")
+	}
+
+	return db.DropTableIfExists(append(tables, new(SchemaVersion))...).Error
+}
+
+// 将整数列改为 64 位, 列已是 64 位时跳过, 只有 MySQL 需要, SQLite 的整数总是 64 位
+func widenColumn(db *gorm.DB, table, column string) error {
+	if db.Dialect().GetName() != DriverMySQL {
+		return nil
+	}
+
+	var dataType string
+	if err := db.Raw("select data_type from information_schema.columns "+
+		"where table_schema = database() and table_name = ? and column_name = ?", table, column).
+		Row().Scan(&dataType); err != nil {
+		return err
+	}
+	if dataType == "bigint" {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("alter table `%s` modify `%s` bigint", table, column)).Error
+}
+
+// 将整数列改回 32 位, 存在超出范围的值时失败
+func narrowColumn(db *gorm.DB, table, column string) error {
+	if db.Dialect().GetName() != DriverMySQL {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("alter table `%s` modify `%s` int", table, column)).Error
+}
+
+// 在 players 表上创建写入变更通知的触发器, 已存在时重建
+// MySQL 开启 binlog 时需要 SUPER 权限或 log_bin_trust_function_creators = 1
+func createChangeTriggers(db *gorm.DB) error {
+	if err := dropChangeTriggers(db); err != nil {
+		return err
+	}
+
+	now, body := "now()", "for each row %s"
+	if db.Dialect().GetName() != DriverMySQL {
+		now, body = "datetime('now')", "begin %s; end"
+	}
+	for _, trigger := range []struct{ name, event, row string }{
+		{"players_updated", "update", "new"},
+		{"players_deleted", "delete", "old"},
+	} {
+		insert := fmt.Sprintf("insert into player_changes (player, created_at) values (%s.id, %s)", trigger.row, now)
+		if err := db.Exec(fmt.Sprintf("create trigger %s after %s on players "+body,
+			trigger.name, trigger.event, insert)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropChangeTriggers(db *gorm.DB) error {
+	for _, name := range []string{"players_updated", "players_deleted"} {
+		if err := db.Exec("drop trigger if exists " + name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 所有表, 新增表时同时加入
+var tables = []interface{}{
+	new(Configuration),
+	new(PlayerData),
+	new(PlayerChangeData),
+	new(FreezeData),
+	new(TransactionData),
+	new(PostingData),
+	new(EntryData),
+	new(SettlementData),
+
+	new(CowHistory),
+	new(GomokuHistory),
+	new(Lever28History),
+	new(RedHistory),
+	new(HistoryArchiveData),
+	new(HistorySummaryData),
+}
+
+// 所有迁移, 按版本号顺序追加
+// 每个步骤使用 migrations_schema.go 中该版本冻结的表结构, 按表名修改列与索引, 不引用当前的模型
+var migrations = []*migration{
+	{
+		Version: 1,
+		Name:    "create_tables",
+		Up: func(db *gorm.DB) error {
+			// 兼容引入迁移之前由 AutoMigrate 建立的表
+			if err := db.AutoMigrate(
+				new(configurationV1),
+				new(playerV1),
+				new(freezeV1),
+				new(transactionV1),
+				new(cowHistoryV1),
+				new(gomokuHistoryV1),
+				new(lever28HistoryV1),
+				new(redHistoryV1),
+			).Error; err != nil {
+				return err
+			}
+			if db.Dialect().GetName() == DriverMySQL {
+				return db.Exec("alter table players AUTO_INCREMENT = 100000;").Error
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(
+				new(configurationV1),
+				new(playerV1),
+				new(freezeV1),
+				new(transactionV1),
+				new(cowHistoryV1),
+				new(gomokuHistoryV1),
+				new(lever28HistoryV1),
+				new(redHistoryV1),
+			).Error
+		},
+		DropsData: true,
+	},
+	{
+		Version: 2,
+		Name:    "create_ledger",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(new(postingV2), new(entryV2)).Error; err != nil {
+				return err
+			}
+
+			count := 0
+			if err := db.Model(new(postingV2)).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			// 以现有的余额与未恢复的冻结作为期初余额, 来源记为外部账户
+			opening := &postingV2{
+				Reason:    "ledger.opening",
+				CreatedAt: time.Now(),
+			}
+			if err := db.Create(opening).Error; err != nil {
+				return err
+			}
+			if err := db.Exec("insert into ledger_entries (posting, account, player, number) "+
+				"select ?, ?, id, money from players where money <> 0",
+				opening.Id, AccountWallet).Error; err != nil {
+				return err
+			}
+			if err := db.Exec("insert into ledger_entries (posting, account, player, number) "+
+				"select ?, ?, player, sum(number) from freezes where recovered = ? group by player",
+				opening.Id, AccountFrozen, false).Error; err != nil {
+				return err
+			}
+
+			var total struct{ Number int64 }
+			if err := db.Model(new(entryV2)).
+				Select("coalesce(sum(number), 0) as number").
+				Where("posting = ?", opening.Id).
+				Scan(&total).Error; err != nil {
+				return err
+			}
+			if total.Number == 0 {
+				return nil
+			}
+			return db.Create(&entryV2{
+				Posting: opening.Id,
+				Account: int32(AccountExternal),
+				Number:  total.Number * (-1),
+			}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(new(postingV2), new(entryV2)).Error
+		},
+		DropsData: true,
+	},
+	{
+		Version: 3,
+		Name:    "create_settlements",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(new(settlementV3)).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(new(settlementV3)).Error
+		},
+		DropsData: true,
+	},
+	{
+		Version: 4,
+		Name:    "widen_money",
+		// 新版本的代码同时兼容 32 位与 64 位的列, 可以先发布再在低峰期执行迁移
+		// MySQL 修改列类型时会复制表并阻塞写入, 大表可先用在线变更工具改为 bigint, 迁移会跳过已是 bigint 的列
+		Up: func(db *gorm.DB) error {
+			if err := widenColumn(db, "players", "money"); err != nil {
+				return err
+			}
+			if err := widenColumn(db, "freezes", "number"); err != nil {
+				return err
+			}
+			return widenColumn(db, "transactions", "number")
+		},
+		Down: func(db *gorm.DB) error {
+			if err := narrowColumn(db, "players", "money"); err != nil {
+				return err
+			}
+			if err := narrowColumn(db, "freezes", "number"); err != nil {
+				return err
+			}
+			return narrowColumn(db, "transactions", "number")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_freeze_recovery",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(new(freezeV5)).Error
+		},
+		Down: func(db *gorm.DB) error {
+			// SQLite 不支持删除列, 保留的列在再次迁移时沿用
+			if db.Dialect().GetName() != DriverMySQL {
+				return nil
+			}
+			for _, column := range []string{"recover_attempts", "recover_error", "recover_failed_at"} {
+				if err := db.Table("freezes").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create_player_changes",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(new(playerChangeV6)).Error; err != nil {
+				return err
+			}
+			return createChangeTriggers(db)
+		},
+		Down: func(db *gorm.DB) error {
+			if err := dropChangeTriggers(db); err != nil {
+				return err
+			}
+			return db.DropTableIfExists(new(playerChangeV6)).Error
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_history_cursor",
+		Up: func(db *gorm.DB) error {
+			// 二八杠与红包战绩没有时间, 已有的记录取迁移时间, 早于之后写入的记录, 不影响按 (created_at, id) 的顺序
+			if err := db.AutoMigrate(new(lever28HistoryV7), new(redHistoryV7)).Error; err != nil {
+				return err
+			}
+			now := time.Now()
+			for _, table := range []string{"history_lever28", "history_red"} {
+				if err := db.Table(table).Where("created_at is null").UpdateColumn("created_at", now).Error; err != nil {
+					return err
+				}
+			}
+			for _, index := range historyCursorIndexes {
+				if err := db.Table(index.Table).AddIndex(index.Name, index.Columns...).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			for _, index := range historyCursorIndexes {
+				if err := db.Table(index.Table).RemoveIndex(index.Name).Error; err != nil {
+					return err
+				}
+			}
+			// SQLite 不支持删除列, 保留的列在再次迁移时沿用
+			if db.Dialect().GetName() != DriverMySQL {
+				return nil
+			}
+			for _, table := range []string{"history_lever28", "history_red"} {
+				if err := db.Table(table).DropColumn("created_at").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create_history_archives",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(new(historyArchiveV8), new(historySummaryV8)).Error; err != nil {
+				return err
+			}
+			// 按时间读取待归档的记录
+			for _, table := range archiveTablesV8 {
+				if err := db.Table(table).AddIndex(archiveIndex(table), "created_at").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			for _, table := range archiveTablesV8 {
+				if err := db.Table(table).RemoveIndex(archiveIndex(table)).Error; err != nil {
+					return err
+				}
+			}
+			return db.DropTableIfExists(new(historyArchiveV8), new(historySummaryV8)).Error
+		},
+		DropsData: true,
+	},
+}
+
+// 战绩分页使用的索引, 列顺序与查询条件和排序一致
+var historyCursorIndexes = []struct {
+	Table   string
+	Name    string
+	Columns []string
+}{
+	{"history_cow", "idx_history_cow_cursor", []string{"player", "created_at", "id"}},
+	{"history_gomoku", "idx_history_gomoku_cursor", []string{"player", "created_at", "id"}},
+	{"history_lever28", "idx_history_lever28_cursor", []string{"player", "mode", "created_at", "id"}},
+	{"history_red", "idx_history_red_cursor", []string{"player", "mode", "created_at", "id"}},
+}
+
+// 版本 8 时的归档来源表, 之后新增的来源表在新的迁移步骤中建立索引
+var archiveTablesV8 = []string{"history_cow", "history_gomoku", "history_lever28", "history_red"}
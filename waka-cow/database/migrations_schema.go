@@ -0,0 +1,245 @@
+package database
+
+import (
+	"time"
+)
+
+// 迁移步骤使用的表结构, 按引入的版本冻结, 修改模型时不能修改这里, 需要新增迁移步骤
+// 字段使用基础类型, 不随模型中的类型定义变化
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 1, 引入迁移之前由 AutoMigrate 建立的表
+
+type configurationV1 struct {
+	Id     int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Type   string
+	Value1 string `gorm:"column:val1;type:text"`
+	Value2 string `gorm:"column:val2;type:text"`
+	Value3 string `gorm:"column:val3;type:text"`
+	Value4 string `gorm:"column:val4;type:text"`
+}
+
+func (configurationV1) TableName() string {
+	return "configurations"
+}
+
+type playerV1 struct {
+	Id            int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	CreatedAt     time.Time
+	WechatUnionid string `gorm:"index;unique;column:wechat_unionid"`
+	Nickname      string
+	Head          string
+	Token         string `gorm:"index;unique"`
+	Money         int32
+	Vip           time.Time
+	Wechat        string
+	Name          string
+	Idcard        string
+	Supervisor    int32
+	Ban           int32
+	VictoryWeight int32
+}
+
+func (playerV1) TableName() string {
+	return "players"
+}
+
+type freezeV1 struct {
+	Id        int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32
+	Number    int32
+	Recovered bool
+	CreatedAt time.Time
+}
+
+func (freezeV1) TableName() string {
+	return "freezes"
+}
+
+type transactionV1 struct {
+	Id        int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32
+	Target    int32
+	Number    int32
+	Type      int32
+	Reason    string
+	CreatedAt time.Time
+}
+
+func (transactionV1) TableName() string {
+	return "transactions"
+}
+
+type cowHistoryV1 struct {
+	Id        int32  `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32  `gorm:"index"`
+	Payload   []byte `gorm:"type:mediumblob"`
+	CreatedAt time.Time
+}
+
+func (cowHistoryV1) TableName() string {
+	return "history_cow"
+}
+
+type gomokuHistoryV1 struct {
+	Id        int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	Opponent  int32
+	Cost      int32
+	CreatedAt time.Time
+}
+
+func (gomokuHistoryV1) TableName() string {
+	return "history_gomoku"
+}
+
+type lever28HistoryV1 struct {
+	Id     int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player int32 `gorm:"index"`
+	Mode   int32
+	Bag    []byte `gorm:"type:mediumblob"`
+}
+
+func (lever28HistoryV1) TableName() string {
+	return "history_lever28"
+}
+
+type redHistoryV1 struct {
+	Id     int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player int32 `gorm:"index"`
+	Mode   int32
+	Bag    []byte `gorm:"type:mediumblob"`
+}
+
+func (redHistoryV1) TableName() string {
+	return "history_red"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 2, 账本
+
+type postingV2 struct {
+	Id        int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Reason    string
+	CreatedAt time.Time
+}
+
+func (postingV2) TableName() string {
+	return "ledger_postings"
+}
+
+type entryV2 struct {
+	Id      int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Posting int64 `gorm:"index"`
+	Account int32 `gorm:"index:idx_ledger_entries_account"`
+	Player  int32 `gorm:"index:idx_ledger_entries_account"`
+	Number  int64
+}
+
+func (entryV2) TableName() string {
+	return "ledger_entries"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 3, 结算记录
+
+type settlementV3 struct {
+	Id        int64  `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Game      string `gorm:"type:varchar(32);unique_index:idx_settlements_key"`
+	Room      int64  `gorm:"unique_index:idx_settlements_key"`
+	Round     int32  `gorm:"unique_index:idx_settlements_key"`
+	CreatedAt time.Time
+}
+
+func (settlementV3) TableName() string {
+	return "settlements"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 5, 冻结记录的恢复状态
+
+type freezeV5 struct {
+	Id              int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player          int32
+	Number          int64
+	Recovered       bool
+	CreatedAt       time.Time
+	RecoverAttempts int32
+	RecoverError    string `gorm:"type:text"`
+	RecoverFailedAt *time.Time
+}
+
+func (freezeV5) TableName() string {
+	return "freezes"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 6, 玩家变更通知
+
+type playerChangeV6 struct {
+	Id        int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (playerChangeV6) TableName() string {
+	return "player_changes"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 7, 二八杠与红包战绩的时间
+
+type lever28HistoryV7 struct {
+	Id        int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	Mode      int32
+	Bag       []byte `gorm:"type:mediumblob"`
+	CreatedAt time.Time
+}
+
+func (lever28HistoryV7) TableName() string {
+	return "history_lever28"
+}
+
+type redHistoryV7 struct {
+	Id        int32 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	Mode      int32
+	Bag       []byte `gorm:"type:mediumblob"`
+	CreatedAt time.Time
+}
+
+func (redHistoryV7) TableName() string {
+	return "history_red"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 8, 战绩归档
+
+type historyArchiveV8 struct {
+	Id         int64  `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Source     string `gorm:"size:64;unique_index:idx_history_archives_source"`
+	SourceId   int64  `gorm:"unique_index:idx_history_archives_source"`
+	Player     int32  `gorm:"index"`
+	CreatedAt  time.Time
+	ArchivedAt time.Time `gorm:"index"`
+	Payload    []byte    `gorm:"type:mediumblob"`
+}
+
+func (historyArchiveV8) TableName() string {
+	return "history_archives"
+}
+
+type historySummaryV8 struct {
+	Id     int64     `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player int32     `gorm:"unique_index:idx_history_summaries_key"`
+	Source string    `gorm:"size:64;unique_index:idx_history_summaries_key"`
+	Mode   int32     `gorm:"unique_index:idx_history_summaries_key"`
+	Day    time.Time `gorm:"unique_index:idx_history_summaries_key"`
+	Total  int32
+	Number int64
+}
+
+func (historySummaryV8) TableName() string {
+	return "history_summaries"
+}
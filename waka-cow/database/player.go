@@ -1,12 +1,7 @@
 package database
 
 import (
-	"sync"
 	"time"
-
-	"github.com/jinzhu/gorm"
-
-	"github.com/liuhan907/waka/waka-cow/conf"
 )
 
 const (
@@ -47,7 +42,7 @@ type PlayerData struct {
 	Token string `gorm:"index;unique"`
 
 	// 钱
-	Money int32
+	Money int64
 	// VIP 时间
 	Vip time.Time
 
@@ -74,15 +69,6 @@ func (PlayerData) TableName() string {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-var (
-	playersLock     sync.RWMutex
-	playersById     = make(map[Player]*PlayerData)
-	playersByWechat = make(map[string]*PlayerData)
-	playersByToken  = make(map[string]*PlayerData)
-)
-
-// ---------------------------------------------------------------------------------------------------------------------
-
 // 注册玩家
 func RegisterPlayer(uid, nickname string, head, token string) (*PlayerData, error) {
 	player := &PlayerData{
@@ -91,206 +77,153 @@ func RegisterPlayer(uid, nickname string, head, token string) (*PlayerData, erro
 		Nickname:      nickname,
 		Head:          head,
 		Token:         token,
-		Money:         conf.Option.Hall.RegisterMoney,
+		Money:         int64(GetSettings().Hall.RegisterMoney),
 		Vip:           time.Now(),
 		Supervisor:    DefaultSupervisor,
 		VictoryWeight: DefaultVictoryWeight,
 	}
-	if err := mysql.Create(player).Error; err != nil {
+	if err := store.Transaction(func(s Store) error {
+		if err := s.Players().Create(player); err != nil {
+			return err
+		}
+		return post(s, "player.register",
+			ledgerEntry{AccountExternal, 0, player.Money * (-1)},
+			ledgerEntry{AccountWallet, player.Id, player.Money},
+		)
+	}); err != nil {
 		return nil, err
 	}
 
-	playersLock.Lock()
-
-	playersById[player.Id] = player
-	playersByWechat[player.WechatUnionid] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, nil
 }
 
 // 更新玩家登录信息
 func UpdatePlayerLogin(id Player, nickname string, head, token string) error {
-	if err := mysql.Model(&PlayerData{}).Where("id = ?", id).Update(&PlayerData{
+	if err := store.Players().Update(id, &PlayerData{
 		Nickname: nickname,
 		Head:     head,
 		Token:    token,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	playersLock.Lock()
-
-	player, being := playersById[id]
-	if being {
-		delete(playersByToken, player.Token)
-
+	cache.update(id, func(player *PlayerData) {
 		player.Nickname = nickname
 		player.Head = head
 		player.Token = token
-
-		playersByToken[player.Token] = player
-	}
-
-	playersLock.Unlock()
+	})
 
 	return nil
 }
 
 // 更新玩家代理信息
 func UpdatePlayerSupervisor(id, supervisor Player) error {
-	if err := mysql.Model(&PlayerData{}).Where("id = ?", id).Updates(&PlayerData{
+	if err := store.Players().Update(id, &PlayerData{
 		Supervisor: supervisor,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	playersLock.Lock()
-
-	player, being := playersById[id]
-	if being {
+	cache.update(id, func(player *PlayerData) {
 		player.Supervisor = supervisor
-	}
-
-	playersLock.Unlock()
+	})
 
 	return nil
 }
 
 // 更新玩家附加信息
 func UpdatePlayerExt(id Player, wechat, name, idcard string) error {
-	if err := mysql.Model(&PlayerData{}).Where("id = ?", id).Updates(&PlayerData{
+	if err := store.Players().Update(id, &PlayerData{
 		Wechat: wechat,
 		Name:   name,
 		Idcard: idcard,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	playersLock.Lock()
-
-	player, being := playersById[id]
-	if being {
+	cache.update(id, func(player *PlayerData) {
 		player.Wechat = wechat
 		player.Name = name
 		player.Idcard = idcard
-	}
-
-	playersLock.Unlock()
+	})
 
 	return nil
 }
 
 // 根据 Player 查询玩家
 func QueryPlayerByPlayer(id Player) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersById[id]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.get(id); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{
-		Id: id,
+	player, err := store.Players().Find(id)
+	if err != nil {
+		return nil, false, err
 	}
-	if err := mysql.First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
-
-	playersById[id] = player
-	playersByWechat[player.WechatUnionid] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, true, nil
 }
 
 // 根据 Token 查询玩家
 func QueryPlayerByToken(token string) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersByToken[token]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.getByKey(tokenKey(token)); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{}
-	if err := mysql.Where("token = ?", token).First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	player, err := store.Players().FindByToken(token)
+	if err != nil {
+		return nil, false, err
+	}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
-
-	playersById[player.Id] = player
-	playersByWechat[player.WechatUnionid] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, true, nil
 }
 
 // 根据 WechatUnionid 查询玩家
 func QueryPlayerByWechatUnionid(uid string) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersByWechat[uid]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.getByKey(wechatKey(uid)); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{}
-	if err := mysql.Where("wechat_uid = ?", uid).First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	player, err := store.Players().FindByWechatUnionid(uid)
+	if err != nil {
+		return nil, false, err
+	}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
-
-	playersById[player.Id] = player
-	playersByWechat[player.WechatUnionid] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, true, nil
 }
 
-// 删除缓存
-func RefreshCache(player Player) {
-	playersLock.Lock()
+// 批量查询玩家, 未缓存的玩家一次查询载入, 不存在的玩家不返回
+func QueryPlayers(ids []Player) (map[Player]*PlayerData, error) {
+	r, missing := cache.getMany(ids)
+	if len(missing) == 0 {
+		return r, nil
+	}
 
-	playerData, being := playersById[player]
-	if being {
-		delete(playersById, player)
-		delete(playersByWechat, playerData.WechatUnionid)
-		delete(playersByToken, playerData.Token)
+	players, err := store.Players().FindIn(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, player := range players {
+		cache.put(player)
+		r[player.Id] = player
 	}
 
-	playersLock.Unlock()
+	return r, nil
 }
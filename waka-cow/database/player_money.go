@@ -3,12 +3,12 @@ package database
 import (
 	"time"
 
-	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 )
 
 var (
 	ErrFreezeRecovered = errors.New("freeze recovered")
+	ErrFreezeNotFound  = errors.New("freeze not found")
 )
 
 // 冻结记录
@@ -21,32 +21,36 @@ type FreezeData struct {
 	// 冻结记录所属玩家
 	Player Player
 	// 被冻结的钱
-	Number int32
+	Number int64
 	// 已恢复
 	Recovered bool
 	// 创建时间
 	CreatedAt time.Time
+
+	// 启动时恢复失败的次数
+	RecoverAttempts int32
+	// 最近一次恢复失败的错误
+	RecoverError string `gorm:"type:text"`
+	// 最近一次恢复失败的时间
+	RecoverFailedAt *time.Time
 }
 
 func (FreezeData) TableName() string {
 	return "freezes"
 }
 
-func freezeMoney(ts *gorm.DB, id Player, number int32) (Freeze, error) {
-	if err := ts.Model(&PlayerData{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"money": gorm.Expr("money - ?", number),
-		},
-	).Error; err != nil {
+func freezeMoney(s Store, id Player, number int64) (Freeze, error) {
+	if err := s.Players().AddMoney(id, number*(-1)); err != nil {
 		return 0, err
 	}
 
-	player := PlayerData{
-		Id: id,
-	}
-	if err := ts.First(&player).Error; err != nil {
+	player, err := s.Players().Find(id)
+	if err != nil {
 		return 0, err
 	}
+	if player == nil {
+		return 0, ErrPlayerNotFound
+	}
 
 	if player.Money < 0 {
 		return 0, ErrMoneyNotEnough
@@ -57,33 +61,46 @@ func freezeMoney(ts *gorm.DB, id Player, number int32) (Freeze, error) {
 		Number:    number,
 		CreatedAt: time.Now(),
 	}
-	if err := ts.Create(&freezeData).Error; err != nil {
+	if err := s.Freezes().Create(&freezeData); err != nil {
+		return 0, err
+	}
+
+	if err := post(s, "freeze",
+		ledgerEntry{AccountWallet, id, number * (-1)},
+		ledgerEntry{AccountFrozen, id, number},
+	); err != nil {
 		return 0, err
 	}
 
 	return freezeData.Id, nil
 }
 
-func recoverFreezeMoney(ts *gorm.DB, id Freeze) (Player, int32, error) {
-	var freezeData FreezeData
-	if err := ts.Where("id = ?", id).First(&freezeData).Error; err != nil {
+func recoverFreezeMoney(s Store, id Freeze) (Player, int64, error) {
+	freezeData, err := s.Freezes().Find(id)
+	if err != nil {
 		return 0, 0, err
 	}
+	if freezeData == nil {
+		return 0, 0, ErrFreezeNotFound
+	}
 
 	if freezeData.Recovered {
 		return 0, 0, ErrFreezeRecovered
 	}
 
-	if err := ts.Model(&PlayerData{}).Where("id = ?", freezeData.Player).Updates(
-		map[string]interface{}{
-			"money": gorm.Expr("money + ?", freezeData.Number),
-		},
-	).Error; err != nil {
+	if err := s.Players().AddMoney(freezeData.Player, freezeData.Number); err != nil {
 		return 0, 0, err
 	}
 
 	freezeData.Recovered = true
-	if err := ts.Save(&freezeData).Error; err != nil {
+	if err := s.Freezes().Save(freezeData); err != nil {
+		return 0, 0, err
+	}
+
+	if err := post(s, "freeze.recover",
+		ledgerEntry{AccountFrozen, freezeData.Player, freezeData.Number * (-1)},
+		ledgerEntry{AccountWallet, freezeData.Player, freezeData.Number},
+	); err != nil {
 		return 0, 0, err
 	}
 
@@ -92,40 +109,61 @@ func recoverFreezeMoney(ts *gorm.DB, id Freeze) (Player, int32, error) {
 
 var (
 	ErrMoneyNotEnough = errors.New("money not enough")
+	ErrPlayerNotFound = errors.New("player not found")
 )
 
+// 改变玩家的钱, 同一凭证的所有改变全部执行后一起记账
 type modifyMoneyAction struct {
-	Player Player
-	Number int32
-	Before func(ts *gorm.DB, self *modifyMoneyAction) error
-	After  func(ts *gorm.DB, self *modifyMoneyAction) error
+	Player  Player
+	Number  int64
+	Posting *ledgerPosting
+	Before  func(s Store, self *modifyMoneyAction) error
+	After   func(s Store, self *modifyMoneyAction) error
 }
 
-func applyModifyMoneyActions(ts *gorm.DB, modifies []*modifyMoneyAction) error {
+func applyModifyMoneyActions(s Store, modifies []*modifyMoneyAction) error {
+	var postings []*ledgerPosting
 	for _, modify := range modifies {
+		if modify.Posting == nil {
+			return errors.WithMessage(ErrUnbalanced, "modify money without posting")
+		}
+
 		zeroCheck := false
 		if modify.Number < 0 {
 			zeroCheck = true
 		}
 		if modify.Before != nil {
-			if err := modify.Before(ts, modify); err != nil {
+			if err := modify.Before(s, modify); err != nil {
 				return err
 			}
 		}
-		if err := modifyMoney(ts, modify.Player, modify.Number, zeroCheck); err != nil {
+		if err := modifyMoney(s, modify.Player, modify.Number, zeroCheck); err != nil {
 			return err
 		}
+		if modify.Player != 0 {
+			if len(modify.Posting.Entries) == 0 {
+				postings = append(postings, modify.Posting)
+			}
+			modify.Posting.Entries = append(modify.Posting.Entries,
+				ledgerEntry{AccountWallet, modify.Player, modify.Number})
+		}
 		if modify.After != nil {
-			if err := modify.After(ts, modify); err != nil {
+			if err := modify.After(s, modify); err != nil {
 				return err
 			}
 		}
 	}
 
+	for _, posting := range postings {
+		if err := post(s, posting.Reason, posting.Entries...); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func modifyMoney(ts *gorm.DB, player Player, money int32, zeroCheck bool) error {
+func modifyMoney(s Store, player Player, money int64, zeroCheck bool) error {
 	if money == 0 {
 		return nil
 	}
@@ -133,22 +171,19 @@ func modifyMoney(ts *gorm.DB, player Player, money int32, zeroCheck bool) error
 		return nil
 	}
 
-	if err := ts.Model(&PlayerData{}).Where("id = ?", player).Updates(
-		map[string]interface{}{
-			"money": gorm.Expr("money + ?", money),
-		},
-	).Error; err != nil {
+	if err := s.Players().AddMoney(player, money); err != nil {
 		return err
 	}
 	if zeroCheck {
-		player := PlayerData{
-			Id: player,
-		}
-		if err := ts.First(&player).Error; err != nil {
+		playerData, err := s.Players().Find(player)
+		if err != nil {
 			return err
 		}
+		if playerData == nil {
+			return ErrPlayerNotFound
+		}
 
-		if player.Money < 0 {
+		if playerData.Money < 0 {
 			return ErrMoneyNotEnough
 		}
 	}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 报表周期
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// 时间所在周期的开始时间, 周从周一开始
+func (period Period) Start(t time.Time) (time.Time, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch period {
+	case PeriodDay:
+		return day, nil
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case PeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown period %q", period)
+}
+
+// 代理在一个周期内的提成
+type CommissionStatement struct {
+	// 周期开始时间
+	Period time.Time
+	// 各级下线产生的提成合计, 下标 0 为直属下线
+	Levels []int64
+	// 提成合计
+	Total int64
+	// 提成笔数
+	Count int32
+}
+
+// 下线玩家
+type DownlinePlayer struct {
+	Player   Player
+	Nickname string
+	// 与代理相隔的级数, 直属下线为 1
+	Level int32
+	// 直接上级
+	Supervisor Player
+	// 注册时间
+	CreatedAt time.Time
+	// 当前余额
+	Money int64
+
+	// 期间内的支付笔数
+	Payments int32
+	// 期间内的支付合计
+	Paid int64
+	// 期间内为代理产生的提成
+	Commission int64
+}
+
+// 支付记录汇总
+type PaymentSum struct {
+	Count  int32
+	Number int64
+}
+
+// 提成记录的级数, 不是提成记录时返回 0
+func tipLevel(reason string) int {
+	i := strings.LastIndex(reason, ".tip")
+	if i < 0 {
+		return 0
+	}
+	level, err := strconv.Atoi(reason[i+len(".tip"):])
+	if err != nil || level <= 0 {
+		return 0
+	}
+	return level
+}
+
+// 按周期汇总代理在 [from, to) 内收到的提成, 按周期开始时间排序, 没有提成的周期不返回
+func QueryCommissionStatements(agent Player, period Period, from, to time.Time) ([]*CommissionStatement, error) {
+	if _, err := period.Start(from); err != nil {
+		return nil, err
+	}
+
+	tips, err := store.Transactions().FindTips(agent, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := make(map[time.Time]*CommissionStatement)
+	for _, tip := range tips {
+		level := tipLevel(tip.Reason)
+		if level == 0 {
+			continue
+		}
+		start, _ := period.Start(tip.CreatedAt.In(from.Location()))
+		statement, being := statements[start]
+		if !being {
+			statement = &CommissionStatement{Period: start}
+			statements[start] = statement
+		}
+		for len(statement.Levels) < level {
+			statement.Levels = append(statement.Levels, 0)
+		}
+		statement.Levels[level-1] += tip.Number
+		statement.Total += tip.Number
+		statement.Count++
+	}
+
+	r := make([]*CommissionStatement, 0, len(statements))
+	for _, statement := range statements {
+		r = append(r, statement)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Period.Before(r[j].Period)
+	})
+	return r, nil
+}
+
+// 沿上级关系查询代理 depth 级以内的下线, 并统计每个下线在 [from, to) 内的活跃情况
+func QueryDownline(agent Player, depth int32, from, to time.Time) ([]*DownlinePlayer, error) {
+	var r []*DownlinePlayer
+	byPlayer := make(map[Player]*DownlinePlayer)
+
+	visited := map[Player]bool{agent: true}
+	supervisors := []Player{agent}
+	for level := int32(1); level <= depth && len(supervisors) > 0; level++ {
+		players, err := store.Players().FindBySupervisors(supervisors)
+		if err != nil {
+			return nil, err
+		}
+		supervisors = supervisors[:0]
+		for _, player := range players {
+			if visited[player.Id] {
+				continue
+			}
+			visited[player.Id] = true
+			supervisors = append(supervisors, player.Id)
+
+			downline := &DownlinePlayer{
+				Player:     player.Id,
+				Nickname:   player.Nickname,
+				Level:      level,
+				Supervisor: player.Supervisor,
+				CreatedAt:  player.CreatedAt,
+				Money:      player.Money,
+			}
+			r = append(r, downline)
+			byPlayer[player.Id] = downline
+		}
+	}
+	if len(r) == 0 {
+		return r, nil
+	}
+
+	players := make([]Player, 0, len(r))
+	for _, downline := range r {
+		players = append(players, downline.Player)
+	}
+	payments, err := store.Transactions().SumPayments(players, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for player, payment := range payments {
+		byPlayer[player].Payments = payment.Count
+		byPlayer[player].Paid = payment.Number
+	}
+
+	tips, err := store.Transactions().FindTips(agent, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, tip := range tips {
+		if downline := byPlayer[tip.Target]; downline != nil {
+			downline.Commission += tip.Number
+		}
+	}
+
+	return r, nil
+}
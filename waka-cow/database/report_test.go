@@ -0,0 +1,197 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func createTestTransaction(t *testing.T, player, target Player, number int64, transactionType int32, reason string, at time.Time) {
+	if err := store.Transactions().Create(&TransactionData{
+		Player:    player,
+		Target:    target,
+		Number:    number,
+		Type:      transactionType,
+		Reason:    reason,
+		CreatedAt: at,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTipLevel(t *testing.T) {
+	cases := map[string]int{
+		"cow.flowing_cost.tip1": 1,
+		"cow.flowing_cost.tip3": 3,
+		"red.grab.tip12":        12,
+		"cow.flowing_cost.tip0": 0,
+		"cow.flowing_cost.tip":  0,
+		"cow.tipping.income":    0,
+		"cow.flowing_cost.pay":  0,
+	}
+	for reason, want := range cases {
+		if got := tipLevel(reason); got != want {
+			t.Errorf("tipLevel(%q) = %d, want %d", reason, got, want)
+		}
+	}
+}
+
+// 代理链 top <- agent <- mid <- leaf <- deep, 另有直属下线 sibling
+type testAgentChain struct {
+	top, agent, mid, leaf, deep, sibling Player
+}
+
+func createTestAgentChain(t *testing.T) *testAgentChain {
+	chain := &testAgentChain{}
+	chain.top = createTestPlayer(t, 0, DefaultSupervisor)
+	chain.agent = createTestPlayer(t, 0, chain.top)
+	chain.mid = createTestPlayer(t, 0, chain.agent)
+	chain.leaf = createTestPlayer(t, 100000, chain.mid)
+	chain.deep = createTestPlayer(t, 0, chain.leaf)
+	chain.sibling = createTestPlayer(t, 0, chain.agent)
+	return chain
+}
+
+func TestQueryCommissionStatements(t *testing.T) {
+	openTestStore(t)
+	chain := createTestAgentChain(t)
+
+	// 2026-09-14 为周一
+	monday := time.Date(2026, 9, 14, 0, 0, 0, 0, time.Local)
+	at := func(days int, hours float64) time.Time {
+		return monday.AddDate(0, 0, days).Add(time.Duration(hours * float64(time.Hour)))
+	}
+	createTestTransaction(t, chain.agent, chain.sibling, 100, 2, "cow.flowing_cost.tip1", at(0, 10))
+	// 周日仍属于周一开始的一周
+	createTestTransaction(t, chain.agent, chain.leaf, 20, 2, "cow.flowing_cost.tip2", at(6, 23))
+	createTestTransaction(t, chain.agent, chain.sibling, 50, 2, "red.grab.tip1", at(7, 0.5))
+	createTestTransaction(t, chain.agent, chain.leaf, 7, 2, "cow.flowing_cost.tip2", at(17, 12))
+	// 不是提成或不属于代理的记录不计入
+	createTestTransaction(t, chain.agent, chain.sibling, 1000, 2, "cow.order_cost.income", at(0, 11))
+	createTestTransaction(t, chain.agent, chain.sibling, 500, 2, "cow.tipping.income", at(0, 12))
+	createTestTransaction(t, chain.agent, chain.sibling, 300, 1, "cow.flowing_cost.pay", at(0, 13))
+	createTestTransaction(t, chain.mid, chain.leaf, 40, 2, "cow.flowing_cost.tip1", at(0, 14))
+
+	september := time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)
+	november := time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		name     string
+		period   Period
+		from, to time.Time
+		want     []*CommissionStatement
+	}{
+		{"week", PeriodWeek, september, november, []*CommissionStatement{
+			{Period: monday, Levels: []int64{100, 20}, Total: 120, Count: 2},
+			{Period: at(7, 0), Levels: []int64{50}, Total: 50, Count: 1},
+			{Period: at(14, 0), Levels: []int64{0, 7}, Total: 7, Count: 1},
+		}},
+		{"month", PeriodMonth, september, november, []*CommissionStatement{
+			{Period: september, Levels: []int64{150, 20}, Total: 170, Count: 3},
+			{Period: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), Levels: []int64{0, 7}, Total: 7, Count: 1},
+		}},
+		{"day", PeriodDay, monday, at(7, 0), []*CommissionStatement{
+			{Period: monday, Levels: []int64{100}, Total: 100, Count: 1},
+			{Period: at(6, 0), Levels: []int64{0, 20}, Total: 20, Count: 1},
+		}},
+		// 只统计 [from, to) 内的提成
+		{"range", PeriodWeek, at(7, 0), at(17, 0), []*CommissionStatement{
+			{Period: at(7, 0), Levels: []int64{50}, Total: 50, Count: 1},
+		}},
+	}
+	for _, c := range cases {
+		statements, err := QueryCommissionStatements(chain.agent, c.period, c.from, c.to)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(statements) != len(c.want) {
+			t.Fatalf("%s: statements %d, want %d", c.name, len(statements), len(c.want))
+		}
+		for i, statement := range statements {
+			want := c.want[i]
+			if !statement.Period.Equal(want.Period) ||
+				!reflect.DeepEqual(statement.Levels, want.Levels) ||
+				statement.Total != want.Total ||
+				statement.Count != want.Count {
+				t.Errorf("%s: statement %d = %+v, want %+v", c.name, i, statement, want)
+			}
+		}
+	}
+
+	if _, err := QueryCommissionStatements(chain.agent, Period("year"), september, november); err == nil {
+		t.Fatal("queried unknown period")
+	}
+}
+
+func TestQueryDownline(t *testing.T) {
+	openTestStore(t)
+	chain := createTestAgentChain(t)
+	victory := createTestPlayer(t, 0, DefaultSupervisor)
+
+	// leaf 输的钱按 mid, agent, top 三级提成
+	from := time.Now().Add(-time.Hour)
+	if _, err := CowFlowingCostSettle(1, 1, []*CowFlowingCostData{
+		{Victory: victory, Loser: chain.leaf, Number: 10000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	createTestTransaction(t, chain.sibling, victory, 300, 1, "cow.order_cost.pay", time.Now())
+	createTestTransaction(t, chain.sibling, victory, 200, 1, "cow.order_cost.pay", time.Now())
+	createTestTransaction(t, chain.agent, chain.sibling, 40, 2, "cow.order_cost.tip1", time.Now())
+	// 期间外的支付与提成不计入
+	createTestTransaction(t, chain.sibling, victory, 900, 1, "cow.order_cost.pay", from.Add(-time.Hour))
+	createTestTransaction(t, chain.agent, chain.sibling, 90, 2, "cow.order_cost.tip1", from.Add(-time.Hour))
+	to := time.Now().Add(time.Hour)
+
+	statements, err := QueryCommissionStatements(chain.agent, PeriodDay, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 || !reflect.DeepEqual(statements[0].Levels, []int64{40, 32}) || statements[0].Total != 72 {
+		t.Fatalf("statements %+v", statements)
+	}
+
+	type downline struct {
+		Level      int32
+		Supervisor Player
+		Payments   int32
+		Paid       int64
+		Commission int64
+	}
+	cases := []struct {
+		depth int32
+		want  map[Player]downline
+	}{
+		{1, map[Player]downline{
+			chain.mid:     {1, chain.agent, 0, 0, 0},
+			chain.sibling: {1, chain.agent, 2, 500, 40},
+		}},
+		{3, map[Player]downline{
+			chain.mid:     {1, chain.agent, 0, 0, 0},
+			chain.sibling: {1, chain.agent, 2, 500, 40},
+			chain.leaf:    {2, chain.mid, 1, 10000, 32},
+			chain.deep:    {3, chain.leaf, 0, 0, 0},
+		}},
+	}
+	for _, c := range cases {
+		players, err := QueryDownline(chain.agent, c.depth, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[Player]downline)
+		for i, player := range players {
+			if i > 0 && player.Level < players[i-1].Level {
+				t.Errorf("depth %d: downline %d at level %d after level %d", c.depth, player.Player, player.Level, players[i-1].Level)
+			}
+			got[player.Player] = downline{player.Level, player.Supervisor, player.Payments, player.Paid, player.Commission}
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("depth %d: downline %+v, want %+v", c.depth, got, c.want)
+		}
+	}
+
+	// 没有下线时返回空
+	if players, err := QueryDownline(chain.deep, 3, from, to); err != nil || len(players) != 0 {
+		t.Fatalf("downline of deep = %+v, %v", players, err)
+	}
+	assertReconciled(t)
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"time"
+)
+
+// 玩家仓库
+type PlayerRepository interface {
+	// 创建玩家, 创建后回填主键
+	Create(player *PlayerData) error
+	// 查询玩家, 不存在时返回 nil
+	Find(id Player) (*PlayerData, error)
+	// 根据 Token 查询玩家, 不存在时返回 nil
+	FindByToken(token string) (*PlayerData, error)
+	// 根据 WechatUnionid 查询玩家, 不存在时返回 nil
+	FindByWechatUnionid(uid string) (*PlayerData, error)
+	// 更新玩家, 只更新 fields 中的非零值字段
+	Update(id Player, fields *PlayerData) error
+	// 增减玩家的钱
+	AddMoney(id Player, number int64) error
+	// 批量查询玩家, 不存在的玩家不返回
+	FindIn(ids []Player) ([]*PlayerData, error)
+	// 按主键顺序查询主键大于 after 的玩家
+	FindAfter(after Player, limit int32) ([]*PlayerData, error)
+	// 查询直接上级为 supervisors 之一的玩家
+	FindBySupervisors(supervisors []Player) ([]*PlayerData, error)
+}
+
+// 玩家变更通知仓库
+type PlayerChangeRepository interface {
+	// 查询最新的变更通知, 没有时返回 nil
+	Last() (*PlayerChangeData, error)
+	// 按主键顺序查询主键大于 after 的变更通知
+	FindAfter(after int64, limit int32) ([]*PlayerChangeData, error)
+	// 删除早于 before 的变更通知
+	DeleteBefore(before time.Time) error
+}
+
+// 冻结记录仓库
+type FreezeRepository interface {
+	// 创建冻结记录, 创建后回填主键
+	Create(freeze *FreezeData) error
+	// 查询冻结记录, 不存在时返回 nil
+	Find(id Freeze) (*FreezeData, error)
+	// 查询所有未恢复的冻结记录
+	FindUnrecovered() ([]*FreezeData, error)
+	// 查询恢复失败且仍未恢复的冻结记录
+	FindStuck() ([]*FreezeData, error)
+	// 保存冻结记录
+	Save(freeze *FreezeData) error
+	// 记录一次恢复失败
+	RecordFailure(id Freeze, reason string) error
+}
+
+// 交易记录仓库
+type TransactionRepository interface {
+	// 创建交易记录
+	Create(transaction *TransactionData) error
+	// 查询玩家在 [from, to) 内收到的提成记录
+	FindTips(player Player, from, to time.Time) ([]*TransactionData, error)
+	// 按玩家汇总 [from, to) 内的支付记录, 没有支付的玩家不返回
+	SumPayments(players []Player, from, to time.Time) (map[Player]*PaymentSum, error)
+}
+
+// 账本仓库
+type LedgerRepository interface {
+	// 创建记账凭证, 创建后回填主键
+	CreatePosting(posting *PostingData) error
+	// 创建分录
+	CreateEntry(entry *EntryData) error
+	// 按玩家汇总指定类型账户的余额
+	Balances(account Account) (map[Player]int64, error)
+	// 查询分录数额之和不为 0 的凭证
+	FindUnbalanced() ([]Posting, error)
+}
+
+// 结算记录仓库
+type SettlementRepository interface {
+	// 创建结算记录, 键重复时返回错误
+	Create(settlement *SettlementData) error
+	// 根据键查询结算记录, 不存在时返回 nil
+	Find(key SettlementKey) (*SettlementData, error)
+}
+
+// 战绩仓库
+type HistoryRepository interface {
+	// 添加牛牛战绩
+	CreateCow(history *CowHistory) error
+	// 按游标从新到旧查询玩家的牛牛战绩
+	FindCow(player Player, cursor *HistoryCursor, limit int32) ([]*CowHistory, error)
+	// 添加五子棋战绩
+	CreateGomoku(history *GomokuHistory) error
+	// 按游标从新到旧查询玩家的五子棋战绩
+	FindGomoku(player Player, cursor *HistoryCursor, limit int32) ([]*GomokuHistory, error)
+	// 添加二八杠战绩
+	CreateLever28(history *Lever28History) error
+	// 按游标从新到旧查询玩家的二八杠战绩
+	FindLever28(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*Lever28History, error)
+	// 添加红包战绩
+	CreateRed(history *RedHistory) error
+	// 按游标从新到旧查询玩家的红包战绩
+	FindRed(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*RedHistory, error)
+}
+
+// 战绩归档仓库
+type ArchiveRepository interface {
+	// 按时间顺序读取 created_at 早于 before 的记录, out 为来源表模型的切片指针
+	FindExpired(out interface{}, before time.Time, limit int32) error
+	// 删除来源表中已归档的记录, model 为来源表模型
+	DeleteArchived(model interface{}, ids []int64) error
+	// 添加归档
+	Create(archive *HistoryArchiveData) error
+	// 根据来源表与原记录的主键查询归档, 不存在时返回 nil
+	Find(source string, id int64) (*HistoryArchiveData, error)
+	// 删除归档时间早于 before 的归档, 返回删除的数量
+	DeleteBefore(before time.Time) (int64, error)
+	// 累加汇总, 不存在时创建
+	AddSummary(summary *HistorySummaryData) error
+	// 查询玩家在 [from, to) 内的汇总
+	FindSummaries(player Player, from, to time.Time) ([]*HistorySummaryData, error)
+}
+
+// 系统配置仓库
+type ConfigurationRepository interface {
+	// 查询指定类型的配置
+	Find(configurationType string) ([]*Configuration, error)
+}
+
+// 存储, 汇总所有仓库
+type Store interface {
+	Players() PlayerRepository
+	PlayerChanges() PlayerChangeRepository
+	Freezes() FreezeRepository
+	Transactions() TransactionRepository
+	Ledger() LedgerRepository
+	Settlements() SettlementRepository
+	Histories() HistoryRepository
+	Archives() ArchiveRepository
+	Configurations() ConfigurationRepository
+
+	// 在事务中执行 fn, fn 返回错误时回滚, 事务中再次调用时直接在当前事务中执行
+	Transaction(fn func(store Store) error) error
+	// 关闭存储
+	Close() error
+}
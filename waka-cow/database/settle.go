@@ -3,11 +3,11 @@ package database
 // 牛牛场费结算记录
 type CowOrderCostData struct {
 	Player Player
-	Number int32
+	Number int64
 }
 
-// 牛牛场费结算
-func CowOrderCostSettle(players []*CowOrderCostData) error {
+// 牛牛场费结算, 同一房间实例的同一局只结算一次
+func CowOrderCostSettle(room int64, round int32, players []*CowOrderCostData) (*SettlementData, error) {
 	var modifies []*modifyMoneyAction
 
 	for i := range players {
@@ -23,39 +23,18 @@ func CowOrderCostSettle(players []*CowOrderCostData) error {
 		})
 	}
 
-	ts := mysql.Begin()
-
-	err := applyModifyMoneyActions(ts, modifies)
-	if err != nil {
-		ts.Rollback()
-		return err
-	}
-
-	ts.Commit()
-
-	playersLock.Lock()
-	for _, player := range modifies {
-		playerData, being := playersById[player.Player]
-		if being {
-			delete(playersById, player.Player)
-			delete(playersByWechat, playerData.WechatUnionid)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return nil
+	return settle(SettlementKey{"cow.order_cost", room, round}, nil, modifies)
 }
 
 // 牛牛流水结算数据
 type CowFlowingCostData struct {
 	Victory Player
 	Loser   Player
-	Number  int32
+	Number  int64
 }
 
-// 牛牛流水结算
-func CowFlowingCostSettle(players []*CowFlowingCostData) error {
+// 牛牛流水结算, 同一房间实例的同一局只结算一次
+func CowFlowingCostSettle(room int64, round int32, players []*CowFlowingCostData) (*SettlementData, error) {
 	var modifies []*modifyMoneyAction
 
 	for i := range players {
@@ -66,79 +45,42 @@ func CowFlowingCostSettle(players []*CowFlowingCostData) error {
 			Payer:     player.Loser,
 			Payee:     player.Victory,
 			Number:    player.Number,
-			Loss:      0.05,
+			Loss:      500,
 			EnableTip: true,
 		})
 	}
 
-	ts := mysql.Begin()
-
-	err := applyModifyMoneyActions(ts, modifies)
-	if err != nil {
-		ts.Rollback()
-		return err
-	}
-
-	ts.Commit()
-
-	playersLock.Lock()
-	for _, player := range modifies {
-		playerData, being := playersById[player.Player]
-		if being {
-			delete(playersById, player.Player)
-			delete(playersByWechat, playerData.WechatUnionid)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return nil
+	return settle(SettlementKey{"cow.flowing_cost", room, round}, nil, modifies)
 }
 
 // 冻结玩家金币
-func FreezeMoney(player Player, number int32) (Freeze, error) {
-	ts := mysql.Begin()
-
-	freeze, err := freezeMoney(ts, player, number)
+func FreezeMoney(player Player, number int64) (Freeze, error) {
+	var freeze Freeze
+	err := store.Transaction(func(s Store) (err error) {
+		freeze, err = freezeMoney(s, player, number)
+		return err
+	})
 	if err != nil {
-		ts.Rollback()
 		return 0, err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
-	playerData, being := playersById[player]
-	if being {
-		delete(playersById, player)
-		delete(playersByWechat, playerData.WechatUnionid)
-		delete(playersByToken, playerData.Token)
-	}
-	playersLock.Unlock()
+	RefreshCache(player)
 
 	return freeze, nil
 }
 
 // 解冻玩家金币
 func RecoverFreezeMoney(freeze Freeze) error {
-	ts := mysql.Begin()
-
-	player, _, err := recoverFreezeMoney(ts, freeze)
+	var player Player
+	err := store.Transaction(func(s Store) (err error) {
+		player, _, err = recoverFreezeMoney(s, freeze)
+		return err
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
-	playerData, being := playersById[player]
-	if being {
-		delete(playersById, player)
-		delete(playersByWechat, playerData.WechatUnionid)
-		delete(playersByToken, playerData.Token)
-	}
-	playersLock.Unlock()
+	RefreshCache(player)
 
 	return nil
 }
@@ -152,14 +94,14 @@ type RedCreatorCost struct {
 // 红包玩家抢红包数据
 type RedGrabCost struct {
 	Player Player
-	Number int32
+	Number int64
 	Freeze Freeze
 }
 
 // 红包玩家赔付数据
 type RedPayCost struct {
 	Player Player
-	Number int32
+	Number int64
 }
 
 // 红包结算数据
@@ -169,8 +111,8 @@ type RedBagCost struct {
 	Pays    []*RedPayCost
 }
 
-// 红包结算
-func RedBagCostSettle(bag *RedBagCost) error {
+// 红包结算, 同一房间实例的同一局只结算一次
+func RedBagCostSettle(room int64, round int32, bag *RedBagCost) (*SettlementData, error) {
 	var modifies []*modifyMoneyAction
 	var freezes []Freeze
 
@@ -184,7 +126,7 @@ func RedBagCostSettle(bag *RedBagCost) error {
 			Payer:     bag.Creator.Player,
 			Payee:     player.Player,
 			Number:    player.Number,
-			Loss:      0.05,
+			Loss:      500,
 			EnableTip: true,
 		})
 		freezes = append(freezes, player.Freeze)
@@ -198,61 +140,32 @@ func RedBagCostSettle(bag *RedBagCost) error {
 			Payer:     player.Player,
 			Payee:     bag.Creator.Player,
 			Number:    player.Number,
-			Loss:      0.05,
+			Loss:      500,
 			EnableTip: true,
 		})
 	}
 
-	ts := mysql.Begin()
-
-	for _, freeze := range freezes {
-		_, _, err := recoverFreezeMoney(ts, freeze)
-		if err != nil {
-			ts.Rollback()
-			return err
-		}
-	}
-
-	err := applyModifyMoneyActions(ts, modifies)
-	if err != nil {
-		ts.Rollback()
-		return err
-	}
-
-	ts.Commit()
-
-	playersLock.Lock()
-	for _, player := range modifies {
-		playerData, being := playersById[player.Player]
-		if being {
-			delete(playersById, player.Player)
-			delete(playersByWechat, playerData.WechatUnionid)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return nil
+	return settle(SettlementKey{"red", room, round}, freezes, modifies)
 }
 
 // 二八杠玩家凑红包数据
 type Lever28Cost struct {
 	Player Player
-	Number int32
+	Number int64
 	Freeze Freeze
 }
 
 // 二八杠玩家抢红包数据
 type Lever28Grab struct {
 	Player Player
-	Number int32
+	Number int64
 }
 
 // 二八杠玩家赔付数据
 type Lever28Pay struct {
 	Payer  Player
 	Payee  Player
-	Number int32
+	Number int64
 }
 
 // 二八杠结算数据
@@ -262,8 +175,8 @@ type Lever28BagCost struct {
 	Pays  []*Lever28Pay
 }
 
-// 二八杠结算
-func Lever28Settle(bag *Lever28BagCost) error {
+// 二八杠结算, 同一房间实例的同一局只结算一次
+func Lever28Settle(room int64, round int32, bag *Lever28BagCost) (*SettlementData, error) {
 	var modifies []*modifyMoneyAction
 	var freezes []Freeze
 
@@ -292,7 +205,7 @@ func Lever28Settle(bag *Lever28BagCost) error {
 		})
 	}
 
-	for i := range bag.Grabs {
+	for i := range bag.Pays {
 		player := bag.Pays[i]
 
 		modifies = buildTransaction(modifies, &playerTransaction{
@@ -300,45 +213,16 @@ func Lever28Settle(bag *Lever28BagCost) error {
 			Payer:     player.Payer,
 			Payee:     player.Payee,
 			Number:    player.Number,
-			Loss:      0.05,
+			Loss:      500,
 			EnableTip: true,
 		})
 	}
 
-	ts := mysql.Begin()
-
-	for _, freeze := range freezes {
-		_, _, err := recoverFreezeMoney(ts, freeze)
-		if err != nil {
-			ts.Rollback()
-			return err
-		}
-	}
-
-	err := applyModifyMoneyActions(ts, modifies)
-	if err != nil {
-		ts.Rollback()
-		return err
-	}
-
-	ts.Commit()
-
-	playersLock.Lock()
-	for _, player := range modifies {
-		playerData, being := playersById[player.Player]
-		if being {
-			delete(playersById, player.Player)
-			delete(playersByWechat, playerData.WechatUnionid)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return nil
+	return settle(SettlementKey{"lever28", room, round}, freezes, modifies)
 }
 
-// 五子棋结算
-func GomokuSettle(master, student Player, money int32) error {
+// 五子棋结算, 同一房间实例的同一局只结算一次
+func GomokuSettle(room int64, round int32, master, student Player, money int64) (*SettlementData, error) {
 	var modifies []*modifyMoneyAction
 
 	modifies = buildTransaction(modifies, &playerTransaction{
@@ -349,26 +233,5 @@ func GomokuSettle(master, student Player, money int32) error {
 		EnableTip: false,
 	})
 
-	ts := mysql.Begin()
-
-	err := applyModifyMoneyActions(ts, modifies)
-	if err != nil {
-		ts.Rollback()
-		return err
-	}
-
-	ts.Commit()
-
-	playersLock.Lock()
-	for _, player := range modifies {
-		playerData, being := playersById[player.Player]
-		if being {
-			delete(playersById, player.Player)
-			delete(playersByWechat, playerData.WechatUnionid)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return nil
+	return settle(SettlementKey{"gomoku", room, round}, nil, modifies)
 }
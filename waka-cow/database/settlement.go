@@ -0,0 +1,79 @@
+package database
+
+import (
+	"time"
+)
+
+// 结算键, 同一个键只结算一次
+type SettlementKey struct {
+	// 游戏
+	Game string
+	// 房间实例序号, 房间号会被回收, 不能直接使用
+	Room int64
+	// 局数
+	Round int32
+}
+
+// 结算记录数据
+type SettlementData struct {
+	// 主键
+	Id int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 游戏
+	Game string `gorm:"type:varchar(32);unique_index:idx_settlements_key"`
+	// 房间实例序号
+	Room int64 `gorm:"unique_index:idx_settlements_key"`
+	// 局数
+	Round int32 `gorm:"unique_index:idx_settlements_key"`
+	// 创建时间
+	CreatedAt time.Time
+}
+
+func (SettlementData) TableName() string {
+	return "settlements"
+}
+
+func (settlement *SettlementData) Key() SettlementKey {
+	return SettlementKey{settlement.Game, settlement.Room, settlement.Round}
+}
+
+// 在一个事务中恢复冻结并执行改变, 同时写入结算记录
+// 键已结算时不做任何改变, 直接返回原结算记录
+func settle(key SettlementKey, freezes []Freeze, modifies []*modifyMoneyAction) (*SettlementData, error) {
+	var settlement *SettlementData
+	err := store.Transaction(func(s Store) (err error) {
+		settlement, err = s.Settlements().Find(key)
+		if err != nil || settlement != nil {
+			return err
+		}
+
+		for _, freeze := range freezes {
+			if _, _, err := recoverFreezeMoney(s, freeze); err != nil {
+				return err
+			}
+		}
+		if err := applyModifyMoneyActions(s, modifies); err != nil {
+			return err
+		}
+
+		settlement = &SettlementData{
+			Game:      key.Game,
+			Room:      key.Room,
+			Round:     key.Round,
+			CreatedAt: time.Now(),
+		}
+		return s.Settlements().Create(settlement)
+	})
+	if err != nil {
+		// 并发的重复结算因唯一约束失败时返回先提交的结算
+		if original, e := store.Settlements().Find(key); e == nil && original != nil {
+			return original, nil
+		}
+		return nil, err
+	}
+
+	for _, player := range modifies {
+		cache.remove(player.Player)
+	}
+
+	return settlement, nil
+}
@@ -0,0 +1,565 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const (
+	// MySQL 驱动
+	DriverMySQL = "mysql"
+	// SQLite 驱动, 需要 cgo, Name 为数据库文件路径, 为 MemorySource 时使用内存数据库
+	DriverSQLite = "sqlite3"
+
+	// SQLite 内存数据库
+	MemorySource = ":memory:"
+)
+
+// 基于 gorm 的存储, 支持 MySQL 与 SQLite
+type gormStore struct {
+	db *gorm.DB
+	ts bool
+}
+
+// 按选项打开 gorm 存储, 需要时重建所有表并执行迁移
+func openGormStore(option Option) (*gormStore, error) {
+	db, err := openGorm(option)
+	if err != nil {
+		return nil, err
+	}
+
+	if option.Reset || option.memory() {
+		if err := resetTables(db, option.Production); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if option.Reset || option.Migrate || option.memory() {
+		if err := migrateUp(db, 0); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &gormStore{db: db}, nil
+}
+
+// 按选项连接数据库
+func openGorm(option Option) (*gorm.DB, error) {
+	driver, source := option.Driver, ""
+	switch driver {
+	case "", DriverMySQL:
+		driver = DriverMySQL
+		source = fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local`,
+			option.User, option.Password, option.Host, option.Name)
+	case DriverSQLite:
+		source = option.Name
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	db, err := gorm.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if option.memory() {
+		// 每个连接都是独立的内存数据库
+		db.DB().SetMaxOpenConns(1)
+	}
+	db.LogMode(option.EnableLog)
+
+	return db, nil
+}
+
+func (s *gormStore) Players() PlayerRepository {
+	return gormPlayers{s.db}
+}
+
+func (s *gormStore) PlayerChanges() PlayerChangeRepository {
+	return gormPlayerChanges{s.db}
+}
+
+func (s *gormStore) Freezes() FreezeRepository {
+	return gormFreezes{s.db}
+}
+
+func (s *gormStore) Transactions() TransactionRepository {
+	return gormTransactions{s.db}
+}
+
+func (s *gormStore) Ledger() LedgerRepository {
+	return gormLedger{s.db}
+}
+
+func (s *gormStore) Settlements() SettlementRepository {
+	return gormSettlements{s.db}
+}
+
+func (s *gormStore) Histories() HistoryRepository {
+	return gormHistories{s.db}
+}
+
+func (s *gormStore) Archives() ArchiveRepository {
+	return gormArchives{s.db}
+}
+
+func (s *gormStore) Configurations() ConfigurationRepository {
+	return gormConfigurations{s.db}
+}
+
+func (s *gormStore) Transaction(fn func(store Store) error) error {
+	if s.ts {
+		return fn(s)
+	}
+
+	ts := s.db.Begin()
+	if ts.Error != nil {
+		return ts.Error
+	}
+
+	if err := fn(&gormStore{db: ts, ts: true}); err != nil {
+		ts.Rollback()
+		return err
+	}
+
+	return ts.Commit().Error
+}
+
+func (s *gormStore) Close() error {
+	return s.db.Close()
+}
+
+// in 查询每批的最大参数个数
+const batchSize = 500
+
+// 查询单条记录, 不存在时返回 false
+func first(db *gorm.DB, out interface{}) (bool, error) {
+	if err := db.First(out).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormPlayers struct {
+	db *gorm.DB
+}
+
+func (r gormPlayers) Create(player *PlayerData) error {
+	return r.db.Create(player).Error
+}
+
+func (r gormPlayers) Find(id Player) (*PlayerData, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+func (r gormPlayers) FindByToken(token string) (*PlayerData, error) {
+	return r.find(r.db.Where("token = ?", token))
+}
+
+func (r gormPlayers) FindByWechatUnionid(uid string) (*PlayerData, error) {
+	return r.find(r.db.Where("wechat_unionid = ?", uid))
+}
+
+func (r gormPlayers) find(db *gorm.DB) (*PlayerData, error) {
+	player := &PlayerData{}
+	being, err := first(db, player)
+	if err != nil || !being {
+		return nil, err
+	}
+	return player, nil
+}
+
+func (r gormPlayers) Update(id Player, fields *PlayerData) error {
+	return r.db.Model(&PlayerData{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r gormPlayers) AddMoney(id Player, number int64) error {
+	return r.db.Model(&PlayerData{}).Where("id = ?", id).Updates(
+		map[string]interface{}{
+			"money": gorm.Expr("money + ?", number),
+		},
+	).Error
+}
+
+func (r gormPlayers) FindIn(ids []Player) ([]*PlayerData, error) {
+	var players []*PlayerData
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > batchSize {
+			n = batchSize
+		}
+		var batch []*PlayerData
+		if err := r.db.Where("id in (?)", ids[:n]).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		players = append(players, batch...)
+		ids = ids[n:]
+	}
+	return players, nil
+}
+
+func (r gormPlayers) FindAfter(after Player, limit int32) ([]*PlayerData, error) {
+	var players []*PlayerData
+	if err := r.db.Where("id > ?", after).Order("id").Limit(limit).Find(&players).Error; err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+func (r gormPlayers) FindBySupervisors(supervisors []Player) ([]*PlayerData, error) {
+	var players []*PlayerData
+	for len(supervisors) > 0 {
+		n := len(supervisors)
+		if n > batchSize {
+			n = batchSize
+		}
+		var batch []*PlayerData
+		if err := r.db.Where("supervisor in (?)", supervisors[:n]).Order("id").Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		players = append(players, batch...)
+		supervisors = supervisors[n:]
+	}
+	return players, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormPlayerChanges struct {
+	db *gorm.DB
+}
+
+func (r gormPlayerChanges) Last() (*PlayerChangeData, error) {
+	change := &PlayerChangeData{}
+	being, err := first(r.db.Order("id desc"), change)
+	if err != nil || !being {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (r gormPlayerChanges) FindAfter(after int64, limit int32) ([]*PlayerChangeData, error) {
+	var changes []*PlayerChangeData
+	if err := r.db.Where("id > ?", after).Order("id").Limit(limit).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r gormPlayerChanges) DeleteBefore(before time.Time) error {
+	return r.db.Where("created_at < ?", before).Delete(PlayerChangeData{}).Error
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormFreezes struct {
+	db *gorm.DB
+}
+
+func (r gormFreezes) Create(freeze *FreezeData) error {
+	return r.db.Create(freeze).Error
+}
+
+func (r gormFreezes) Find(id Freeze) (*FreezeData, error) {
+	freeze := &FreezeData{}
+	being, err := first(r.db.Where("id = ?", id), freeze)
+	if err != nil || !being {
+		return nil, err
+	}
+	return freeze, nil
+}
+
+func (r gormFreezes) FindUnrecovered() ([]*FreezeData, error) {
+	var freezes []*FreezeData
+	if err := r.db.Where("recovered = ?", false).Find(&freezes).Error; err != nil {
+		return nil, err
+	}
+	return freezes, nil
+}
+
+func (r gormFreezes) FindStuck() ([]*FreezeData, error) {
+	var freezes []*FreezeData
+	if err := r.db.Where("recovered = ? and recover_attempts > 0", false).Order("id").Find(&freezes).Error; err != nil {
+		return nil, err
+	}
+	return freezes, nil
+}
+
+func (r gormFreezes) Save(freeze *FreezeData) error {
+	return r.db.Save(freeze).Error
+}
+
+func (r gormFreezes) RecordFailure(id Freeze, reason string) error {
+	return r.db.Model(&FreezeData{}).Where("id = ?", id).Updates(
+		map[string]interface{}{
+			"recover_attempts":  gorm.Expr("recover_attempts + 1"),
+			"recover_error":     reason,
+			"recover_failed_at": time.Now(),
+		},
+	).Error
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormTransactions struct {
+	db *gorm.DB
+}
+
+func (r gormTransactions) Create(transaction *TransactionData) error {
+	return r.db.Create(transaction).Error
+}
+
+func (r gormTransactions) FindTips(player Player, from, to time.Time) ([]*TransactionData, error) {
+	var d []*TransactionData
+	if err := r.db.Where("player = ? and type = ? and reason like ? and created_at >= ? and created_at < ?",
+		player, 2, "%.tip%", from, to).Order("id").Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormTransactions) SumPayments(players []Player, from, to time.Time) (map[Player]*PaymentSum, error) {
+	sums := make(map[Player]*PaymentSum)
+	for len(players) > 0 {
+		n := len(players)
+		if n > batchSize {
+			n = batchSize
+		}
+		rows, err := r.db.Model(&TransactionData{}).
+			Select("player, count(*), sum(number)").
+			Where("player in (?) and type = ? and created_at >= ? and created_at < ?", players[:n], 1, from, to).
+			Group("player").
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var player Player
+			sum := &PaymentSum{}
+			if err := rows.Scan(&player, &sum.Count, &sum.Number); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			sums[player] = sum
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		players = players[n:]
+	}
+	return sums, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormLedger struct {
+	db *gorm.DB
+}
+
+func (r gormLedger) CreatePosting(posting *PostingData) error {
+	return r.db.Create(posting).Error
+}
+
+func (r gormLedger) CreateEntry(entry *EntryData) error {
+	return r.db.Create(entry).Error
+}
+
+func (r gormLedger) Balances(account Account) (map[Player]int64, error) {
+	rows, err := r.db.Model(&EntryData{}).
+		Select("player, sum(number)").
+		Where("account = ?", account).
+		Group("player").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[Player]int64)
+	for rows.Next() {
+		var player Player
+		var number int64
+		if err := rows.Scan(&player, &number); err != nil {
+			return nil, err
+		}
+		balances[player] = number
+	}
+	return balances, rows.Err()
+}
+
+func (r gormLedger) FindUnbalanced() ([]Posting, error) {
+	var postings []Posting
+	if err := r.db.Model(&EntryData{}).
+		Group("posting").
+		Having("sum(number) <> 0").
+		Order("posting").
+		Pluck("posting", &postings).Error; err != nil {
+		return nil, err
+	}
+	return postings, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormSettlements struct {
+	db *gorm.DB
+}
+
+func (r gormSettlements) Create(settlement *SettlementData) error {
+	return r.db.Create(settlement).Error
+}
+
+func (r gormSettlements) Find(key SettlementKey) (*SettlementData, error) {
+	settlement := &SettlementData{}
+	being, err := first(r.db.Where("game = ? and room = ? and round = ?", key.Game, key.Room, key.Round), settlement)
+	if err != nil || !being {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormHistories struct {
+	db *gorm.DB
+}
+
+// 按 (created_at, id) 从新到旧取游标之后的一页, 与战绩表的游标索引顺序一致
+func pageHistory(db *gorm.DB, cursor *HistoryCursor, limit int32) *gorm.DB {
+	if cursor != nil {
+		db = db.Where("created_at < ? or (created_at = ? and id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.Id)
+	}
+	return db.Order("created_at desc").Order("id desc").Limit(limit)
+}
+
+func (r gormHistories) CreateCow(history *CowHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormHistories) FindCow(player Player, cursor *HistoryCursor, limit int32) ([]*CowHistory, error) {
+	var d []*CowHistory
+	if err := pageHistory(r.db.Where("player = ?", player), cursor, limit).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormHistories) CreateGomoku(history *GomokuHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormHistories) FindGomoku(player Player, cursor *HistoryCursor, limit int32) ([]*GomokuHistory, error) {
+	var d []*GomokuHistory
+	if err := pageHistory(r.db.Where("player = ?", player), cursor, limit).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormHistories) CreateLever28(history *Lever28History) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormHistories) FindLever28(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*Lever28History, error) {
+	var d []*Lever28History
+	if err := pageHistory(r.db.Where("player = ? and mode = ?", player, mode), cursor, limit).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormHistories) CreateRed(history *RedHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormHistories) FindRed(player Player, mode int32, cursor *HistoryCursor, limit int32) ([]*RedHistory, error) {
+	var d []*RedHistory
+	if err := pageHistory(r.db.Where("player = ? and mode = ?", player, mode), cursor, limit).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormArchives struct {
+	db *gorm.DB
+}
+
+func (r gormArchives) FindExpired(out interface{}, before time.Time, limit int32) error {
+	return r.db.Where("created_at < ?", before).Order("created_at").Order("id").Limit(limit).Find(out).Error
+}
+
+func (r gormArchives) DeleteArchived(model interface{}, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Where("id in (?)", ids).Delete(model).Error
+}
+
+func (r gormArchives) Create(archive *HistoryArchiveData) error {
+	return r.db.Create(archive).Error
+}
+
+func (r gormArchives) Find(source string, id int64) (*HistoryArchiveData, error) {
+	archive := &HistoryArchiveData{}
+	being, err := first(r.db.Where("source = ? and source_id = ?", source, id), archive)
+	if err != nil || !being {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (r gormArchives) DeleteBefore(before time.Time) (int64, error) {
+	db := r.db.Where("archived_at < ?", before).Delete(new(HistoryArchiveData))
+	return db.RowsAffected, db.Error
+}
+
+func (r gormArchives) AddSummary(summary *HistorySummaryData) error {
+	existing := &HistorySummaryData{}
+	being, err := first(r.db.Where("player = ? and source = ? and mode = ? and day = ?",
+		summary.Player, summary.Source, summary.Mode, summary.Day), existing)
+	if err != nil {
+		return err
+	}
+	if !being {
+		return r.db.Create(summary).Error
+	}
+	return r.db.Model(existing).UpdateColumns(map[string]interface{}{
+		"total":  gorm.Expr("total + ?", summary.Total),
+		"number": gorm.Expr("number + ?", summary.Number),
+	}).Error
+}
+
+func (r gormArchives) FindSummaries(player Player, from, to time.Time) ([]*HistorySummaryData, error) {
+	var d []*HistorySummaryData
+	if err := r.db.Where("player = ? and day >= ? and day < ?", player, from, to).Order("day").Order("source").Order("mode").Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormConfigurations struct {
+	db *gorm.DB
+}
+
+func (r gormConfigurations) Find(configurationType string) ([]*Configuration, error) {
+	var values []*Configuration
+	if err := r.db.Where("type = ?", configurationType).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	return values, nil
+}
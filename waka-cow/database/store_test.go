@@ -0,0 +1,272 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestRegisterAndQueryPlayer(t *testing.T) {
+	openTestStore(t)
+
+	player, err := RegisterPlayer("unionid", "nickname", "head", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.Id <= DefaultSupervisor || player.Money != int64(GetSettings().Hall.RegisterMoney) {
+		t.Fatalf("registered %+v", player)
+	}
+
+	byToken, being, err := QueryPlayerByToken("token")
+	if err != nil || !being || byToken.Id != player.Id {
+		t.Fatalf("query by token = %+v, %v, %v", byToken, being, err)
+	}
+	byWechat, being, err := QueryPlayerByWechatUnionid("unionid")
+	if err != nil || !being || byWechat.Id != player.Id {
+		t.Fatalf("query by unionid = %+v, %v, %v", byWechat, being, err)
+	}
+	if _, being, err := QueryPlayerByToken("missing"); err != nil || being {
+		t.Fatalf("query missing token = %v, %v", being, err)
+	}
+
+	// 重新登录后旧令牌失效
+	if err := UpdatePlayerLogin(player.Id, "renamed", "head", "renewed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, being, err := QueryPlayerByToken("token"); err != nil || being {
+		t.Fatalf("query old token = %v, %v", being, err)
+	}
+	if renewed, being, err := QueryPlayerByToken("renewed"); err != nil || !being || renewed.Nickname != "renamed" {
+		t.Fatalf("query renewed token = %+v, %v, %v", renewed, being, err)
+	}
+
+	assertReconciled(t)
+}
+
+func TestFreezeAndRecoverMoney(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 1000, DefaultSupervisor)
+
+	freeze, err := FreezeMoney(player, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if money := testMoney(t, player); money != 400 {
+		t.Fatalf("money after freeze = %d, want 400", money)
+	}
+	assertReconciled(t)
+
+	// 钱不够时整个冻结回滚
+	if _, err := FreezeMoney(player, 401); err != ErrMoneyNotEnough {
+		t.Fatalf("freeze more than money: %v", err)
+	}
+	if money := testMoney(t, player); money != 400 {
+		t.Fatalf("money after failed freeze = %d, want 400", money)
+	}
+	if _, err := FreezeMoney(DefaultSupervisor+1000, 1); err != ErrPlayerNotFound {
+		t.Fatalf("freeze missing player: %v", err)
+	}
+	assertReconciled(t)
+
+	if err := RecoverFreezeMoney(freeze); err != nil {
+		t.Fatal(err)
+	}
+	if money := testMoney(t, player); money != 1000 {
+		t.Fatalf("money after recover = %d, want 1000", money)
+	}
+	if err := RecoverFreezeMoney(freeze); err != ErrFreezeRecovered {
+		t.Fatalf("recover twice: %v", err)
+	}
+	if err := RecoverFreezeMoney(freeze + 1000); err != ErrFreezeNotFound {
+		t.Fatalf("recover missing freeze: %v", err)
+	}
+	assertReconciled(t)
+}
+
+func TestSettleOnce(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 1000, DefaultSupervisor)
+
+	first, err := CowOrderCostSettle(7, 1, []*CowOrderCostData{{Player: player, Number: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一房间实例的同一局重复结算时返回原结算记录, 不再扣钱
+	again, err := CowOrderCostSettle(7, 1, []*CowOrderCostData{{Player: player, Number: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != first.Id {
+		t.Fatalf("settled twice: %+v, %+v", first, again)
+	}
+	if money := testMoney(t, player); money != 900 {
+		t.Fatalf("money = %d, want 900", money)
+	}
+
+	// 下一局正常结算
+	if _, err := CowOrderCostSettle(7, 2, []*CowOrderCostData{{Player: player, Number: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if money := testMoney(t, player); money != 800 {
+		t.Fatalf("money = %d, want 800", money)
+	}
+	assertReconciled(t)
+}
+
+func TestLever28Settle(t *testing.T) {
+	openTestStore(t)
+	a := createTestPlayer(t, 100000, DefaultSupervisor)
+	b := createTestPlayer(t, 100000, DefaultSupervisor)
+	c := createTestPlayer(t, 100000, DefaultSupervisor)
+	system := testMoney(t, DefaultSupervisor)
+
+	var costs []*Lever28Cost
+	for _, player := range []Player{a, b, c} {
+		freeze, err := FreezeMoney(player, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		costs = append(costs, &Lever28Cost{Player: player, Number: 1000, Freeze: freeze})
+	}
+
+	// 抢红包与赔付的数量不同, 每一笔赔付都要结算
+	bag := &Lever28BagCost{
+		Costs: costs,
+		Grabs: []*Lever28Grab{
+			{Player: a, Number: 2000},
+			{Player: b, Number: 1000},
+		},
+		Pays: []*Lever28Pay{
+			{Payer: c, Payee: a, Number: 500},
+			{Payer: c, Payee: b, Number: 300},
+			{Payer: b, Payee: a, Number: 200},
+		},
+	}
+	if _, err := Lever28Settle(1, 1, bag); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[Player]int64{
+		a:                 100000 - 1000 + 2000 + 475 + 190,
+		b:                 100000 - 1000 + 1000 + 285 - 200,
+		c:                 100000 - 1000 - 500 - 300,
+		DefaultSupervisor: system + 3000 - 3000 + 25 + 15 + 10,
+	}
+	for player, money := range want {
+		if got := testMoney(t, player); got != money {
+			t.Errorf("player %d money = %d, want %d", player, got, money)
+		}
+	}
+	for _, cost := range costs {
+		if err := RecoverFreezeMoney(cost.Freeze); err != ErrFreezeRecovered {
+			t.Errorf("freeze %d not recovered by settle: %v", cost.Freeze, err)
+		}
+	}
+	assertReconciled(t)
+}
+
+// 任一玩家的钱不够时整个结算回滚, 冻结保持未恢复
+func TestSettleRollback(t *testing.T) {
+	openTestStore(t)
+	rich := createTestPlayer(t, 10000, DefaultSupervisor)
+	poor := createTestPlayer(t, 100, DefaultSupervisor)
+
+	freeze, err := FreezeMoney(rich, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bag := &Lever28BagCost{
+		Costs: []*Lever28Cost{{Player: rich, Number: 1000, Freeze: freeze}},
+		Grabs: []*Lever28Grab{{Player: rich, Number: 1000}},
+		Pays:  []*Lever28Pay{{Payer: poor, Payee: rich, Number: 101}},
+	}
+	if _, err := Lever28Settle(1, 1, bag); err == nil {
+		t.Fatal("settle with money not enough succeeded")
+	}
+
+	if money := testMoney(t, rich); money != 9000 {
+		t.Errorf("rich money = %d, want 9000", money)
+	}
+	if money := testMoney(t, poor); money != 100 {
+		t.Errorf("poor money = %d, want 100", money)
+	}
+	settlement, err := store.Settlements().Find(SettlementKey{"lever28", 1, 1})
+	if err != nil || settlement != nil {
+		t.Fatalf("settlement after rollback = %+v, %v", settlement, err)
+	}
+	assertReconciled(t)
+
+	// 冻结仍可恢复
+	if err := RecoverFreezeMoney(freeze); err != nil {
+		t.Fatal(err)
+	}
+	assertReconciled(t)
+}
+
+// 造一条启动时恢复失败的冻结
+func createStuckFreeze(t *testing.T, player Player, number int64) Freeze {
+	freeze, err := FreezeMoney(player, number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Freezes().RecordFailure(freeze, "connection lost"); err != nil {
+		t.Fatal(err)
+	}
+	return freeze
+}
+
+func TestResolveStuckFreeze(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 1000, DefaultSupervisor)
+
+	refunded := createStuckFreeze(t, player, 300)
+	discarded := createStuckFreeze(t, player, 200)
+	normal, err := FreezeMoney(player, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stuck, err := QueryStuckFreezes(); err != nil || len(stuck) != 2 {
+		t.Fatalf("stuck freezes = %v, %v", stuck, err)
+	}
+	assertReconciled(t)
+
+	// 没有恢复失败过的冻结不能手动处理
+	if err := ResolveStuckFreeze(normal, true); err != ErrFreezeNotStuck {
+		t.Fatalf("resolve normal freeze: %v", err)
+	}
+	if err := ResolveStuckFreeze(discarded+1000, true); err != ErrFreezeNotFound {
+		t.Fatalf("resolve missing freeze: %v", err)
+	}
+
+	if err := ResolveStuckFreeze(refunded, true); err != nil {
+		t.Fatal(err)
+	}
+	if money := testMoney(t, player); money != 700 {
+		t.Fatalf("money after refund = %d, want 700", money)
+	}
+	assertReconciled(t)
+
+	// 作废的冻结不退还给玩家
+	if err := ResolveStuckFreeze(discarded, false); err != nil {
+		t.Fatal(err)
+	}
+	if money := testMoney(t, player); money != 700 {
+		t.Fatalf("money after discard = %d, want 700", money)
+	}
+	assertReconciled(t)
+
+	// 已处理的冻结不能再次处理
+	for _, freeze := range []Freeze{refunded, discarded} {
+		for _, refund := range []bool{true, false} {
+			if err := ResolveStuckFreeze(freeze, refund); err != ErrFreezeRecovered {
+				t.Fatalf("resolve freeze %d twice with refund %v: %v", freeze, refund, err)
+			}
+		}
+	}
+	if money := testMoney(t, player); money != 700 {
+		t.Fatalf("money after second resolve = %d, want 700", money)
+	}
+	if stuck, err := QueryStuckFreezes(); err != nil || len(stuck) != 0 {
+		t.Fatalf("stuck freezes = %v, %v", stuck, err)
+	}
+	assertReconciled(t)
+}
@@ -1,15 +1,8 @@
 package database
 
 import (
+	"fmt"
 	"time"
-
-	"github.com/jinzhu/gorm"
-)
-
-const (
-	supervisor1 = 0.3
-	supervisor2 = 0.09
-	supervisor3 = 0.06
 )
 
 // 交易记录
@@ -24,7 +17,7 @@ type TransactionData struct {
 	// 交易对象
 	Target Player
 	// 数额
-	Number int32
+	Number int64
 	// 类型
 	// 0 未知
 	// 1 支付
@@ -49,9 +42,9 @@ type playerTransaction struct {
 	// 收款人
 	Payee Player
 	// 交易数额
-	Number int32
-	// 折损率
-	Loss float64
+	Number int64
+	// 收款人的折损, 万分比, 折损部分作为手续费分配给上级与系统
+	Loss int32
 	// 是否支付提成
 	EnableTip bool
 }
@@ -60,123 +53,106 @@ func buildTransaction(modifies []*modifyMoneyAction, transaction *playerTransact
 	if transaction.Payer < DefaultSupervisor ||
 		transaction.Payee < DefaultSupervisor ||
 		transaction.Number <= 0 ||
-		(transaction.EnableTip && (transaction.Loss < 0 || transaction.Loss > 1)) {
+		(transaction.EnableTip && (transaction.Loss < 0 || transaction.Loss > rateBase)) {
 		return modifies
 	}
 
+	posting := &ledgerPosting{Reason: transaction.Reason}
+
 	modifies = append(modifies, &modifyMoneyAction{
-		Player: transaction.Payer,
-		Number: transaction.Number * (-1),
-		After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-			if err := ts.Create(&TransactionData{
+		Player:  transaction.Payer,
+		Number:  transaction.Number * (-1),
+		Posting: posting,
+		After: func(s Store, self *modifyMoneyAction) error {
+			if err := s.Transactions().Create(&TransactionData{
 				Player:    transaction.Payer,
 				Target:    transaction.Payee,
 				Number:    transaction.Number,
 				Type:      1,
 				Reason:    transaction.Reason + ".pay",
 				CreatedAt: time.Now(),
-			}).Error; err != nil {
+			}); err != nil {
 				return err
 			}
 			return nil
 		},
 	})
 	if transaction.EnableTip {
-		supervisorPlayer1 := transaction.Payer.PlayerData().Supervisor
-		supervisorPlayer2 := supervisorPlayer1.PlayerData().Supervisor
-		supervisorPlayer3 := supervisorPlayer2.PlayerData().Supervisor
-
-		number := int32(float64(transaction.Number)*(1-transaction.Loss) + 0.5)
-		supervisorNumber1 := int32(float64(transaction.Number-number)*supervisor1 + 0.5)
-		supervisorNumber2 := int32(float64(transaction.Number-number-supervisorNumber1)*supervisor2 + 0.5)
-		supervisorNumber3 := int32(float64(transaction.Number-number-supervisorNumber1-supervisorNumber2)*supervisor3 + 0.5)
-		systemNumber := transaction.Number - number - supervisorNumber1 - supervisorNumber2 - supervisorNumber3
+		c := computeCommission(transaction.Number, transaction.Loss, commissionRates(transaction.Reason))
 
 		modifies = append(modifies, &modifyMoneyAction{
-			Player: transaction.Payee,
-			Number: number,
-			After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-				if err := ts.Create(&TransactionData{
+			Player:  transaction.Payee,
+			Number:  c.Income,
+			Posting: posting,
+			After: func(s Store, self *modifyMoneyAction) error {
+				if err := s.Transactions().Create(&TransactionData{
 					Player:    transaction.Payee,
 					Target:    transaction.Payer,
-					Number:    number,
+					Number:    c.Income,
 					Type:      2,
 					Reason:    transaction.Reason + ".income",
 					CreatedAt: time.Now(),
-				}).Error; err != nil {
-					return err
-				}
-				return nil
-			},
-		})
-		modifies = append(modifies, &modifyMoneyAction{
-			Player: supervisorPlayer1,
-			Number: supervisorNumber1,
-			After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-				if err := ts.Create(&TransactionData{
-					Player:    supervisorPlayer1,
-					Target:    transaction.Payer,
-					Number:    supervisorNumber1,
-					Type:      2,
-					Reason:    transaction.Reason + ".tip1",
-					CreatedAt: time.Now(),
-				}).Error; err != nil {
+				}); err != nil {
 					return err
 				}
 				return nil
 			},
 		})
+		supervisor := transaction.Payer
+		for i, tip := range c.Tips {
+			supervisor = supervisorOf(supervisor)
+			level, player, tip := i+1, supervisor, tip
+			modifies = append(modifies, &modifyMoneyAction{
+				Player:  player,
+				Number:  tip,
+				Posting: posting,
+				After: func(s Store, self *modifyMoneyAction) error {
+					if err := s.Transactions().Create(&TransactionData{
+						Player:    player,
+						Target:    transaction.Payer,
+						Number:    tip,
+						Type:      2,
+						Reason:    fmt.Sprintf("%s.tip%d", transaction.Reason, level),
+						CreatedAt: time.Now(),
+					}); err != nil {
+						return err
+					}
+					return nil
+				},
+			})
+		}
 		modifies = append(modifies, &modifyMoneyAction{
-			Player: transaction.Payer.PlayerData().Supervisor.PlayerData().Supervisor,
-			Number: supervisorNumber2,
-			After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-				if err := ts.Create(&TransactionData{
-					Player:    supervisorPlayer2,
+			Player:  DefaultSupervisor,
+			Number:  c.System,
+			Posting: posting,
+			After: func(s Store, self *modifyMoneyAction) error {
+				if err := s.Transactions().Create(&TransactionData{
+					Player:    DefaultSupervisor,
 					Target:    transaction.Payer,
-					Number:    supervisorNumber2,
+					Number:    c.System,
 					Type:      2,
-					Reason:    transaction.Reason + ".tip2",
+					Reason:    transaction.Reason + ".system",
 					CreatedAt: time.Now(),
-				}).Error; err != nil {
+				}); err != nil {
 					return err
 				}
 				return nil
 			},
 		})
-		modifies = append(modifies, &modifyMoneyAction{
-			Player: transaction.Payer.PlayerData().Supervisor.PlayerData().Supervisor.PlayerData().Supervisor,
-			Number: supervisorNumber3,
-			After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-				if err := ts.Create(&TransactionData{
-					Player:    supervisorPlayer3,
-					Target:    transaction.Payer,
-					Number:    supervisorNumber3,
-					Type:      2,
-					Reason:    transaction.Reason + ".tip3",
-					CreatedAt: time.Now(),
-				}).Error; err != nil {
-					return err
-				}
-				return nil
-			},
-		})
-		modifies = append(modifies, &modifyMoneyAction{
-			Player: DefaultSupervisor,
-			Number: systemNumber,
-		})
 	} else {
 		modifies = append(modifies, &modifyMoneyAction{
-			Player: transaction.Payee,
-			Number: transaction.Number,
-			After: func(ts *gorm.DB, self *modifyMoneyAction) error {
-				if err := ts.Create(&TransactionData{
+			Player:  transaction.Payee,
+			Number:  transaction.Number,
+			Posting: posting,
+			After: func(s Store, self *modifyMoneyAction) error {
+				if err := s.Transactions().Create(&TransactionData{
 					Player:    transaction.Payee,
 					Target:    transaction.Payer,
 					Number:    transaction.Number,
 					Type:      2,
 					Reason:    transaction.Reason + ".income",
 					CreatedAt: time.Now(),
-				}).Error; err != nil {
+				}); err != nil {
 					return err
 				}
 				return nil
@@ -186,3 +162,13 @@ func buildTransaction(modifies []*modifyMoneyAction, transaction *playerTransact
 
 	return modifies
 }
+
+// 玩家的上级, 没有上级时为系统玩家.
+// 以前没有上级时提成记给玩家 0, 玩家 0 不存在, 这部分钱直接丢失, 账本也无法平衡, 现在改为归系统玩家
+func supervisorOf(player Player) Player {
+	supervisor := player.PlayerData().Supervisor
+	if supervisor < DefaultSupervisor {
+		return DefaultSupervisor
+	}
+	return supervisor
+}
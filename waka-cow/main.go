@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,7 +13,6 @@ import (
 
 	"github.com/AsynkronIT/protoactor-go/actor"
 	protolog "github.com/AsynkronIT/protoactor-go/log"
-	"github.com/davyxu/cellnet"
 	"github.com/davyxu/golog"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -22,8 +22,11 @@ import (
 	_ "github.com/liuhan907/waka/waka/vt100"
 
 	"github.com/liuhan907/waka/waka-cow/conf"
+	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/modules/hall"
 	"github.com/liuhan907/waka/waka-cow/modules/player"
+	"github.com/liuhan907/waka/waka-cow/proto"
+	"github.com/liuhan907/waka/waka/codec"
 	"github.com/liuhan907/waka/waka/modules/gateway"
 	"github.com/liuhan907/waka/waka/modules/session"
 	"github.com/liuhan907/waka/waka/modules/supervisor"
@@ -34,20 +37,92 @@ var (
 		"pid":    os.Getpid(),
 		"module": "main",
 	})
+
+	configPath = flag.String("config", "conf.toml", "配置文件路径")
 )
 
 func init() {
-	gin.SetMode(conf.Option.Mode.Mode)
-	logrus.SetLevel(logrus.Level(conf.Option.Log.Level))
 	golog.SetLevelByString("*", "fatal")
 	actor.SetLogLevel(protolog.OffLevel)
 }
 
 func main() {
+	flag.Parse()
+	loadConfig()
+	if flag.NArg() > 0 {
+		runCommand(flag.Args())
+		return
+	}
+
+	validateMessages()
+	openDatabase()
 	startGateway()
 	wait()
 }
 
+func loadConfig() {
+	if err := conf.Load(*configPath); err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Fatalln("load config failed")
+	}
+	gin.SetMode(conf.Option.Mode.Mode)
+	logrus.SetLevel(logrus.Level(conf.Option.Log.Level))
+}
+
+// 重新读取配置文件的 hall 段与系统配置表, 配置文件中的其它项需要重启后生效
+func reloadSettings() error {
+	option, err := conf.Read(*configPath)
+	if err != nil {
+		return err
+	}
+	return database.ReloadSettings(option.Hall)
+}
+
+func validateMessages() {
+	if err := codec.RegisterPackage(&cow_proto.Welcome{}); err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Fatalln("register messages failed")
+	}
+	if err := codec.Validate(); err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Fatalln("validate messages failed")
+	}
+}
+
+func databaseOption() database.Option {
+	return database.Option{
+		Driver:     conf.Option.Database.Driver,
+		Host:       conf.Option.Database.Host,
+		User:       conf.Option.Database.User,
+		Password:   conf.Option.Database.Password,
+		Name:       conf.Option.Database.Name,
+		Reset:      conf.Option.Install.Reset,
+		Migrate:    conf.Option.Install.Update,
+		Production: conf.Option.Mode.Mode == gin.ReleaseMode,
+		CacheTTL:   time.Duration(conf.Option.Cache.TTL) * time.Second,
+		CacheSize:  int(conf.Option.Cache.Size),
+		ChangePoll: time.Duration(conf.Option.Cache.Poll) * time.Millisecond,
+
+		ArchiveAge:       time.Duration(conf.Option.Archive.Age) * time.Hour * 24,
+		ArchiveRetention: time.Duration(conf.Option.Archive.Retention) * time.Hour * 24,
+		ArchiveInterval:  time.Duration(conf.Option.Archive.Interval) * time.Minute,
+		ArchiveBatch:     conf.Option.Archive.Batch,
+
+		EnableLog: true,
+	}
+}
+
+func openDatabase() {
+	if err := database.Open(databaseOption()); err != nil {
+		log.WithFields(logrus.Fields{
+			"err": err,
+		}).Fatalln("open database failed")
+	}
+}
+
 func startGateway() {
 	supervisorTargetCreator := func(pid *actor.PID) *actor.PID {
 		target := hall.Spawn(pid)
@@ -56,6 +131,7 @@ func startGateway() {
 				TargetCreator: func() *actor.PID {
 					return target
 				},
+				Reload:  reloadSettings,
 				Address: conf.Option.Gateway.Backend,
 			}
 			backend.Start(backendOption)
@@ -74,18 +150,21 @@ func startGateway() {
 	sessionOption := session.Option{
 		TargetCreator:   sessionTargetCreator,
 		EnableHeart:     true,
+		Codecs:          conf.Option.Gateway.Codecs,
+		Interceptors:    []session.Interceptor{session.ValidateInterceptor},
 		EnableLog:       false,
 		EnableHeartLog:  false,
 		HeartPeriod:     time.Second * 3,
 		HeartDeadPeriod: time.Second * 3 * 10,
 	}
 
-	gatewayTargetCreator := func(conn cellnet.Session) *actor.PID {
+	gatewayTargetCreator := func(conn gateway.Conn) *actor.PID {
 		return session.Spawn(sessionOption, conn)
 	}
 	gatewayOption := gateway.Option{
-		TargetCreator: gatewayTargetCreator,
-		Address:       conf.Option.Gateway.Gateway,
+		TargetCreator:    gatewayTargetCreator,
+		Address:          conf.Option.Gateway.Gateway,
+		WebSocketAddress: conf.Option.Gateway.WebSocket,
 	}
 	gateway.Start(gatewayOption)
 }
@@ -112,7 +191,18 @@ func wait() {
 	}
 
 	c := make(chan os.Signal, 0)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
-	<-c
+	signal.Notify(c, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range c {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if err := reloadSettings(); err != nil {
+			log.WithFields(logrus.Fields{
+				"err": err,
+			}).Warnln("reload settings failed")
+		} else {
+			log.Infoln("settings reloaded")
+		}
+	}
 	log.Infoln("exit signal received")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/sirupsen/logrus"
@@ -24,6 +25,11 @@ type actorT struct {
 	supervisor *actor.PID
 	pid        *actor.PID
 
+	// 房间实例序号
+	serial int64
+	// 等待重试的结算
+	settles map[settleKey]*settleStateT
+
 	players playerMap
 
 	cowRooms                cowRoomMapT
@@ -69,8 +75,16 @@ func (my *actorT) Receive(context actor.Context) {
 }
 
 func Spawn(supervisor *actor.PID) *actor.PID {
+	return actor.Spawn(
+		actor.FromInstance(newActor(supervisor)),
+	)
+}
+
+func newActor(supervisor *actor.PID) *actorT {
 	instance := &actorT{
 		supervisor:              supervisor,
+		serial:                  time.Now().UnixNano(),
+		settles:                 make(map[settleKey]*settleStateT),
 		players:                 make(playerMap, 12800),
 		cowRooms:                make(cowRoomMapT, 12800),
 		cowPlayerNumberPool:     tools.NewNumberPool(10001, 89999, true),
@@ -81,7 +95,5 @@ func Spawn(supervisor *actor.PID) *actor.PID {
 		gomokuNumberPool:        tools.NewNumberPool(10001, 89999, true),
 	}
 	instance.players[database.Player(0)] = &playerT{}
-	return actor.Spawn(
-		actor.FromInstance(instance),
-	)
+	return instance
 }
@@ -0,0 +1,58 @@
+package hall
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka/modules/logger"
+	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
+)
+
+func TestMain(m *testing.M) {
+	// 与正式运行一样经过日志钩子, 日志文件写入临时目录
+	dir, err := ioutil.TempDir("", "waka-cow-hall")
+	if err != nil {
+		panic(err)
+	}
+	logger.Install(logger.Option{Prefix: filepath.Join(dir, "cow")})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 丢弃大厅发出的所有消息
+type discardActor struct{}
+
+func (discardActor) Receive(context actor.Context) {}
+
+// 大厅处理消息的吞吐, 对比关闭与开启调试日志
+// 每条玩家变更消息向所有在线玩家广播人数, 是日志最密集的路径之一
+func BenchmarkHallPlayerExchanged(b *testing.B) {
+	const online = 20
+
+	level := logrus.GetLevel()
+	defer logrus.SetLevel(level)
+
+	for _, level := range []logrus.Level{logrus.InfoLevel, logrus.DebugLevel} {
+		b.Run(level.String(), func(b *testing.B) {
+			logrus.SetLevel(level)
+
+			my := newActor(actor.Spawn(actor.FromInstance(discardActor{})))
+			for i := 0; i < online; i++ {
+				my.playerEntered(&supervisor_message.PlayerEntered{uint64(100001 + i), "127.0.0.1:30011"})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				my.playerExchanged(&supervisor_message.PlayerExchanged{uint64(100001 + i%online), "127.0.0.1:30011"})
+			}
+		})
+	}
+}
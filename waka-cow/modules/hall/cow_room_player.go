@@ -99,6 +99,7 @@ type playerRoomT struct {
 	Hall *actorT
 
 	Id      int32
+	Serial  int64
 	Type    cow_proto.NiuniuRoomType
 	Option  *cow_proto.NiuniuRoomOption
 	Creator database.Player
@@ -345,6 +346,7 @@ func (r *playerRoomT) CreateRoom(hall *actorT, id int32, roomType cow_proto.Niun
 	*r = playerRoomT{
 		Hall:    hall,
 		Id:      id,
+		Serial:  hall.newSerial(),
 		Type:    roomType,
 		Option:  option,
 		Creator: creator,
@@ -362,7 +364,7 @@ func (r *playerRoomT) CreateRoom(hall *actorT, id int32, roomType cow_proto.Niun
 		r.Owner = creator
 	}
 
-	if creator.PlayerData().Money < r.CreateMoney()*100 {
+	if creator.PlayerData().Money < int64(r.CreateMoney())*100 {
 		r.Hall.sendNiuniuCreateRoomFailed(creator, 1)
 	} else {
 		r.Hall.cowRooms[id] = r
@@ -377,7 +379,7 @@ func (r *playerRoomT) CreateRoom(hall *actorT, id int32, roomType cow_proto.Niun
 }
 
 func (r *playerRoomT) JoinRoom(player *playerT) {
-	if player.Player.PlayerData().Money < r.JoinMoney()*100 {
+	if player.Player.PlayerData().Money < int64(r.JoinMoney())*100 {
 		r.Hall.sendNiuniuJoinRoomFailed(player.Player, 1)
 		return
 	}
@@ -501,19 +503,28 @@ func (r *playerRoomT) Start(player *playerT) {
 				for _, player := range r.Players {
 					costs = append(costs, &database.CowOrderCostData{
 						Player: player.Player,
-						Number: r.StartMoney() * 100,
+						Number: int64(r.StartMoney()) * 100,
 					})
 				}
 			} else if r.Type == cow_proto.NiuniuRoomType_PayForAnother {
 				costs = append(costs, &database.CowOrderCostData{
 					Player: r.Creator,
-					Number: r.StartMoney() * 100,
+					Number: int64(r.StartMoney()) * 100,
 				})
 			} else {
 				panic("illegal room type")
 			}
 
-			err := database.CowOrderCostSettle(costs)
+			retrying, err := r.Hall.trySettle(r.Serial, r.RoundNumber, func() (*database.SettlementData, error) {
+				return database.CowOrderCostSettle(r.Serial, r.RoundNumber, costs)
+			}, func() {
+				if r.Hall.cowRooms[r.Id] == r {
+					r.Start(player)
+				}
+			})
+			if retrying {
+				return
+			}
 			if err != nil {
 				log.WithFields(logrus.Fields{
 					"id":     r.Id,
@@ -1162,7 +1173,7 @@ func (r *playerRoomT) loopClean() bool {
 	}
 	for _, player := range r.Players {
 		if r.Type == cow_proto.NiuniuRoomType_Order {
-			if player.Player.PlayerData().Money < r.JoinMoney()*100 {
+			if player.Player.PlayerData().Money < int64(r.JoinMoney())*100 {
 				if player.Player == r.Owner {
 					delete(r.Hall.cowRooms, r.Id)
 					for _, player := range r.Players {
@@ -1182,7 +1193,7 @@ func (r *playerRoomT) loopClean() bool {
 				}
 			}
 		} else if r.Type == cow_proto.NiuniuRoomType_PayForAnother {
-			if r.Creator.PlayerData().Money < r.CreateMoney()*100 {
+			if r.Creator.PlayerData().Money < int64(r.CreateMoney())*100 {
 				delete(r.Hall.cowRooms, r.Id)
 				for _, player := range r.Players {
 					if playerData := r.Hall.players[player.Player]; playerData != nil {
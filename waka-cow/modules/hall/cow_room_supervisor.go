@@ -80,12 +80,21 @@ func (players supervisorPlayerMapT) NiuniuRoomDataPlayerData() (pb []*cow_proto.
 	return pb
 }
 
+func (players supervisorPlayerMapT) PlayerData() map[database.Player]*database.PlayerData {
+	ids := make([]database.Player, 0, len(players))
+	for id := range players {
+		ids = append(ids, id)
+	}
+	return queryPlayers(ids)
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 type supervisorRoomT struct {
 	Hall *actorT
 
 	Id      int32
+	Serial  int64
 	Option  *cow_proto.NiuniuRoomOption
 	Owner   database.Player
 	Players supervisorPlayerMapT
@@ -98,6 +107,9 @@ type supervisorRoomT struct {
 	Gaming bool
 	Step   cow_proto.NiuniuRoundStatus_RoundStep
 	Banker database.Player
+
+	// 已开始的局数, 每局开始时递增, 结算以房间实例与局数为键
+	Rounds int32
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -172,10 +184,11 @@ func (r *supervisorRoomT) NiuniuRoomData() *cow_proto.NiuniuRoomData {
 func (r *supervisorRoomT) NiuniuRoundStatus(player database.Player) *cow_proto.NiuniuRoundStatus {
 	var pokers []string
 	var players []*cow_proto.NiuniuRoundStatus_PlayerData
+	data := r.Players.PlayerData()
 	for id, playerData := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundStatus_PlayerData{
 			Id:              int32(id),
-			Points:          playerData.Player.PlayerData().Money / 100,
+			Points:          clampInt32(data[id].Money / 100),
 			Grab:            playerData.Round.Grab,
 			Rate:            playerData.Round.Rate,
 			GrabCommitted:   playerData.Round.GrabCommitted,
@@ -219,10 +232,11 @@ func (r *supervisorRoomT) NiuniuRequireGrabShow() *cow_proto.NiuniuRequireGrabSh
 
 func (r *supervisorRoomT) NiuniuRoundClear() *cow_proto.NiuniuRoundClear {
 	var players []*cow_proto.NiuniuRoundClear_PlayerData
+	data := r.Players.PlayerData()
 	for _, player := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundClear_PlayerData{
 			Player:     int32(player.Player),
-			Points:     player.Player.PlayerData().Money / 100,
+			Points:     clampInt32(data[player.Player].Money / 100),
 			Type:       player.Round.PokersPattern,
 			Weight:     player.Round.PokersWeight,
 			Rate:       player.Round.PokersRate,
@@ -298,6 +312,7 @@ func (r *supervisorRoomT) CreateRoom(hall *actorT, id int32, roomType cow_proto.
 	*r = supervisorRoomT{
 		Hall:    hall,
 		Id:      id,
+		Serial:  hall.newSerial(),
 		Option:  option,
 		Players: make(supervisorPlayerMapT, 5),
 		Seats:   tools.NewNumberPool(1, 5, false),
@@ -305,7 +320,7 @@ func (r *supervisorRoomT) CreateRoom(hall *actorT, id int32, roomType cow_proto.
 }
 
 func (r *supervisorRoomT) JoinRoom(player *playerT) {
-	if player.Player.PlayerData().Money < r.JoinMoney()*100 {
+	if player.Player.PlayerData().Money < int64(r.JoinMoney())*100 {
 		r.Hall.sendNiuniuJoinRoomFailed(player.Player, 1)
 		return
 	}
@@ -441,6 +456,7 @@ func (r *supervisorRoomT) ContinueWith(player *playerT) {
 
 func (r *supervisorRoomT) loopStart() bool {
 	r.Gaming = true
+	r.Rounds++
 
 	r.Hall.sendNiuniuGameStartedForAll(r)
 	r.Hall.sendNiuniuRoundStartedForAll(r, 1)
@@ -748,6 +764,8 @@ func (r *supervisorRoomT) loopSettle() bool {
 	bw, bp, br, _ := cow.GetPokersPattern(banker.Round.CommittedPokers, r.Option.GetMode())
 	banker.Round.PokersPattern = bp
 	banker.Round.PokersRate = int32(br)
+	// 得分每次重新计算, 重复执行本步骤时结算的数额不变
+	var bankerPoints int32
 	for _, player := range players {
 		var applyRate int32
 		var applySign int32
@@ -760,12 +778,14 @@ func (r *supervisorRoomT) loopSettle() bool {
 			applySign = 1
 		}
 
-		banker.Round.Points += r.Option.GetScore() * player.Round.Rate * applyRate * applySign * (-1)
-		player.Round.Points += r.Option.GetScore() * player.Round.Rate * applyRate * applySign
+		points := r.Option.GetScore() * player.Round.Rate * applyRate * applySign
+		bankerPoints -= points
+		player.Round.Points = points
 
 		player.Round.PokersPattern = pp
 		player.Round.PokersRate = int32(pr)
 	}
+	banker.Round.Points = bankerPoints
 
 	var costs []*database.CowFlowingCostData
 	for _, player := range players {
@@ -774,18 +794,23 @@ func (r *supervisorRoomT) loopSettle() bool {
 			c = &database.CowFlowingCostData{
 				Victory: player.Player,
 				Loser:   banker.Player,
-				Number:  player.Round.Points * 100,
+				Number:  int64(player.Round.Points) * 100,
 			}
 		} else {
 			c = &database.CowFlowingCostData{
 				Victory: banker.Player,
 				Loser:   player.Player,
-				Number:  player.Round.Points * 100 * (-1),
+				Number:  int64(player.Round.Points) * 100 * (-1),
 			}
 		}
 		costs = append(costs, c)
 	}
-	err := database.CowFlowingCostSettle(costs)
+	retrying, err := r.Hall.trySettle(r.Serial, r.Rounds, func() (*database.SettlementData, error) {
+		return database.CowFlowingCostSettle(r.Serial, r.Rounds, costs)
+	}, r.Loop)
+	if retrying {
+		return false
+	}
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"room_id": r.Id,
@@ -869,8 +894,9 @@ func (r *supervisorRoomT) loopClean() bool {
 			}
 		}
 	}
+	data := r.Players.PlayerData()
 	for _, player := range r.Players {
-		if playerData := player.Player.PlayerData(); playerData.Money < r.JoinMoney()*100 || playerData.Money < r.StartMoney()*100 {
+		if playerData := data[player.Player]; playerData.Money < int64(r.JoinMoney())*100 || playerData.Money < int64(r.StartMoney())*100 {
 			delete(r.Players, player.Player)
 			r.Seats.Return(player.Pos)
 			r.Hall.players[player.Player].InsideCow = 0
@@ -3,30 +3,14 @@ package hall
 import (
 	"sort"
 
-	"github.com/golang/protobuf/proto"
-
 	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
-func (my *actorT) playerFutureRequestedCow(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.NiuniuQueryPayForAnotherRoomListRequest:
-		my.NiuniuQueryPayForAnotherRoomListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuQueryFlowingRoomListRequest:
-		my.NiuniuQueryFlowingRoomListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuQueryHistoryRequest:
-		my.NiuniuQueryHistoryRequest(player, evd, ev.Respond)
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) NiuniuQueryPayForAnotherRoomListRequest(player *playerT,
+func (my *actorT) NiuniuQueryPayForAnotherRoomListRequest(playerId uint64,
 	ev *cow_proto.NiuniuQueryPayForAnotherRoomListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuQueryPayForAnotherRoomListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	rooms := my.cowRooms.
 		WherePayForAnother().
@@ -40,10 +24,9 @@ func (my *actorT) NiuniuQueryPayForAnotherRoomListRequest(player *playerT,
 	respond(&cow_proto.NiuniuQueryPayForAnotherRoomListResponse{pb}, nil)
 }
 
-func (my *actorT) NiuniuQueryFlowingRoomListRequest(player *playerT,
+func (my *actorT) NiuniuQueryFlowingRoomListRequest(playerId uint64,
 	ev *cow_proto.NiuniuQueryFlowingRoomListRequest,
-	respond func(proto.Message, error)) {
-
+	respond func(*cow_proto.NiuniuQueryFlowingRoomListResponse, error)) {
 	rooms := my.cowRooms.
 		WhereFlowing().
 		WhereReady()
@@ -56,15 +39,16 @@ func (my *actorT) NiuniuQueryFlowingRoomListRequest(player *playerT,
 	respond(&cow_proto.NiuniuQueryFlowingRoomListResponse{pb}, nil)
 }
 
-func (my *actorT) NiuniuQueryHistoryRequest(player *playerT,
+func (my *actorT) NiuniuQueryHistoryRequest(playerId uint64,
 	ev *cow_proto.NiuniuQueryHistoryRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuQueryHistoryResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	records, err := database.CowQueryHistory(player.Player, 20)
+	records, next, err := database.CowQueryHistory(player.Player, database.HistoryCursorFromProto(ev.Cursor), ev.Limit)
 	if err != nil {
 		respond(nil, err)
 		return
 	}
 
-	respond(&cow_proto.NiuniuQueryHistoryResponse{records}, nil)
+	respond(&cow_proto.NiuniuQueryHistoryResponse{records, next.Proto()}, nil)
 }
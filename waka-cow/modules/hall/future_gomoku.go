@@ -1,30 +1,19 @@
 package hall
 
 import (
-	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
 	. "gopkg.in/ahmetb/go-linq.v3"
 
 	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
-func (my *actorT) playerFutureRequestedGomoku(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.GomokuGetHistoryRequest:
-		my.GomokuGetHistoryRequest(player, evd, ev.Respond)
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) GomokuGetHistoryRequest(player *playerT,
+func (my *actorT) GomokuGetHistoryRequest(playerId uint64,
 	ev *cow_proto.GomokuGetHistoryRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.GomokuGetHistoryResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	histories, err := database.GomokuQueryHistory(player.Player, 20)
+	histories, next, err := database.GomokuQueryHistory(player.Player, database.HistoryCursorFromProto(ev.Cursor), ev.Limit)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -42,5 +31,5 @@ func (my *actorT) GomokuGetHistoryRequest(player *playerT,
 		}
 	}).ToSlice(&d)
 
-	respond(&cow_proto.GomokuGetHistoryResponse{d}, nil)
+	respond(&cow_proto.GomokuGetHistoryResponse{d, next.Proto()}, nil)
 }
@@ -3,28 +3,15 @@ package hall
 import (
 	"errors"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/liuhan907/waka/waka-cow/database"
 	waka "github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"github.com/sirupsen/logrus"
 )
 
-func (my *actorT) playerFutureRequestedLever28(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *waka.Lever28GetBagClearRequest:
-		my.Lever28GetBagClearRequest(player, evd, ev.Respond)
-	case *waka.Lever28GetHistoryRequest:
-		my.Lever28GetHistoryRequest(player, evd, ev.Respond)
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) Lever28GetBagClearRequest(player *playerT,
+func (my *actorT) Lever28GetBagClearRequest(playerId uint64,
 	ev *waka.Lever28GetBagClearRequest,
-	respond func(proto.Message, error)) {
+	respond func(*waka.Lever28GetBagClearResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	if player.InsideLever28 == 0 {
 		log.WithFields(logrus.Fields{
@@ -65,11 +52,12 @@ func (my *actorT) Lever28GetBagClearRequest(player *playerT,
 
 }
 
-func (my *actorT) Lever28GetHistoryRequest(player *playerT,
+func (my *actorT) Lever28GetHistoryRequest(playerId uint64,
 	ev *waka.Lever28GetHistoryRequest,
-	respond func(proto.Message, error)) {
+	respond func(*waka.Lever28GetHistoryResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	grabs, err := database.Lever28QueryGrabHistory(player.Player, 10)
+	grabs, grabNext, err := database.Lever28QueryGrabHistory(player.Player, database.HistoryCursorFromProto(ev.GrabCursor), ev.Limit)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -78,7 +66,7 @@ func (my *actorT) Lever28GetHistoryRequest(player *playerT,
 		return
 	}
 
-	hands, err := database.Lever28QueryHandHistory(player.Player, 10)
+	hands, handNext, err := database.Lever28QueryHandHistory(player.Player, database.HistoryCursorFromProto(ev.HandCursor), ev.Limit)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -87,5 +75,5 @@ func (my *actorT) Lever28GetHistoryRequest(player *playerT,
 		return
 	}
 
-	respond(&waka.Lever28GetHistoryResponse{grabs, hands}, nil)
+	respond(&waka.Lever28GetHistoryResponse{grabs, hands, grabNext.Proto(), handNext.Proto()}, nil)
 }
@@ -0,0 +1,18 @@
+package hall
+
+import (
+	"github.com/liuhan907/waka/waka-cow/database"
+	"github.com/liuhan907/waka/waka-cow/proto"
+)
+
+func (my *actorT) GetMyRequest(playerId uint64,
+	ev *cow_proto.GetMyRequest,
+	respond func(*cow_proto.GetMyResponse, error)) {
+	respond(&cow_proto.GetMyResponse{my.ToPlayerSecret(database.Player(playerId))}, nil)
+}
+
+func (my *actorT) GetPlayerRequest(playerId uint64,
+	ev *cow_proto.GetPlayerRequest,
+	respond func(*cow_proto.GetPlayerResponse, error)) {
+	respond(&cow_proto.GetPlayerResponse{my.ToPlayer(database.Player(ev.GetPlayerId()))}, nil)
+}
@@ -3,28 +3,16 @@ package hall
 import (
 	"errors"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/liuhan907/waka/waka-cow/database"
 	waka "github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"github.com/sirupsen/logrus"
 )
 
-func (my *actorT) playerFutureRequestedRed(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *waka.RedGetBagClearRequest:
-		my.RedGetBagClearRequest(player, evd, ev.Respond)
-	case *waka.RedGetHistoryRequest:
-		my.RedGetHistoryRequest(player, evd, ev.Respond)
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) RedGetBagClearRequest(player *playerT,
+func (my *actorT) RedGetBagClearRequest(playerId uint64,
 	ev *waka.RedGetBagClearRequest,
-	respond func(proto.Message, error)) {
+	respond func(*waka.RedGetBagClearResponse, error)) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideRed == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -63,11 +51,12 @@ func (my *actorT) RedGetBagClearRequest(player *playerT,
 	my.sendRedUpdateBagList(player.Player, my.redBags)
 }
 
-func (my *actorT) RedGetHistoryRequest(player *playerT,
+func (my *actorT) RedGetHistoryRequest(playerId uint64,
 	ev *waka.RedGetHistoryRequest,
-	respond func(proto.Message, error)) {
+	respond func(*waka.RedGetHistoryResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	grabs, err := database.RedQueryGrabHistory(player.Player, 10)
+	grabs, grabNext, err := database.RedQueryGrabHistory(player.Player, database.HistoryCursorFromProto(ev.GrabCursor), ev.Limit)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -76,7 +65,7 @@ func (my *actorT) RedGetHistoryRequest(player *playerT,
 		return
 	}
 
-	hands, err := database.RedQueryHandHistory(player.Player, 10)
+	hands, handNext, err := database.RedQueryHandHistory(player.Player, database.HistoryCursorFromProto(ev.HandCursor), ev.Limit)
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -85,5 +74,5 @@ func (my *actorT) RedGetHistoryRequest(player *playerT,
 		return
 	}
 
-	respond(&waka.RedGetHistoryResponse{grabs, hands}, nil)
+	respond(&waka.RedGetHistoryResponse{grabs, hands, grabNext.Proto(), handNext.Proto()}, nil)
 }
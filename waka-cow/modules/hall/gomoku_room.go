@@ -28,6 +28,7 @@ type gomokuRoomT struct {
 	Hall *actorT
 
 	Id      int32
+	Serial  int64
 	Creator *gomokuRoomPlayerT
 	Student *gomokuRoomPlayerT
 	Cost    int32
@@ -87,9 +88,10 @@ func (r *gomokuRoomT) Recover(player *playerT) {
 
 func (r *gomokuRoomT) Create(hall *actorT, player *playerT, id int32) {
 	*r = gomokuRoomT{
-		Hall:  hall,
-		Id:    id,
-		Board: gomoku.NewBoard(),
+		Hall:   hall,
+		Id:     id,
+		Serial: hall.newSerial(),
+		Board:  gomoku.NewBoard(),
 	}
 
 	r.Creator = &gomokuRoomPlayerT{
@@ -307,7 +309,16 @@ func (r *gomokuRoomT) loopSwitch() bool {
 func (r *gomokuRoomT) loopSettle() bool {
 	r.Hall.sendGomokuUpdateRoundForAll(r)
 
-	err := database.GomokuSettle(r.ThisPlayer.Player, r.AnotherPlayer.Player, r.Cost*100)
+	retrying, err := r.Hall.trySettle(r.Serial, r.RoundNumber, func() (*database.SettlementData, error) {
+		return database.GomokuSettle(r.Serial, r.RoundNumber, r.ThisPlayer.Player, r.AnotherPlayer.Player, int64(r.Cost)*100)
+	}, func() {
+		if r.Hall.gomokuRooms[r.Id] == r {
+			r.loop()
+		}
+	})
+	if retrying {
+		return false
+	}
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"err": err,
@@ -17,3 +17,8 @@ type GetPlayerRoom struct {
 type GetOnlinePlayer struct {
 	Respond func(response []int32, e error)
 }
+
+// 运行时设置已变更
+type SettingsChanged struct {
+	Settings *database.Settings
+}
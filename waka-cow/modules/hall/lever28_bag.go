@@ -62,6 +62,7 @@ type lever28BagT struct {
 	Hall *actorT
 
 	Id        int32
+	Serial    int64
 	Option    *cow_proto.Lever28BagOption
 	Creator   *lever28CreatorT
 	Players   lever28BagPlayerMapT
@@ -139,6 +140,7 @@ func (bag *lever28BagT) Create(hall *actorT, id int32, option *cow_proto.Lever28
 	*bag = lever28BagT{
 		Hall:   hall,
 		Id:     id,
+		Serial: hall.newSerial(),
 		Option: option,
 		Creator: &lever28CreatorT{
 			Player: creator,
@@ -157,7 +159,7 @@ func (bag *lever28BagT) Create(hall *actorT, id int32, option *cow_proto.Lever28
 		return
 	}
 
-	if creator.PlayerData().Money < bag.CreateMoney() {
+	if creator.PlayerData().Money < int64(bag.CreateMoney()) {
 		log.WithFields(logrus.Fields{
 			"creator":      creator,
 			"option":       option.String(),
@@ -180,7 +182,7 @@ func (bag *lever28BagT) Create(hall *actorT, id int32, option *cow_proto.Lever28
 
 	bag.RemainMoney = remainMoney
 
-	freeze, err := database.FreezeMoney(creator, bag.CreateMoney())
+	freeze, err := database.FreezeMoney(creator, int64(bag.CreateMoney()))
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"creator": creator,
@@ -227,7 +229,7 @@ func (bag *lever28BagT) Grab(player *playerT) {
 				return
 			}
 
-			if player.Player.PlayerData().Money < bag.EnterMoney() {
+			if player.Player.PlayerData().Money < int64(bag.EnterMoney()) {
 				log.WithFields(logrus.Fields{
 					"player":      player,
 					"enter_money": bag.EnterMoney(),
@@ -236,7 +238,7 @@ func (bag *lever28BagT) Grab(player *playerT) {
 				return
 			}
 
-			freeze, err = database.FreezeMoney(player.Player, bag.EnterMoney())
+			freeze, err = database.FreezeMoney(player.Player, int64(bag.EnterMoney()))
 			if err != nil {
 				log.WithFields(logrus.Fields{
 					"player":      player,
@@ -293,7 +295,7 @@ func (bag *lever28BagT) settle() {
 		})
 		costs.Grabs = append(costs.Grabs, &database.Lever28Grab{
 			Player: player.Player,
-			Number: player.Grab,
+			Number: int64(player.Grab),
 		})
 	}
 
@@ -338,7 +340,7 @@ func (bag *lever28BagT) settle() {
 			costs.Pays = append(costs.Pays, &database.Lever28Pay{
 				Payer:  player.Player,
 				Payee:  banker.Player,
-				Number: bag.Option.Money,
+				Number: int64(bag.Option.Money),
 			})
 		} else if bw < w {
 			player.Get = player.Get + bag.Option.Money
@@ -347,7 +349,7 @@ func (bag *lever28BagT) settle() {
 			costs.Pays = append(costs.Pays, &database.Lever28Pay{
 				Payer:  banker.Player,
 				Payee:  player.Player,
-				Number: bag.Option.Money,
+				Number: int64(bag.Option.Money),
 			})
 		} else if banker.Grab > player.Grab {
 			banker.Get = banker.Get + bag.Option.Money
@@ -356,7 +358,7 @@ func (bag *lever28BagT) settle() {
 			costs.Pays = append(costs.Pays, &database.Lever28Pay{
 				Payer:  player.Player,
 				Payee:  banker.Player,
-				Number: bag.Option.Money,
+				Number: int64(bag.Option.Money),
 			})
 		} else if banker.Grab < player.Grab {
 			player.Get = player.Get + bag.Option.Money
@@ -365,7 +367,7 @@ func (bag *lever28BagT) settle() {
 			costs.Pays = append(costs.Pays, &database.Lever28Pay{
 				Payer:  banker.Player,
 				Payee:  player.Player,
-				Number: bag.Option.Money,
+				Number: int64(bag.Option.Money),
 			})
 		}
 	}
@@ -377,7 +379,22 @@ func (bag *lever28BagT) settle() {
 	}
 
 	// 结算
-	if err := database.Lever28Settle(costs); err != nil {
+	bag.commit(costs)
+}
+
+// 提交结算, 暂时失败时由大厅延迟后重新提交
+func (bag *lever28BagT) commit(costs *database.Lever28BagCost) {
+	retrying, err := bag.Hall.trySettle(bag.Serial, 1, func() (*database.SettlementData, error) {
+		return database.Lever28Settle(bag.Serial, 1, costs)
+	}, func() {
+		if bag.Hall.lever28Bags[bag.Id] == bag && !bag.Settled {
+			bag.commit(costs)
+		}
+	})
+	if retrying {
+		return
+	}
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"id":      bag.Id,
 			"option":  bag.Option.String(),
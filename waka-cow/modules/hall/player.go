@@ -1,8 +1,11 @@
 package hall
 
 import (
+	"math"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/proto"
 )
@@ -39,7 +42,8 @@ func (my *actorT) ToPlayer(player database.Player) (pb *cow_proto.Player) {
 	pb.Id = int32(playerData.Id)
 	pb.Nickname = playerData.Nickname
 	pb.Head = playerData.Head
-	pb.Money = playerData.Money / 100
+	pb.Money = clampInt32(playerData.Money / 100)
+	pb.Money64 = playerData.Money / 100
 	pb.Vip = int64(playerData.Vip.Sub(time.Now()).Seconds() / (24 * 60 * 60))
 	pb.Wechat = playerData.Wechat
 
@@ -65,7 +69,8 @@ func (my *actorT) ToPlayerSecret(player database.Player) (pb *cow_proto.PlayerSe
 	pb.Wechat = playerData.Wechat
 	pb.Idcard = playerData.Idcard
 	pb.Name = playerData.Name
-	pb.Money = playerData.Money / 100
+	pb.Money = clampInt32(playerData.Money / 100)
+	pb.Money64 = playerData.Money / 100
 	pb.Vip = int64(playerData.Vip.Sub(time.Now()).Seconds() / (24 * 60 * 60))
 	pb.Supervisor = int32(playerData.Supervisor)
 	pb.CreatedAt = playerData.CreatedAt.Format("2006-01-02 15:04:05")
@@ -87,3 +92,32 @@ func (my *actorT) ToPlayerMap(players map[database.Player]database.Player) (pb [
 	}
 	return pb
 }
+
+// 一次查询载入多个玩家的数据, 查询失败或不存在的玩家与 PlayerData 一样使用默认值
+func queryPlayers(players []database.Player) map[database.Player]*database.PlayerData {
+	r, err := database.QueryPlayers(players)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"players": players,
+			"err":     err,
+		}).Warnln("query players failed")
+		r = make(map[database.Player]*database.PlayerData, len(players))
+	}
+	for _, player := range players {
+		if _, being := r[player]; !being {
+			r[player] = player.PlayerData()
+		}
+	}
+	return r
+}
+
+// 转换为 int32, 超出范围时取边界值, 用于填充旧协议中的 32 位字段
+func clampInt32(number int64) int32 {
+	if number > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if number < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(number)
+}
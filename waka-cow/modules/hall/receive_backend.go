@@ -15,6 +15,8 @@ func (my *actorT) ReceiveBackend(context actor.Context) bool {
 		my.GetPlayerRoom(evd)
 	case *hall_message.GetOnlinePlayer:
 		my.GetOnlinePlayer(evd)
+	case *hall_message.SettingsChanged:
+		my.SettingsChanged(evd)
 	default:
 		return false
 	}
@@ -42,3 +44,11 @@ func (my *actorT) GetOnlinePlayer(evd *hall_message.GetOnlinePlayer) {
 	}).ToSlice(&r)
 	evd.Respond(r, nil)
 }
+
+// 向在线玩家推送新的欢迎消息
+func (my *actorT) SettingsChanged(evd *hall_message.SettingsChanged) {
+	welcome := evd.Settings.Welcome()
+	for player := range my.players.SelectOnline() {
+		my.send(player, welcome)
+	}
+}
@@ -7,11 +7,13 @@ import (
 )
 
 func (my *actorT) ReceiveClock(context actor.Context) bool {
-	switch context.Message().(type) {
+	switch evd := context.Message().(type) {
 	case *clock1:
 		my.clock1()
 	case *clock3:
 		my.clock3()
+	case *settleRetry:
+		my.settleRetried(evd)
 	default:
 		return false
 	}
@@ -9,9 +9,16 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/liuhan907/waka/waka-cow/database"
+	"github.com/liuhan907/waka/waka-cow/proto"
 	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
+// hall 通过生成的分发函数处理玩家消息
+var (
+	_ cow_proto.HallTransportHandler = (*actorT)(nil)
+	_ cow_proto.HallFutureHandler    = (*actorT)(nil)
+)
+
 func (my *actorT) ReceiveSupervisor(context actor.Context) bool {
 	switch ev := context.Message().(type) {
 	case *supervisor_message.PlayerEntered:
@@ -181,8 +188,7 @@ func (my *actorT) playerTransported(ev *supervisor_message.PlayerTransported) {
 
 	player := database.Player(ev.Player)
 
-	playerData, being := my.players[player]
-	if !being {
+	if _, being := my.players[player]; !being {
 		log.WithFields(logrus.Fields{
 			"player":  ev.Player,
 			"type":    reflect.TypeOf(ev.Payload).Elem().Name(),
@@ -191,16 +197,7 @@ func (my *actorT) playerTransported(ev *supervisor_message.PlayerTransported) {
 		return
 	}
 
-	if my.playerTransportedCow(playerData, ev) {
-		return
-	}
-	if my.playerTransportedRed(playerData, ev) {
-		return
-	}
-	if my.playerTransportedLever28(playerData, ev) {
-		return
-	}
-	if my.playerTransportedGomoku(playerData, ev) {
+	if cow_proto.DispatchHallTransport(my, ev.Player, ev.Payload) {
 		return
 	}
 
@@ -222,8 +219,7 @@ func (my *actorT) playerFutureRequested(ev *supervisor_message.PlayerFutureReque
 
 	player := database.Player(ev.Player)
 
-	playerData, being := my.players[player]
-	if !being {
+	if _, being := my.players[player]; !being {
 		log.WithFields(logrus.Fields{
 			"player":  ev.Player,
 			"type":    reflect.TypeOf(ev.Payload).Elem().Name(),
@@ -232,16 +228,7 @@ func (my *actorT) playerFutureRequested(ev *supervisor_message.PlayerFutureReque
 		return
 	}
 
-	if my.playerFutureRequestedCow(playerData, ev) {
-		return
-	}
-	if my.playerFutureRequestedRed(playerData, ev) {
-		return
-	}
-	if my.playerFutureRequestedLever28(playerData, ev) {
-		return
-	}
-	if my.playerFutureRequestedGomoku(playerData, ev) {
+	if cow_proto.DispatchHallFuture(my, ev.Player, ev.Payload, ev.Respond) {
 		return
 	}
 
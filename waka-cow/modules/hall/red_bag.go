@@ -76,6 +76,7 @@ type redBagT struct {
 	Hall *actorT
 
 	Id        int32
+	Serial    int64
 	Option    *cow_proto.RedBagOption
 	Creator   *redBagCreatorT
 	Players   redBagPlayerMapT
@@ -165,6 +166,7 @@ func (bag *redBagT) Create(hall *actorT, id int32, option *cow_proto.RedBagOptio
 	*bag = redBagT{
 		Hall:   hall,
 		Id:     id,
+		Serial: hall.newSerial(),
 		Option: option,
 		Creator: &redBagCreatorT{
 			Player: creator,
@@ -174,7 +176,7 @@ func (bag *redBagT) Create(hall *actorT, id int32, option *cow_proto.RedBagOptio
 		Players:  make(redBagPlayerMapT, 10),
 	}
 
-	if creator.PlayerData().Money < bag.CreateMoney() {
+	if creator.PlayerData().Money < int64(bag.CreateMoney()) {
 		log.WithFields(logrus.Fields{
 			"creator":      creator,
 			"option":       option.String(),
@@ -197,7 +199,7 @@ func (bag *redBagT) Create(hall *actorT, id int32, option *cow_proto.RedBagOptio
 
 	bag.RemainMoney = remainMoney
 
-	freeze, err := database.FreezeMoney(creator, bag.CreateMoney())
+	freeze, err := database.FreezeMoney(creator, int64(bag.CreateMoney()))
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"creator": creator,
@@ -240,7 +242,7 @@ func (bag *redBagT) Grab(player *playerT) {
 			return
 		}
 
-		if player.Player.PlayerData().Money < bag.EnterMoney() {
+		if player.Player.PlayerData().Money < int64(bag.EnterMoney()) {
 			log.WithFields(logrus.Fields{
 				"player":      player,
 				"enter_money": bag.EnterMoney(),
@@ -249,7 +251,7 @@ func (bag *redBagT) Grab(player *playerT) {
 			return
 		}
 
-		freeze, err := database.FreezeMoney(player.Player, bag.EnterMoney())
+		freeze, err := database.FreezeMoney(player.Player, int64(bag.EnterMoney()))
 		if err != nil {
 			log.WithFields(logrus.Fields{
 				"player":      player,
@@ -304,7 +306,7 @@ func (bag *redBagT) settle() {
 		costs.Grabs = append(costs.Grabs, &database.RedGrabCost{
 			Player: player.Player,
 			Freeze: player.Freeze,
-			Number: player.Grab,
+			Number: int64(player.Grab),
 		})
 	}
 	if linq.From(bag.Players).
@@ -326,7 +328,7 @@ func (bag *redBagT) settle() {
 			}) {
 				costs.Pays = append(costs.Pays, &database.RedPayCost{
 					Player: player.Player,
-					Number: bag.LostMoney(),
+					Number: int64(bag.LostMoney()),
 				})
 			}
 		}
@@ -338,7 +340,7 @@ func (bag *redBagT) settle() {
 
 	for _, pay := range costs.Pays {
 		if player := bag.Players[pay.Player]; player != nil {
-			player.Pay = pay.Number
+			player.Pay = int32(pay.Number)
 		}
 	}
 
@@ -352,7 +354,22 @@ func (bag *redBagT) settle() {
 
 	bag.Creator.Cost = bag.CreateMoney()
 
-	if err := database.RedBagCostSettle(costs); err != nil {
+	bag.commit(costs)
+}
+
+// 提交结算, 暂时失败时由大厅延迟后重新提交
+func (bag *redBagT) commit(costs *database.RedBagCost) {
+	retrying, err := bag.Hall.trySettle(bag.Serial, 1, func() (*database.SettlementData, error) {
+		return database.RedBagCostSettle(bag.Serial, 1, costs)
+	}, func() {
+		if bag.Hall.redBags[bag.Id] == bag && !bag.Settled {
+			bag.commit(costs)
+		}
+	})
+	if retrying {
+		return
+	}
+	if err != nil {
 		log.WithFields(logrus.Fields{
 			"id":     bag.Id,
 			"option": bag.Option.String(),
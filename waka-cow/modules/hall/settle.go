@@ -0,0 +1,103 @@
+package hall
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+const (
+	// 结算的最多尝试次数
+	kSettleAttempts = 3
+	// 结算重试间隔, 按已尝试次数递增
+	kSettleRetryInterval = time.Millisecond * 200
+)
+
+// 分配房间实例序号, 房间号会被回收, 结算时用序号区分房间
+func (my *actorT) newSerial() int64 {
+	return atomic.AddInt64(&my.serial, 1)
+}
+
+// 结算的键, 房间实例序号与局数
+type settleKey struct {
+	Room  int64
+	Round int32
+}
+
+// 结算的重试状态
+type settleStateT struct {
+	// 已尝试次数
+	Attempts int
+	// 已安排重试, 尚未执行
+	Pending bool
+}
+
+// 结算重试消息, 延迟后发给大厅, 由大厅重新执行结算所在的步骤
+type settleRetry struct {
+	Key   settleKey
+	Retry func()
+}
+
+// 执行一次结算, 暂时失败时不阻塞大厅, 延迟后在大厅中调用 retry 重新执行结算所在的步骤
+// 返回 true 表示结算尚未完成, 调用者保持当前状态直接返回
+// 结算以房间实例与局数为键, 重新执行不会重复结算
+func (my *actorT) trySettle(room int64, round int32, settle func() (*database.SettlementData, error), retry func()) (bool, error) {
+	key := settleKey{room, round}
+	state := my.settles[key]
+	if state == nil {
+		state = &settleStateT{}
+		my.settles[key] = state
+	}
+	if state.Pending {
+		return true, nil
+	}
+
+	state.Attempts++
+	_, err := settle()
+	if err == nil || state.Attempts >= kSettleAttempts {
+		delete(my.settles, key)
+		return false, err
+	}
+	switch errors.Cause(err) {
+	case database.ErrMoneyNotEnough,
+		database.ErrPlayerNotFound,
+		database.ErrFreezeNotFound,
+		database.ErrFreezeRecovered,
+		database.ErrUnbalanced:
+		delete(my.settles, key)
+		return false, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"room":    room,
+		"round":   round,
+		"attempt": state.Attempts,
+		"err":     err,
+	}).Warnln("settle failed, retry")
+
+	state.Pending = true
+	time.AfterFunc(kSettleRetryInterval*time.Duration(state.Attempts), func() {
+		my.pid.Tell(&settleRetry{key, retry})
+	})
+
+	return true, nil
+}
+
+func (my *actorT) settleRetried(evd *settleRetry) {
+	state := my.settles[evd.Key]
+	if state == nil {
+		return
+	}
+	state.Pending = false
+
+	evd.Retry()
+
+	// 房间已不在结算的步骤, 不再重试
+	if state := my.settles[evd.Key]; state != nil && !state.Pending {
+		delete(my.settles, evd.Key)
+	}
+}
@@ -0,0 +1,117 @@
+package hall
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+
+	"github.com/liuhan907/waka/waka-cow/database"
+)
+
+// 把收到的结算重试消息转给测试
+type settleRetryActor chan *settleRetry
+
+func (c settleRetryActor) Receive(context actor.Context) {
+	if evd, ok := context.Message().(*settleRetry); ok {
+		c <- evd
+	}
+}
+
+func newSettleTestActor() (*actorT, settleRetryActor) {
+	retries := make(settleRetryActor, 1)
+	my := newActor(nil)
+	my.pid = actor.Spawn(actor.FromInstance(retries))
+	return my, retries
+}
+
+func waitSettleRetry(t *testing.T, retries settleRetryActor) *settleRetry {
+	select {
+	case evd := <-retries:
+		return evd
+	case <-time.After(time.Second * 3):
+		t.Fatal("settle retry not sent")
+		return nil
+	}
+}
+
+// 暂时失败时不阻塞大厅, 延迟后重新执行结算的步骤, 最多尝试 kSettleAttempts 次
+func TestTrySettleRetries(t *testing.T) {
+	my, retries := newSettleTestActor()
+
+	failed := errors.New("connection lost")
+	calls := 0
+	settle := func() (*database.SettlementData, error) {
+		calls++
+		return nil, failed
+	}
+
+	var step func()
+	var result error
+	finished := false
+	step = func() {
+		retrying, err := my.trySettle(1, 1, settle, step)
+		if !retrying {
+			finished = true
+			result = err
+		}
+	}
+
+	start := time.Now()
+	step()
+	if elapsed := time.Since(start); elapsed >= kSettleRetryInterval {
+		t.Fatalf("trySettle blocked for %v", elapsed)
+	}
+	if finished || calls != 1 {
+		t.Fatalf("finished %v after %d calls", finished, calls)
+	}
+
+	// 等待重试期间再次执行步骤不会重复尝试
+	step()
+	if calls != 1 {
+		t.Fatalf("settled %d times while retry pending", calls)
+	}
+
+	for i := 1; i < kSettleAttempts; i++ {
+		my.settleRetried(waitSettleRetry(t, retries))
+	}
+	if !finished || result != failed || calls != kSettleAttempts {
+		t.Fatalf("finished %v with %v after %d calls", finished, result, calls)
+	}
+	if len(my.settles) != 0 {
+		t.Fatalf("settle states left %v", my.settles)
+	}
+}
+
+func TestTrySettleStopsRetrying(t *testing.T) {
+	my, retries := newSettleTestActor()
+
+	// 钱不够等确定的错误不重试
+	retrying, err := my.trySettle(1, 1, func() (*database.SettlementData, error) {
+		return nil, database.ErrMoneyNotEnough
+	}, func() { t.Fatal("retried money not enough") })
+	if retrying || err != database.ErrMoneyNotEnough {
+		t.Fatalf("trySettle = %v, %v", retrying, err)
+	}
+
+	// 房间离开结算的步骤后不再重试
+	retrying, _ = my.trySettle(2, 1, func() (*database.SettlementData, error) {
+		return nil, errors.New("connection lost")
+	}, func() {})
+	if !retrying {
+		t.Fatal("transient error not retried")
+	}
+	my.settleRetried(waitSettleRetry(t, retries))
+	if len(my.settles) != 0 {
+		t.Fatalf("settle states left %v", my.settles)
+	}
+
+	// 成功后清除重试状态
+	retrying, err = my.trySettle(3, 1, func() (*database.SettlementData, error) {
+		return &database.SettlementData{}, nil
+	}, func() {})
+	if retrying || err != nil || len(my.settles) != 0 {
+		t.Fatalf("trySettle = %v, %v, states %v", retrying, err, my.settles)
+	}
+}
@@ -6,40 +6,11 @@ import (
 	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/modules/hall/tools/cow"
 	"github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
-func (my *actorT) playerTransportedCow(player *playerT, ev *supervisor_message.PlayerTransported) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.NiuniuCreateRoom:
-		my.NiuniuCreateRoom(player, evd)
-	case *cow_proto.NiuniuJoinRoom:
-		my.NiuniuJoinRoom(player, evd)
-	case *cow_proto.NiuniuLeaveRoom:
-		my.NiuniuLeaveRoom(player, evd)
-	case *cow_proto.NiuniuSwitchReady:
-		my.NiuniuSwitchReady(player, evd)
-	case *cow_proto.NiuniuDismiss:
-		my.NiuniuDismiss(player, evd)
-	case *cow_proto.NiuniuStart:
-		my.NiuniuStart(player, evd)
-	case *cow_proto.NiuniuSpecifyBanker:
-		my.NiuniuSpecifyBanker(player, evd)
-	case *cow_proto.NiuniuGrab:
-		my.NiuniuGrab(player, evd)
-	case *cow_proto.NiuniuSpecifyRate:
-		my.NiuniuSpecifyRate(player, evd)
-	case *cow_proto.NiuniuCommitPokers:
-		my.NiuniuCommitPokers(player, evd)
-	case *cow_proto.NiuniuContinueWith:
-		my.NiuniuContinueWith(player, evd)
-	default:
-		return false
-	}
-	return true
-}
+func (my *actorT) NiuniuCreateRoom(playerId uint64, ev *cow_proto.NiuniuCreateRoom) {
+	player := my.players[database.Player(playerId)]
 
-func (my *actorT) NiuniuCreateRoom(player *playerT, ev *cow_proto.NiuniuCreateRoom) {
 	if player.InsideCow != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -83,7 +54,9 @@ func (my *actorT) NiuniuCreateRoom(player *playerT, ev *cow_proto.NiuniuCreateRo
 	room.CreateRoom(my, id, ev.GetType(), ev.GetOption(), player.Player)
 }
 
-func (my *actorT) NiuniuJoinRoom(player *playerT, ev *cow_proto.NiuniuJoinRoom) {
+func (my *actorT) NiuniuJoinRoom(playerId uint64, ev *cow_proto.NiuniuJoinRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -105,7 +78,9 @@ func (my *actorT) NiuniuJoinRoom(player *playerT, ev *cow_proto.NiuniuJoinRoom)
 	room.JoinRoom(player)
 }
 
-func (my *actorT) NiuniuLeaveRoom(player *playerT, ev *cow_proto.NiuniuLeaveRoom) {
+func (my *actorT) NiuniuLeaveRoom(playerId uint64, ev *cow_proto.NiuniuLeaveRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -126,7 +101,9 @@ func (my *actorT) NiuniuLeaveRoom(player *playerT, ev *cow_proto.NiuniuLeaveRoom
 	room.LeaveRoom(player)
 }
 
-func (my *actorT) NiuniuSwitchReady(player *playerT, ev *cow_proto.NiuniuSwitchReady) {
+func (my *actorT) NiuniuSwitchReady(playerId uint64, ev *cow_proto.NiuniuSwitchReady) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -147,7 +124,9 @@ func (my *actorT) NiuniuSwitchReady(player *playerT, ev *cow_proto.NiuniuSwitchR
 	room.SwitchReady(player)
 }
 
-func (my *actorT) NiuniuDismiss(player *playerT, ev *cow_proto.NiuniuDismiss) {
+func (my *actorT) NiuniuDismiss(playerId uint64, ev *cow_proto.NiuniuDismiss) {
+	player := my.players[database.Player(playerId)]
+
 	roomId := int32(0)
 
 	if ev.GetRoomId() != 0 {
@@ -175,7 +154,9 @@ func (my *actorT) NiuniuDismiss(player *playerT, ev *cow_proto.NiuniuDismiss) {
 	room.Dismiss(player)
 }
 
-func (my *actorT) NiuniuStart(player *playerT, ev *cow_proto.NiuniuStart) {
+func (my *actorT) NiuniuStart(playerId uint64, ev *cow_proto.NiuniuStart) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -196,7 +177,9 @@ func (my *actorT) NiuniuStart(player *playerT, ev *cow_proto.NiuniuStart) {
 	room.Start(player)
 }
 
-func (my *actorT) NiuniuSpecifyBanker(player *playerT, ev *cow_proto.NiuniuSpecifyBanker) {
+func (my *actorT) NiuniuSpecifyBanker(playerId uint64, ev *cow_proto.NiuniuSpecifyBanker) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -217,7 +200,9 @@ func (my *actorT) NiuniuSpecifyBanker(player *playerT, ev *cow_proto.NiuniuSpeci
 	room.SpecifyBanker(player, database.Player(ev.GetBanker()))
 }
 
-func (my *actorT) NiuniuGrab(player *playerT, ev *cow_proto.NiuniuGrab) {
+func (my *actorT) NiuniuGrab(playerId uint64, ev *cow_proto.NiuniuGrab) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -238,7 +223,9 @@ func (my *actorT) NiuniuGrab(player *playerT, ev *cow_proto.NiuniuGrab) {
 	room.Grab(player, ev.GetDoing())
 }
 
-func (my *actorT) NiuniuSpecifyRate(player *playerT, ev *cow_proto.NiuniuSpecifyRate) {
+func (my *actorT) NiuniuSpecifyRate(playerId uint64, ev *cow_proto.NiuniuSpecifyRate) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -263,7 +250,9 @@ func (my *actorT) NiuniuSpecifyRate(player *playerT, ev *cow_proto.NiuniuSpecify
 	room.SpecifyRate(player, ev.GetRate())
 }
 
-func (my *actorT) NiuniuCommitPokers(player *playerT, ev *cow_proto.NiuniuCommitPokers) {
+func (my *actorT) NiuniuCommitPokers(playerId uint64, ev *cow_proto.NiuniuCommitPokers) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -289,7 +278,9 @@ func (my *actorT) NiuniuCommitPokers(player *playerT, ev *cow_proto.NiuniuCommit
 	room.CommitPokers(player, ev.GetPokers())
 }
 
-func (my *actorT) NiuniuContinueWith(player *playerT, ev *cow_proto.NiuniuContinueWith) {
+func (my *actorT) NiuniuContinueWith(playerId uint64, ev *cow_proto.NiuniuContinueWith) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
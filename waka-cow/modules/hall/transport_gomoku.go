@@ -1,36 +1,14 @@
 package hall
 
 import (
+	"github.com/liuhan907/waka/waka-cow/database"
 	"github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"github.com/sirupsen/logrus"
 )
 
-func (my *actorT) playerTransportedGomoku(player *playerT, ev *supervisor_message.PlayerTransported) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.GomokuCreateRoom:
-		my.GomokuCreateRoom(player, evd)
-	case *cow_proto.GomokuJoinRoom:
-		my.GomokuJoinRoom(player, evd)
-	case *cow_proto.GomokuSetCost:
-		my.GomokuSetCost(player, evd)
-	case *cow_proto.GomokuLeave:
-		my.GomokuLeave(player, evd)
-	case *cow_proto.GomokuDismiss:
-		my.GomokuDismiss(player, evd)
-	case *cow_proto.GomokuStart:
-		my.GomokuStart(player, evd)
-	case *cow_proto.GomokuPlay:
-		my.GomokuPlay(player, evd)
-	case *cow_proto.GomokuSurrender:
-		my.GomokuSurrender(player, evd)
-	default:
-		return false
-	}
-	return true
-}
+func (my *actorT) GomokuCreateRoom(playerId uint64, ev *cow_proto.GomokuCreateRoom) {
+	player := my.players[database.Player(playerId)]
 
-func (my *actorT) GomokuCreateRoom(player *playerT, ev *cow_proto.GomokuCreateRoom) {
 	if player.InsideGomoku != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -52,7 +30,9 @@ func (my *actorT) GomokuCreateRoom(player *playerT, ev *cow_proto.GomokuCreateRo
 	room.Create(my, player, id)
 }
 
-func (my *actorT) GomokuJoinRoom(player *playerT, ev *cow_proto.GomokuJoinRoom) {
+func (my *actorT) GomokuJoinRoom(playerId uint64, ev *cow_proto.GomokuJoinRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -99,7 +79,7 @@ func (my *actorT) GomokuJoinRoom(player *playerT, ev *cow_proto.GomokuJoinRoom)
 		return
 	}
 
-	if player.Player.PlayerData().Money < room.Cost*100 {
+	if player.Player.PlayerData().Money < int64(room.Cost)*100 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
 			"id":     ev.GetRoomId(),
@@ -111,7 +91,9 @@ func (my *actorT) GomokuJoinRoom(player *playerT, ev *cow_proto.GomokuJoinRoom)
 	room.Join(player)
 }
 
-func (my *actorT) GomokuSetCost(player *playerT, ev *cow_proto.GomokuSetCost) {
+func (my *actorT) GomokuSetCost(playerId uint64, ev *cow_proto.GomokuSetCost) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -151,7 +133,7 @@ func (my *actorT) GomokuSetCost(player *playerT, ev *cow_proto.GomokuSetCost) {
 		return
 	}
 
-	if room.Student != nil && room.Student.Player.PlayerData().Money < ev.GetCost()*100 {
+	if room.Student != nil && room.Student.Player.PlayerData().Money < int64(ev.GetCost())*100 {
 		log.WithFields(logrus.Fields{
 			"player":  player.Player,
 			"student": room.Student.Player,
@@ -165,7 +147,9 @@ func (my *actorT) GomokuSetCost(player *playerT, ev *cow_proto.GomokuSetCost) {
 	room.SetCost(player, ev.Cost)
 }
 
-func (my *actorT) GomokuLeave(player *playerT, ev *cow_proto.GomokuLeave) {
+func (my *actorT) GomokuLeave(playerId uint64, ev *cow_proto.GomokuLeave) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -190,7 +174,9 @@ func (my *actorT) GomokuLeave(player *playerT, ev *cow_proto.GomokuLeave) {
 	room.Leave(player)
 }
 
-func (my *actorT) GomokuDismiss(player *playerT, ev *cow_proto.GomokuDismiss) {
+func (my *actorT) GomokuDismiss(playerId uint64, ev *cow_proto.GomokuDismiss) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -211,7 +197,9 @@ func (my *actorT) GomokuDismiss(player *playerT, ev *cow_proto.GomokuDismiss) {
 	room.Dismiss(player)
 }
 
-func (my *actorT) GomokuStart(player *playerT, ev *cow_proto.GomokuStart) {
+func (my *actorT) GomokuStart(playerId uint64, ev *cow_proto.GomokuStart) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -240,7 +228,9 @@ func (my *actorT) GomokuStart(player *playerT, ev *cow_proto.GomokuStart) {
 	room.Start(player)
 }
 
-func (my *actorT) GomokuPlay(player *playerT, ev *cow_proto.GomokuPlay) {
+func (my *actorT) GomokuPlay(playerId uint64, ev *cow_proto.GomokuPlay) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -265,7 +255,9 @@ func (my *actorT) GomokuPlay(player *playerT, ev *cow_proto.GomokuPlay) {
 	room.Play(player, ev.GetX(), ev.GetY())
 }
 
-func (my *actorT) GomokuSurrender(player *playerT, ev *cow_proto.GomokuSurrender) {
+func (my *actorT) GomokuSurrender(playerId uint64, ev *cow_proto.GomokuSurrender) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideGomoku == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
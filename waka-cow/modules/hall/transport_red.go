@@ -3,26 +3,14 @@ package hall
 import (
 	"sync/atomic"
 
+	"github.com/liuhan907/waka/waka-cow/database"
 	waka "github.com/liuhan907/waka/waka-cow/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"github.com/sirupsen/logrus"
 )
 
-func (my *actorT) playerTransportedRed(player *playerT, ev *supervisor_message.PlayerTransported) bool {
-	switch evd := ev.Payload.(type) {
-	case *waka.RedCreateBag:
-		my.RedCreateBag(player, evd)
-	case *waka.RedGrab:
-		my.RedGrab(player, evd)
-	case *waka.RedLeave:
-		my.RedLeave(player, evd)
-	default:
-		return false
-	}
-	return true
-}
+func (my *actorT) RedCreateBag(playerId uint64, ev *waka.RedCreateBag) {
+	player := my.players[database.Player(playerId)]
 
-func (my *actorT) RedCreateBag(player *playerT, ev *waka.RedCreateBag) {
 	ev.GetOption().Money *= 100
 
 	if ev.GetOption().GetNumber() != 7 && ev.GetOption().GetNumber() != 10 {
@@ -58,7 +46,9 @@ func (my *actorT) RedCreateBag(player *playerT, ev *waka.RedCreateBag) {
 	bag.Create(my, id, ev.GetOption(), player.Player)
 }
 
-func (my *actorT) RedGrab(player *playerT, ev *waka.RedGrab) {
+func (my *actorT) RedGrab(playerId uint64, ev *waka.RedGrab) {
+	player := my.players[database.Player(playerId)]
+
 	bag, being := my.redBags[ev.GetId()]
 	if !being {
 		log.WithFields(logrus.Fields{
@@ -72,6 +62,8 @@ func (my *actorT) RedGrab(player *playerT, ev *waka.RedGrab) {
 	bag.Grab(player)
 }
 
-func (my *actorT) RedLeave(player *playerT, ev *waka.RedLeave) {
+func (my *actorT) RedLeave(playerId uint64, ev *waka.RedLeave) {
+	player := my.players[database.Player(playerId)]
+
 	player.InsideRed = 0
 }
@@ -5,7 +5,6 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/liuhan907/waka/waka-cow/database"
-	"github.com/liuhan907/waka/waka-cow/proto"
 	"github.com/liuhan907/waka/waka/modules/session/session_message"
 )
 
@@ -27,10 +26,5 @@ func (my *actorT) started(context actor.Context) {
 	})
 	my.pid = context.Self()
 
-	my.conn.Tell(&session_message.Send{&cow_proto.Welcome{
-		Customers: database.GetCustomerServices(),
-		Exts:      database.GetExt(),
-		Notices:   database.GetNotices(),
-		Urls:      database.GetUrls(),
-	}})
+	my.conn.Tell(&session_message.Send{database.GetSettings().Welcome()})
 }
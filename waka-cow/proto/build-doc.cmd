@@ -0,0 +1,6 @@
+@echo off
+
+set ProtoName=cow
+
+protoc.exe %ProtoName%.proto --waka_out=lang=markdown:.
+protoc.exe %ProtoName%.proto --waka_out=lang=html:.
\ No newline at end of file
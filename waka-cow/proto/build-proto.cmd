@@ -9,7 +9,8 @@ protoc %ProtoName%.proto --msg_out=%ProtoName%.pb.meta.go:.
 
 protoc.exe %ProtoName%.proto --csharp_out .
 protoc.exe %ProtoName%.proto --cellnet_out=.
-protoc.exe %ProtoName%.proto --waka_out=.
+protoc.exe %ProtoName%.proto --waka_out=lang=csharp:.
+protoc.exe %ProtoName%.proto --waka_out=lang=go:.
 
 if not exist %SDKPath%\Release mkdir %SDKPath%\Release
 
@@ -1,17 +1,21 @@
 package backend
 
 import (
+	"encoding/json"
 	"net"
 	"net/http"
 	"os"
 	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/liuhan907/waka/waka-cow2/database"
 	"github.com/liuhan907/waka/waka-cow2/modules/hall/hall_message"
+	"github.com/liuhan907/waka/waka/codec"
 )
 
 var (
@@ -23,6 +27,7 @@ var (
 
 type httpHandler struct {
 	target *actor.PID
+	reload func() error
 }
 
 func (w *httpHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
@@ -57,6 +62,14 @@ func (w *httpHandler) ServeHTTP(response http.ResponseWriter, request *http.Requ
 			w.playerChanged(response, request)
 		case "/configurationChanged":
 			w.configurationChanged(response, request)
+		case "/settingsReload":
+			w.settingsReload(response, request)
+		case "/getMessageRegistry":
+			w.getMessageRegistry(response, request)
+		case "/getArchivedHistory":
+			w.getArchivedHistory(response, request)
+		case "/getHistorySummary":
+			w.getHistorySummary(response, request)
 		default:
 			response.WriteHeader(405)
 		}
@@ -195,10 +208,136 @@ func (w *httpHandler) playerChanged(response http.ResponseWriter, request *http.
 }
 
 func (w *httpHandler) configurationChanged(response http.ResponseWriter, request *http.Request) {
-	database.RefreshConfiguration()
+	if err := database.RefreshConfiguration(); err != nil {
+		response.WriteHeader(500)
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("refresh configuration failed")
+		return
+	}
+	response.WriteHeader(200)
+}
+
+func (w *httpHandler) settingsReload(response http.ResponseWriter, request *http.Request) {
+	if err := w.reload(); err != nil {
+		response.WriteHeader(500)
+		response.Write([]byte(err.Error()))
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("reload settings failed")
+		return
+	}
 	response.WriteHeader(200)
 }
 
+func (w *httpHandler) getMessageRegistry(response http.ResponseWriter, request *http.Request) {
+	d, err := json.Marshal(codec.Registry())
+	if err != nil {
+		response.WriteHeader(500)
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("marshal message registry failed")
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(d)
+}
+
+func (w *httpHandler) getArchivedHistory(response http.ResponseWriter, request *http.Request) {
+	id, err := strconv.ParseInt(request.Form.Get("id"), 10, 64)
+	if err != nil {
+		response.WriteHeader(400)
+		return
+	}
+
+	history, err := database.QueryArchivedHistory(request.Form.Get("source"), id)
+	switch errors.Cause(err) {
+	case nil:
+	case database.ErrUnknownArchiveSource:
+		response.WriteHeader(400)
+		return
+	case database.ErrArchiveNotFound:
+		response.WriteHeader(404)
+		return
+	default:
+		response.WriteHeader(500)
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("query archived history failed")
+		return
+	}
+
+	w.writeJSON(response, request, history)
+}
+
+// from 与 to 为 2006-01-02 格式的日期, 均包含在内, 默认为最近 30 天
+func (w *httpHandler) getHistorySummary(response http.ResponseWriter, request *http.Request) {
+	player, err := strconv.ParseInt(request.Form.Get("player_id"), 10, 64)
+	if err != nil {
+		response.WriteHeader(400)
+		return
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	if param := request.Form.Get("to"); param != "" {
+		if to, err = time.ParseInLocation("2006-01-02", param, time.Local); err != nil {
+			response.WriteHeader(400)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -30)
+	if param := request.Form.Get("from"); param != "" {
+		if from, err = time.ParseInLocation("2006-01-02", param, time.Local); err != nil {
+			response.WriteHeader(400)
+			return
+		}
+	}
+
+	summaries, err := database.QueryHistorySummaries(database.Player(player), from, to)
+	if err != nil {
+		response.WriteHeader(500)
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("query history summaries failed")
+		return
+	}
+
+	w.writeJSON(response, request, summaries)
+}
+
+func (w *httpHandler) writeJSON(response http.ResponseWriter, request *http.Request, v interface{}) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		response.WriteHeader(500)
+
+		log.WithFields(logrus.Fields{
+			"method":      request.Method,
+			"request_uri": request.RequestURI,
+			"err":         err,
+		}).Warnln("marshal response failed")
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(d)
+}
+
 // 消息转发目标创建器
 type TargetCreator func() *actor.PID
 
@@ -207,6 +346,9 @@ type Option struct {
 	// 创建器
 	TargetCreator TargetCreator
 
+	// 重新加载配置文件中的运行时设置
+	Reload func() error
+
 	// 监听地址
 	Address string
 }
@@ -223,6 +365,7 @@ func Start(option Option) {
 	go func() {
 		err := http.Serve(l, &httpHandler{
 			target: option.TargetCreator(),
+			reload: option.Reload,
 		})
 		if err != nil {
 			log.WithFields(logrus.Fields{
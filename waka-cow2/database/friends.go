@@ -4,9 +4,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	ErrAskNotFound = errors.New("ask not found")
+)
+
 // 好友
 type FriendData struct {
 	// 主键
@@ -44,57 +49,37 @@ type AskData struct {
 
 // 查询好友
 func QueryFriendList(player Player) (d []*FriendData, e error) {
-	if err := mysql.Where("player = ? and ban = ?", player, false).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindFriends(player, false)
 }
 
 // 查询被屏蔽好友
 func QueryBanFriendList(player Player) (d []*FriendData, e error) {
-	if err := mysql.Where("player = ? and ban = ?", player, true).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindFriends(player, true)
 }
 
 // 查询已发送的添加列表
 func QueryWantListSend(player Player) (d []*AskData, e error) {
-	if err := mysql.Where("sender = ? and status = ?", player, 0).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindAsksBySender(player, false, 0)
 }
 
 // 查询已被处理的添加列表
 func QueryWantListDeal(player Player, limit int32) (d []*AskData, e error) {
-	if err := mysql.Where("sender = ? and status <> ?", player, 0).Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindAsksBySender(player, true, limit)
 }
 
 // 查询未处理的申请列表
 func QueryAskListUndeal(player Player) (d []*AskData, e error) {
-	if err := mysql.Where("player = ? and status = ?", player, 0).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindAsksByPlayer(player, false, 0)
 }
 
 // 查询已处理的申请列表
 func QueryAskListDeal(player Player, limit int32) (d []*AskData, e error) {
-	if err := mysql.Where("player = ? and status <> ?", player, 0).Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
-	}
-	return d, nil
+	return store.Friends().FindAsksByPlayer(player, true, limit)
 }
 
 // 屏蔽好友
 func BanFriend(player, friend Player) error {
-	if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ?", player, friend).Updates(map[string]interface{}{
-		"ban": true,
-	}).Error; err != nil {
+	if err := store.Friends().UpdateBan(player, friend, true); err != nil {
 		return err
 	}
 
@@ -107,9 +92,7 @@ func BanFriend(player, friend Player) error {
 
 // 解除屏蔽好友
 func CancelBanFriend(player, friend Player) error {
-	if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ?", player, friend).Updates(map[string]interface{}{
-		"ban": false,
-	}).Error; err != nil {
+	if err := store.Friends().UpdateBan(player, friend, false); err != nil {
 		return err
 	}
 
@@ -122,21 +105,21 @@ func CancelBanFriend(player, friend Player) error {
 
 // 发送申请
 func WantFriend(player, friend Player) error {
-	c := 0
-	if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ?", player, friend).Count(&c).Error; err != nil {
+	friendData, err := store.Friends().FindFriend(player, friend)
+	if err != nil {
 		return err
 	}
 
-	if c > 0 {
+	if friendData != nil {
 		return nil
 	}
 
-	if err := mysql.Create(&AskData{
+	if err := store.Friends().CreateAsk(&AskData{
 		Player:    friend,
 		Sender:    player,
 		Status:    0,
 		CreatedAt: time.Now(),
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
@@ -145,67 +128,57 @@ func WantFriend(player, friend Player) error {
 
 // 回应申请
 func ReplayAskFriend(number, operate int32) error {
-	ask := AskData{
-		Id: number,
-	}
-	if err := mysql.First(&ask).Error; err != nil {
+	ask, err := store.Friends().FindAsk(number)
+	if err != nil {
 		return err
 	}
+	if ask == nil {
+		return ErrAskNotFound
+	}
 
 	if ask.Status != 0 {
 		return nil
 	}
 
-	ts := mysql.Begin()
-
-	c := 0
-	if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ?", ask.Player, ask.Sender).Count(&c).Error; err != nil {
-		return err
-	}
-	if c == 0 && operate == 1 {
-		if err := ts.Create(&FriendData{
-			Player:    ask.Player,
-			Friend:    ask.Sender,
-			CreatedAt: time.Now(),
-		}).Error; err != nil {
-			ts.Rollback()
-			return err
+	var added []uint64
+	err = store.Transaction(func(s Store) error {
+		if operate == 1 {
+			for _, pair := range [][2]Player{{ask.Player, ask.Sender}, {ask.Sender, ask.Player}} {
+				friendData, err := s.Friends().FindFriend(pair[0], pair[1])
+				if err != nil {
+					return err
+				}
+				if friendData != nil {
+					continue
+				}
+				if err := s.Friends().CreateFriend(&FriendData{
+					Player:    pair[0],
+					Friend:    pair[1],
+					CreatedAt: time.Now(),
+				}); err != nil {
+					return err
+				}
+				added = append(added, uint64(pair[0])<<32|uint64(pair[1]))
+			}
 		}
 
-		friendsLock.Lock()
-		friendsByPlayer[uint64(ask.Player)<<32|uint64(ask.Sender)] = true
-		friendsLock.Unlock()
-	}
-	if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ?", ask.Sender, ask.Player).Count(&c).Error; err != nil {
-		return err
-	}
-	if c == 0 && operate == 1 {
-		if err := ts.Create(&FriendData{
-			Player:    ask.Sender,
-			Friend:    ask.Player,
-			CreatedAt: time.Now(),
-		}).Error; err != nil {
-			ts.Rollback()
-			return err
+		if operate == 1 {
+			ask.Status = 2
+		} else {
+			ask.Status = 1
 		}
 
-		friendsLock.Lock()
-		friendsByPlayer[uint64(ask.Sender)<<32|uint64(ask.Player)] = true
-		friendsLock.Unlock()
-	}
-
-	if operate == 1 {
-		ask.Status = 2
-	} else {
-		ask.Status = 1
-	}
-
-	if err := ts.Save(&ask).Error; err != nil {
-		ts.Rollback()
+		return s.Friends().SaveAsk(ask)
+	})
+	if err != nil {
 		return err
 	}
 
-	ts.Commit()
+	friendsLock.Lock()
+	for _, key := range added {
+		friendsByPlayer[key] = true
+	}
+	friendsLock.Unlock()
 
 	return nil
 }
@@ -222,8 +195,8 @@ func QueryPlayerCanJoin(creator, player Player) bool {
 	friendsLock.RUnlock()
 
 	if !being {
-		c := 0
-		if err := mysql.Model(new(FriendData)).Where("player = ? and friend = ? and ban = ?", creator, player, false).Count(&c).Error; err != nil {
+		friendData, err := store.Friends().FindFriend(creator, player)
+		if err != nil {
 			log.WithFields(logrus.Fields{
 				"creator": creator,
 				"player":  player,
@@ -231,12 +204,11 @@ func QueryPlayerCanJoin(creator, player Player) bool {
 			}).Warnln("query friend failed")
 			return false
 		}
-		if c > 0 {
-			can = true
-		} else {
-			can = false
-		}
+		can = friendData != nil && !friendData.Ban
+
+		friendsLock.Lock()
 		friendsByPlayer[uint64(creator)<<32|uint64(player)] = can
+		friendsLock.Unlock()
 	}
 
 	return can
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"time"
+)
+
+// 迁移步骤使用的表结构, 按引入的版本冻结, 修改模型时不能修改这里, 需要新增迁移步骤
+// 字段使用基础类型, 不随模型中的类型定义变化
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 1, 引入迁移之前由 AutoMigrate 建立的表
+
+type configurationV1 struct {
+	Id     int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Type   string
+	Value1 string `gorm:"column:val1;type:text"`
+	Value2 string `gorm:"column:val2;type:text"`
+	Value3 string `gorm:"column:val3;type:text"`
+	Value4 string `gorm:"column:val4;type:text"`
+}
+
+func (configurationV1) TableName() string {
+	return "configurations"
+}
+
+type friendV1 struct {
+	Id        int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	Friend    int32
+	Ban       bool
+	CreatedAt time.Time
+}
+
+func (friendV1) TableName() string {
+	return "friend_data"
+}
+
+type askV1 struct {
+	Id        int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	Sender    int32 `gorm:"index"`
+	Status    int32
+	CreatedAt time.Time
+}
+
+func (askV1) TableName() string {
+	return "ask_data"
+}
+
+type playerV1 struct {
+	Id          int32  `gorm:"index;primary_key;AUTO_INCREMENT"`
+	UnionId     string `gorm:"index;unique"`
+	Token       string `gorm:"index"`
+	Nickname    string
+	Head        string
+	Wechat      string
+	Name        string
+	Idcard      string
+	Diamonds    int32
+	Ban         int32
+	CreatedAt   time.Time
+	VictoryRate int32
+	SharedAt    time.Time
+}
+
+func (playerV1) TableName() string {
+	return "players"
+}
+
+type orderRoomPurchaseV1 struct {
+	Id        int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	RoomId    int32
+	Number    int32
+	CreatedAt time.Time
+}
+
+func (orderRoomPurchaseV1) TableName() string {
+	return "cow_order_room_purchase_histories"
+}
+
+type payForAnotherRoomPurchaseV1 struct {
+	Id        int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	Player    int32 `gorm:"index"`
+	RoomId    int32
+	Number    int32
+	CreatedAt time.Time
+}
+
+func (payForAnotherRoomPurchaseV1) TableName() string {
+	return "cow_pay_for_another_room_purchase_histories"
+}
+
+type warHistoryV1 struct {
+	Id        int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
+	PlayerId  int32 `gorm:"index"`
+	Mode      int32
+	Payload   []byte `gorm:"type:mediumblob"`
+	CreatedAt time.Time
+}
+
+func (warHistoryV1) TableName() string {
+	return "cow_war_histories"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 3, 玩家变更通知
+
+type playerChangeV3 struct {
+	Id        int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player    int32
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (playerChangeV3) TableName() string {
+	return "player_changes"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+// 版本 5, 战绩归档
+
+type historyArchiveV5 struct {
+	Id         int64  `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Source     string `gorm:"size:64;unique_index:idx_history_archives_source"`
+	SourceId   int64  `gorm:"unique_index:idx_history_archives_source"`
+	Player     int32  `gorm:"index"`
+	CreatedAt  time.Time
+	ArchivedAt time.Time `gorm:"index"`
+	Payload    []byte    `gorm:"type:mediumblob"`
+}
+
+func (historyArchiveV5) TableName() string {
+	return "history_archives"
+}
+
+type historySummaryV5 struct {
+	Id     int64     `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	Player int32     `gorm:"unique_index:idx_history_summaries_key"`
+	Source string    `gorm:"size:64;unique_index:idx_history_summaries_key"`
+	Mode   int32     `gorm:"unique_index:idx_history_summaries_key"`
+	Day    time.Time `gorm:"unique_index:idx_history_summaries_key"`
+	Total  int32
+	Number int64
+}
+
+func (historySummaryV5) TableName() string {
+	return "history_summaries"
+}
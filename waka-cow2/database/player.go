@@ -1,13 +1,9 @@
 package database
 
 import (
-	"sync"
 	"time"
 
-	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
-
-	"github.com/liuhan907/waka/waka-cow2/conf"
 )
 
 var (
@@ -59,7 +55,7 @@ type PlayerData struct {
 	Idcard string
 
 	// 钱
-	Diamonds int32
+	Diamonds int64
 
 	// 封禁
 	Ban int32
@@ -80,15 +76,6 @@ func (PlayerData) TableName() string {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-var (
-	playersLock      sync.RWMutex
-	playersByPlayer  = make(map[Player]*PlayerData)
-	playersByUnionId = make(map[string]*PlayerData)
-	playersByToken   = make(map[string]*PlayerData)
-)
-
-// ---------------------------------------------------------------------------------------------------------------------
-
 // 注册玩家
 func RegisterPlayer(unionId, nickname string, head, token string) (*PlayerData, error) {
 	player := &PlayerData{
@@ -96,172 +83,132 @@ func RegisterPlayer(unionId, nickname string, head, token string) (*PlayerData,
 		Token:     token,
 		Nickname:  nickname,
 		Head:      head,
-		Diamonds:  conf.Option.Hall.RegisterDiamonds,
+		Diamonds:  int64(GetSettings().Hall.RegisterDiamonds),
 		Ban:       0,
 		CreatedAt: time.Now(),
 		SharedAt:  time.Date(2018, 1, 1, 0, 0, 0, 0, time.Now().Location()),
 	}
-	if err := mysql.Create(player).Error; err != nil {
+	if err := store.Players().Create(player); err != nil {
 		return nil, err
 	}
 
-	playersLock.Lock()
-
-	playersByPlayer[player.Id] = player
-	playersByUnionId[player.UnionId] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, nil
 }
 
 // 更新玩家登录信息
 func UpdatePlayerLogin(id Player, nickname string, head, token string) error {
-	if err := mysql.Model(&PlayerData{}).Where("id = ?", id).Update(&PlayerData{
+	if err := store.Players().Update(id, &PlayerData{
 		Nickname: nickname,
 		Head:     head,
 		Token:    token,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	playersLock.Lock()
-
-	player, being := playersByPlayer[id]
-	if being {
-		delete(playersByToken, player.Token)
-
+	cache.update(id, func(player *PlayerData) {
 		player.Nickname = nickname
 		player.Head = head
 		player.Token = token
-
-		playersByToken[player.Token] = player
-	}
-
-	playersLock.Unlock()
+	})
 
 	return nil
 }
 
 // 更新玩家附加信息
 func UpdatePlayerExt(id Player, wechat, name, idcard string) error {
-	if err := mysql.Model(&PlayerData{}).Where("id = ?", id).Updates(&PlayerData{
+	if err := store.Players().Update(id, &PlayerData{
 		Wechat: wechat,
 		Name:   name,
 		Idcard: idcard,
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 
-	playersLock.Lock()
-
-	player, being := playersByPlayer[id]
-	if being {
+	cache.update(id, func(player *PlayerData) {
 		player.Wechat = wechat
 		player.Name = name
 		player.Idcard = idcard
-	}
-
-	playersLock.Unlock()
+	})
 
 	return nil
 }
 
 // 根据 ID 查询玩家
 func QueryPlayerById(id Player) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersByPlayer[id]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.get(id); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{}
-	if err := mysql.Where("id = ?", id).First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	player, err := store.Players().Find(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
-
-	playersByPlayer[id] = player
-	playersByUnionId[player.UnionId] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, true, nil
 }
 
 // 根据 Token 查询玩家
 func QueryPlayerByToken(token string) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersByToken[token]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.getByKey(tokenKey(token)); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{}
-	if err := mysql.Where("token = ?", token).First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	player, err := store.Players().FindByToken(token)
+	if err != nil {
+		return nil, false, err
+	}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
-
-	playersByPlayer[player.Id] = player
-	playersByUnionId[player.UnionId] = player
-	playersByToken[player.Token] = player
-
-	playersLock.Unlock()
+	cache.put(player)
 
 	return player, true, nil
 }
 
 // 根据微信UID 查询玩家
 func QueryPlayerByWechatUid(uid string) (*PlayerData, bool, error) {
-	playersLock.RLock()
-
-	player, being := playersByUnionId[uid]
-	if being {
-		playersLock.RUnlock()
+	if player, being := cache.getByKey(unionIdKey(uid)); being {
 		return player, true, nil
 	}
 
-	playersLock.RUnlock()
-
-	player = &PlayerData{}
-	if err := mysql.Where("union_id = ?", uid).First(player).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, false, nil
-		} else {
-			return nil, false, err
-		}
+	player, err := store.Players().FindByUnionId(uid)
+	if err != nil {
+		return nil, false, err
+	}
+	if player == nil {
+		return nil, false, nil
 	}
 
-	playersLock.Lock()
+	cache.put(player)
 
-	playersByPlayer[player.Id] = player
-	playersByUnionId[player.UnionId] = player
-	playersByToken[player.Token] = player
+	return player, true, nil
+}
 
-	playersLock.Unlock()
+// 批量查询玩家, 未缓存的玩家一次查询载入, 不存在的玩家不返回
+func QueryPlayers(ids []Player) (map[Player]*PlayerData, error) {
+	r, missing := cache.getMany(ids)
+	if len(missing) == 0 {
+		return r, nil
+	}
 
-	return player, true, nil
+	players, err := store.Players().FindIn(missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, player := range players {
+		cache.put(player)
+		r[player.Id] = player
+	}
+
+	return r, nil
 }
 
 // 分享送钻
@@ -274,6 +221,8 @@ func PlayerShared(id Player) (int32, error) {
 		return 0, ErrPlayerNotFound
 	}
 
+	number := GetSettings().Hall.ShareDiamonds
+
 	year, month, day := playerData.SharedAt.Date()
 	yearNow, monthNow, dayNow := time.Now().Date()
 	if yearNow <= year {
@@ -289,11 +238,11 @@ func PlayerShared(id Player) (int32, error) {
 
 	modifies = append(modifies, &modifyDiamondsAction{
 		Player: id,
-		Number: conf.Option.Hall.ShareDiamonds,
-		After: func(ts *gorm.DB, modify *modifyDiamondsAction) error {
-			if err := ts.Model(new(PlayerData)).Where("id = ?", id).Updates(&PlayerData{
+		Number: int64(number),
+		After: func(s Store, modify *modifyDiamondsAction) error {
+			if err := s.Players().Update(id, &PlayerData{
 				SharedAt: time.Now(),
-			}).Error; err != nil {
+			}); err != nil {
 				return err
 			}
 			return nil
@@ -301,40 +250,16 @@ func PlayerShared(id Player) (int32, error) {
 	})
 	changed = append(changed, id)
 
-	ts := mysql.Begin()
-
-	err = applyModifyDiamondsAction(ts, modifies)
+	err = store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return 0, err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionId, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
-	}
-	playersLock.Unlock()
-
-	return conf.Option.Hall.ShareDiamonds, nil
-}
-
-//刷新缓存
-func RefreshPlayer(player Player) {
-	playersLock.Lock()
-
-	playerData, being := playersByPlayer[player]
-	if being {
-		delete(playersByPlayer, player)
-		delete(playersByUnionId, playerData.UnionId)
-		delete(playersByToken, playerData.Token)
+		cache.remove(player)
 	}
 
-	playersLock.Unlock()
+	return number, nil
 }
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 默认缓存有效期
+	defaultCacheTTL = time.Minute * 10
+	// 默认最多缓存的玩家数
+	defaultCacheSize = 100000
+	// 默认的变更通知轮询间隔
+	defaultChangePoll = time.Second
+
+	// 缓存分片数, 查询只锁住所在的分片
+	cacheShards = 64
+
+	// 每次读取的变更通知数
+	changeBatch = 1000
+	// 每次轮询重新读取已读取的最后 changeWindow 个主键
+	// 主键在插入时分配, 事务提交的顺序可能与主键不一致, 较小的主键可能在较大的主键已读取之后才提交
+	// 提交得更晚的通知只能由缓存的有效期兜底
+	changeWindow = 1000
+	// 变更通知保留时间, 超过后由任一进程删除
+	changeRetention = time.Hour
+	// 删除过期变更通知的间隔
+	changeCleanup = time.Minute * 10
+)
+
+var (
+	cache = newPlayerCache(defaultCacheTTL, defaultCacheSize)
+
+	// 关闭时停止轮询变更通知
+	watchStop chan struct{}
+)
+
+// 玩家变更通知, 由 players 表上的触发器在更新与删除时写入
+// 直接修改数据库的后台也会触发, 各进程轮询后删除对应的缓存
+type PlayerChangeData struct {
+	// 主键
+	Id int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 变更的玩家
+	Player Player
+	// 变更时间, 使用数据库时间
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (PlayerChangeData) TableName() string {
+	return "player_changes"
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 缓存项, 写入后不再修改, 更新时整体替换
+type cacheEntry struct {
+	player *PlayerData
+	loaded time.Time
+}
+
+type cacheShard struct {
+	sync.RWMutex
+	players map[Player]*cacheEntry
+}
+
+// Token 与 UnionId 到主键的索引
+type keyShard struct {
+	sync.RWMutex
+	keys map[string]Player
+}
+
+// 分片的玩家缓存, 读取时返回副本, 调用者可以随意修改
+// 同时加锁时总是先锁玩家分片, 再锁索引分片
+type playerCache struct {
+	shards [cacheShards]cacheShard
+	keys   [cacheShards]keyShard
+	ttl    time.Duration
+	// 每个分片最多缓存的玩家数
+	size int
+}
+
+func newPlayerCache(ttl time.Duration, size int) *playerCache {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	c := &playerCache{
+		ttl:  ttl,
+		size: (size + cacheShards - 1) / cacheShards,
+	}
+	for i := range c.shards {
+		c.shards[i].players = make(map[Player]*cacheEntry)
+		c.keys[i].keys = make(map[string]Player)
+	}
+	return c
+}
+
+// 清空缓存
+func (c *playerCache) reset() {
+	for i := range c.shards {
+		c.shards[i].Lock()
+		c.shards[i].players = make(map[Player]*cacheEntry)
+		c.shards[i].Unlock()
+	}
+	for i := range c.keys {
+		c.keys[i].Lock()
+		c.keys[i].keys = make(map[string]Player)
+		c.keys[i].Unlock()
+	}
+}
+
+func (c *playerCache) shard(id Player) *cacheShard {
+	return &c.shards[uint32(id)%cacheShards]
+}
+
+func (c *playerCache) keyShard(key string) *keyShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &c.keys[h.Sum32()%cacheShards]
+}
+
+func tokenKey(token string) string {
+	return "t:" + token
+}
+
+func unionIdKey(unionId string) string {
+	return "u:" + unionId
+}
+
+func cacheKeys(player *PlayerData) [2]string {
+	return [2]string{tokenKey(player.Token), unionIdKey(player.UnionId)}
+}
+
+// 根据主键查询, 过期时视为不存在
+func (c *playerCache) get(id Player) (*PlayerData, bool) {
+	shard := c.shard(id)
+	shard.RLock()
+	entry, being := shard.players[id]
+	shard.RUnlock()
+
+	if !being || time.Since(entry.loaded) >= c.ttl {
+		return nil, false
+	}
+	player := *entry.player
+	return &player, true
+}
+
+// 根据索引键查询, 索引可能落后于玩家分片, 取到后校验
+func (c *playerCache) getByKey(key string) (*PlayerData, bool) {
+	shard := c.keyShard(key)
+	shard.RLock()
+	id, being := shard.keys[key]
+	shard.RUnlock()
+	if !being {
+		return nil, false
+	}
+
+	player, being := c.get(id)
+	if !being {
+		return nil, false
+	}
+	for _, k := range cacheKeys(player) {
+		if k == key {
+			return player, true
+		}
+	}
+	return nil, false
+}
+
+// 批量查询, 返回命中的玩家与未命中的主键
+func (c *playerCache) getMany(ids []Player) (map[Player]*PlayerData, []Player) {
+	r := make(map[Player]*PlayerData, len(ids))
+	var missing []Player
+	for _, id := range ids {
+		if player, being := c.get(id); being {
+			r[id] = player
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return r, missing
+}
+
+// 写入副本, 分片已满时随机淘汰
+func (c *playerCache) put(player *PlayerData) {
+	copied := *player
+	entry := &cacheEntry{player: &copied, loaded: time.Now()}
+
+	shard := c.shard(player.Id)
+	shard.Lock()
+	defer shard.Unlock()
+
+	if old, being := shard.players[player.Id]; being {
+		c.unindex(old.player)
+		delete(shard.players, player.Id)
+	}
+	for id, old := range shard.players {
+		if len(shard.players) < c.size {
+			break
+		}
+		c.unindex(old.player)
+		delete(shard.players, id)
+	}
+
+	shard.players[player.Id] = entry
+	c.index(entry.player)
+}
+
+// 在副本上修改并替换, 不在缓存中时什么也不做
+func (c *playerCache) update(id Player, fn func(player *PlayerData)) {
+	shard := c.shard(id)
+	shard.Lock()
+	defer shard.Unlock()
+
+	old, being := shard.players[id]
+	if !being {
+		return
+	}
+	copied := *old.player
+	fn(&copied)
+
+	c.unindex(old.player)
+	shard.players[id] = &cacheEntry{player: &copied, loaded: old.loaded}
+	c.index(&copied)
+}
+
+func (c *playerCache) remove(id Player) {
+	shard := c.shard(id)
+	shard.Lock()
+	if old, being := shard.players[id]; being {
+		c.unindex(old.player)
+		delete(shard.players, id)
+	}
+	shard.Unlock()
+}
+
+// 写入索引, 调用时需持有玩家分片的锁
+func (c *playerCache) index(player *PlayerData) {
+	for _, key := range cacheKeys(player) {
+		shard := c.keyShard(key)
+		shard.Lock()
+		shard.keys[key] = player.Id
+		shard.Unlock()
+	}
+}
+
+// 删除仍指向该玩家的索引, 调用时需持有玩家分片的锁
+func (c *playerCache) unindex(player *PlayerData) {
+	for _, key := range cacheKeys(player) {
+		shard := c.keyShard(key)
+		shard.Lock()
+		if shard.keys[key] == player.Id {
+			delete(shard.keys, key)
+		}
+		shard.Unlock()
+	}
+}
+
+// 刷新缓存
+func RefreshPlayer(player Player) {
+	cache.remove(player)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 从最新的变更通知之后开始轮询
+func startWatchChanges(s Store, interval time.Duration) error {
+	last, err := s.PlayerChanges().Last()
+	if err != nil {
+		return err
+	}
+	var after int64
+	if last != nil {
+		after = last.Id
+	}
+
+	if interval <= 0 {
+		interval = defaultChangePoll
+	}
+	watchStop = make(chan struct{})
+	go watchChanges(s, newChangeCursor(after), interval, watchStop)
+	return nil
+}
+
+func stopWatchChanges() {
+	if watchStop != nil {
+		close(watchStop)
+		watchStop = nil
+	}
+}
+
+// 轮询变更通知并删除对应的玩家缓存, 同时定期删除过期的通知
+func watchChanges(s Store, cursor *changeCursor, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cleaned := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := pollChanges(s, cursor); err != nil {
+			log.WithFields(logrus.Fields{
+				"after": cursor.After,
+				"err":   err,
+			}).Warnln("poll player changes failed")
+			continue
+		}
+
+		if time.Since(cleaned) >= changeCleanup {
+			cleaned = time.Now()
+			if err := cleanChanges(s); err != nil {
+				log.WithFields(logrus.Fields{
+					"err": err,
+				}).Warnln("clean player changes failed")
+			}
+		}
+	}
+}
+
+// 变更通知的轮询位置
+type changeCursor struct {
+	// 已读取的最大主键
+	After int64
+	// 窗口内已处理的通知, 重新读取时跳过
+	Seen map[int64]bool
+}
+
+func newChangeCursor(after int64) *changeCursor {
+	return &changeCursor{
+		After: after,
+		Seen:  make(map[int64]bool),
+	}
+}
+
+// 读取窗口内与之后的所有变更通知, 删除未处理过的通知对应的缓存
+func pollChanges(s Store, cursor *changeCursor) error {
+	from := cursor.After - changeWindow
+	for {
+		changes, err := s.PlayerChanges().FindAfter(from, changeBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if !cursor.Seen[change.Id] {
+				cache.remove(change.Player)
+				cursor.Seen[change.Id] = true
+			}
+			if change.Id > cursor.After {
+				cursor.After = change.Id
+			}
+		}
+
+		if len(changes) < changeBatch {
+			break
+		}
+		from = changes[len(changes)-1].Id
+	}
+
+	for id := range cursor.Seen {
+		if id <= cursor.After-changeWindow {
+			delete(cursor.Seen, id)
+		}
+	}
+	return nil
+}
+
+// 以最新通知的时间为准删除过期的通知, 避免进程与数据库的时钟不一致
+func cleanChanges(s Store) error {
+	last, err := s.PlayerChanges().Last()
+	if err != nil || last == nil {
+		return err
+	}
+	return s.PlayerChanges().DeleteBefore(last.CreatedAt.Add(-changeRetention))
+}
@@ -2,8 +2,6 @@ package database
 
 import (
 	"time"
-
-	"github.com/jinzhu/gorm"
 )
 
 // 牛牛约战房间消费记录
@@ -15,7 +13,7 @@ type CowOrderRoomPurchaseHistory struct {
 	// 房间 ID
 	RoomId int32
 	// 金币数
-	Number int32
+	Number int64
 	// 时间
 	CreatedAt time.Time
 }
@@ -31,7 +29,7 @@ type CowPayForAnotherRoomPurchaseHistory struct {
 	// 房间 ID
 	RoomId int32
 	// 金币数
-	Number int32
+	Number int64
 	// 时间
 	CreatedAt time.Time
 }
@@ -41,7 +39,7 @@ type CowPayForAnotherRoomPurchaseHistory struct {
 // 场费结算数据
 type CowPlayerRoomCost struct {
 	Player Player
-	Number int32
+	Number int64
 }
 
 // 牛牛约战房间场费结算
@@ -55,14 +53,14 @@ func CowOrderSettle(room int32, players []*CowPlayerRoomCost) error {
 		modifies = append(modifies, &modifyDiamondsAction{
 			Player: player.Player,
 			Number: player.Number * (-1),
-			After: func(ts *gorm.DB, self *modifyDiamondsAction) error {
+			After: func(s Store, self *modifyDiamondsAction) error {
 				consume := &CowOrderRoomPurchaseHistory{
 					Player:    player.Player,
 					RoomId:    room,
 					Number:    player.Number,
 					CreatedAt: time.Now(),
 				}
-				if err := ts.Create(consume).Error; err != nil {
+				if err := s.Purchases().CreateOrderRoom(consume); err != nil {
 					return err
 				}
 				return nil
@@ -71,26 +69,16 @@ func CowOrderSettle(room int32, players []*CowPlayerRoomCost) error {
 		changed = append(changed, player.Player)
 	}
 
-	ts := mysql.Begin()
-
-	err := applyModifyDiamondsAction(ts, modifies)
+	err := store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionId, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
+		cache.remove(player)
 	}
-	playersLock.Unlock()
 
 	return nil
 }
@@ -103,14 +91,14 @@ func CowPayForAnotherSettle(room int32, player *CowPlayerRoomCost) error {
 	modifies = append(modifies, &modifyDiamondsAction{
 		Player: player.Player,
 		Number: player.Number * (-1),
-		After: func(ts *gorm.DB, self *modifyDiamondsAction) error {
+		After: func(s Store, self *modifyDiamondsAction) error {
 			consume := &CowPayForAnotherRoomPurchaseHistory{
 				Player:    player.Player,
 				RoomId:    room,
 				Number:    player.Number,
 				CreatedAt: time.Now(),
 			}
-			if err := ts.Create(consume).Error; err != nil {
+			if err := s.Purchases().CreatePayForAnotherRoom(consume); err != nil {
 				return err
 			}
 			return nil
@@ -118,26 +106,16 @@ func CowPayForAnotherSettle(room int32, player *CowPlayerRoomCost) error {
 	})
 	changed = append(changed, player.Player)
 
-	ts := mysql.Begin()
-
-	err := applyModifyDiamondsAction(ts, modifies)
+	err := store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionId, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
+		cache.remove(player)
 	}
-	playersLock.Unlock()
 
 	return nil
 }
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"time"
+)
+
+// 玩家仓库
+type PlayerRepository interface {
+	// 创建玩家, 创建后回填主键
+	Create(player *PlayerData) error
+	// 查询玩家, 不存在时返回 nil
+	Find(id Player) (*PlayerData, error)
+	// 根据 Token 查询玩家, 不存在时返回 nil
+	FindByToken(token string) (*PlayerData, error)
+	// 根据微信 UnionId 查询玩家, 不存在时返回 nil
+	FindByUnionId(unionId string) (*PlayerData, error)
+	// 更新玩家, 只更新 fields 中的非零值字段
+	Update(id Player, fields *PlayerData) error
+	// 增减玩家的钻石
+	AddDiamonds(id Player, number int64) error
+	// 批量查询玩家, 不存在的玩家不返回
+	FindIn(ids []Player) ([]*PlayerData, error)
+}
+
+// 玩家变更通知仓库
+type PlayerChangeRepository interface {
+	// 查询最新的变更通知, 没有时返回 nil
+	Last() (*PlayerChangeData, error)
+	// 按主键顺序查询主键大于 after 的变更通知
+	FindAfter(after int64, limit int32) ([]*PlayerChangeData, error)
+	// 删除早于 before 的变更通知
+	DeleteBefore(before time.Time) error
+}
+
+// 房卡消费记录仓库
+type PurchaseRepository interface {
+	// 添加约战房间消费记录
+	CreateOrderRoom(history *CowOrderRoomPurchaseHistory) error
+	// 添加代开房间消费记录
+	CreatePayForAnotherRoom(history *CowPayForAnotherRoomPurchaseHistory) error
+}
+
+// 战绩仓库
+type HistoryRepository interface {
+	// 添加牛牛战绩
+	CreateWar(history *CowWarHistory) error
+	// 按游标从新到旧查询玩家的牛牛战绩
+	FindWar(player Player, cursor *HistoryCursor, limit int32) ([]*CowWarHistory, error)
+}
+
+// 战绩归档仓库
+type ArchiveRepository interface {
+	// 按时间顺序读取 created_at 早于 before 的记录, out 为来源表模型的切片指针
+	FindExpired(out interface{}, before time.Time, limit int32) error
+	// 删除来源表中已归档的记录, model 为来源表模型
+	DeleteArchived(model interface{}, ids []int64) error
+	// 添加归档
+	Create(archive *HistoryArchiveData) error
+	// 根据来源表与原记录的主键查询归档, 不存在时返回 nil
+	Find(source string, id int64) (*HistoryArchiveData, error)
+	// 删除归档时间早于 before 的归档, 返回删除的数量
+	DeleteBefore(before time.Time) (int64, error)
+	// 累加汇总, 不存在时创建
+	AddSummary(summary *HistorySummaryData) error
+	// 查询玩家在 [from, to) 内的汇总
+	FindSummaries(player Player, from, to time.Time) ([]*HistorySummaryData, error)
+}
+
+// 系统配置仓库
+type ConfigurationRepository interface {
+	// 查询指定类型的配置
+	Find(configurationType string) ([]*Configuration, error)
+}
+
+// 好友仓库
+type FriendRepository interface {
+	// 查询玩家的好友, ban 为 true 时查询被屏蔽的好友
+	FindFriends(player Player, ban bool) ([]*FriendData, error)
+	// 查询好友关系, 不存在时返回 nil
+	FindFriend(player, friend Player) (*FriendData, error)
+	// 添加好友关系
+	CreateFriend(friend *FriendData) error
+	// 屏蔽或取消屏蔽好友
+	UpdateBan(player, friend Player, ban bool) error
+
+	// 添加好友申请
+	CreateAsk(ask *AskData) error
+	// 查询好友申请, 不存在时返回 nil
+	FindAsk(id int32) (*AskData, error)
+	// 保存好友申请
+	SaveAsk(ask *AskData) error
+	// 查询玩家发出的好友申请, dealt 为 true 时查询已处理的, limit 为 0 时不限制数量
+	FindAsksBySender(sender Player, dealt bool, limit int32) ([]*AskData, error)
+	// 查询玩家收到的好友申请, dealt 为 true 时查询已处理的, limit 为 0 时不限制数量
+	FindAsksByPlayer(player Player, dealt bool, limit int32) ([]*AskData, error)
+}
+
+// 存储, 汇总所有仓库
+type Store interface {
+	Players() PlayerRepository
+	PlayerChanges() PlayerChangeRepository
+	Purchases() PurchaseRepository
+	Histories() HistoryRepository
+	Archives() ArchiveRepository
+	Configurations() ConfigurationRepository
+	Friends() FriendRepository
+
+	// 在事务中执行 fn, fn 返回错误时回滚, 事务中再次调用时直接在当前事务中执行
+	Transaction(fn func(store Store) error) error
+	// 关闭存储
+	Close() error
+}
@@ -233,9 +233,10 @@ func (r *aaRoomT) NiuniuGrabAnimation() *cow_proto.NiuniuGrabAnimation {
 
 func (r *aaRoomT) NiuniuRoundClear() *cow_proto.NiuniuRoundClear {
 	var players []*cow_proto.NiuniuRoundClear_PlayerData
+	data := queryPlayers(r.GetPlayers())
 	for _, player := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundClear_PlayerData{
-			Player:     r.Hall.ToPlayer(player.Player),
+			Player:     r.Hall.toPlayer(player.Player, data[player.Player]),
 			Points:     player.Round.Points,
 			Pokers:     player.Round.BestPokers,
 			Type:       player.Round.BestPattern,
@@ -248,9 +249,10 @@ func (r *aaRoomT) NiuniuRoundClear() *cow_proto.NiuniuRoundClear {
 
 func (r *aaRoomT) NiuniuRoundFinally() *cow_proto.NiuniuRoundFinally {
 	var players []*cow_proto.NiuniuRoundFinally_PlayerData
+	data := queryPlayers(r.GetPlayers())
 	for _, player := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundFinally_PlayerData{
-			Player:    r.Hall.ToPlayer(player.Player),
+			Player:    r.Hall.toPlayer(player.Player, data[player.Player]),
 			Points:    int32(player.Round.Points),
 			Victories: player.Round.VictoriousNumber,
 		})
@@ -356,7 +358,7 @@ func (r *aaRoomT) CreateRoom(hall *actorT, id int32, option *cow_proto.NiuniuRoo
 		r.King = append(r.King, creator)
 	}
 
-	if creator.PlayerData().Diamonds < r.CreateDiamonds() {
+	if creator.PlayerData().Diamonds < int64(r.CreateDiamonds()) {
 		r.Hall.sendNiuniuCreateRoomFailed(creator, 1)
 		return nil
 	} else {
@@ -383,7 +385,7 @@ func (r *aaRoomT) JoinRoom(player *playerT) {
 		return
 	}
 
-	if player.Player.PlayerData().Diamonds < r.EnterDiamonds() {
+	if player.Player.PlayerData().Diamonds < int64(r.EnterDiamonds()) {
 		r.Hall.sendNiuniuJoinRoomFailed(player.Player, 1)
 		return
 	}
@@ -523,7 +525,7 @@ func (r *aaRoomT) Start(player *playerT) {
 			for _, player := range r.Players {
 				playerRoomCost = append(playerRoomCost, &database.CowPlayerRoomCost{
 					Player: player.Player,
-					Number: r.CostDiamonds(),
+					Number: int64(r.CostDiamonds()),
 				})
 			}
 			err := database.CowOrderSettle(r.Id, playerRoomCost)
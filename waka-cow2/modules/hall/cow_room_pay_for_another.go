@@ -227,9 +227,10 @@ func (r *payForAnotherRoomT) NiuniuGrabAnimation() *cow_proto.NiuniuGrabAnimatio
 
 func (r *payForAnotherRoomT) NiuniuRoundClear() *cow_proto.NiuniuRoundClear {
 	var players []*cow_proto.NiuniuRoundClear_PlayerData
+	data := queryPlayers(r.GetPlayers())
 	for _, player := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundClear_PlayerData{
-			Player:     r.Hall.ToPlayer(player.Player),
+			Player:     r.Hall.toPlayer(player.Player, data[player.Player]),
 			Points:     player.Round.Points,
 			Pokers:     player.Round.BestPokers,
 			Type:       player.Round.BestPattern,
@@ -242,9 +243,10 @@ func (r *payForAnotherRoomT) NiuniuRoundClear() *cow_proto.NiuniuRoundClear {
 
 func (r *payForAnotherRoomT) NiuniuRoundFinally() *cow_proto.NiuniuRoundFinally {
 	var players []*cow_proto.NiuniuRoundFinally_PlayerData
+	data := queryPlayers(r.GetPlayers())
 	for _, player := range r.Players {
 		players = append(players, &cow_proto.NiuniuRoundFinally_PlayerData{
-			Player:    r.Hall.ToPlayer(player.Player),
+			Player:    r.Hall.toPlayer(player.Player, data[player.Player]),
 			Points:    int32(player.Round.Points),
 			Victories: player.Round.VictoriousNumber,
 		})
@@ -347,7 +349,7 @@ func (r *payForAnotherRoomT) CreateRoom(hall *actorT, id int32, option *cow_prot
 		Bans:    make(map[database.Player]bool),
 	}
 
-	if creator.PlayerData().Diamonds < r.CreateDiamonds() {
+	if creator.PlayerData().Diamonds < int64(r.CreateDiamonds()) {
 		r.Hall.sendNiuniuCreateRoomFailed(creator, 1)
 		return nil
 	} else {
@@ -499,7 +501,7 @@ func (r *payForAnotherRoomT) Start(player *playerT) {
 
 			err := database.CowPayForAnotherSettle(r.Id, &database.CowPlayerRoomCost{
 				Player: r.Creator,
-				Number: r.CostDiamonds(),
+				Number: int64(r.CostDiamonds()),
 			})
 			if err != nil {
 				log.WithFields(logrus.Fields{
@@ -3,47 +3,16 @@ package hall
 import (
 	"sort"
 
-	"github.com/golang/protobuf/proto"
 	"gopkg.in/ahmetb/go-linq.v3"
 
 	"github.com/liuhan907/waka/waka-cow2/database"
 	"github.com/liuhan907/waka/waka-cow2/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
-func (my *actorT) playerFutureRequestedCow(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.NiuniuGetPayForAnotherRoomListRequest:
-		my.NiuniuGetPayForAnotherRoomListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuGetWarHistoryRequest:
-		my.NiuniuGetWarHistoryRequest(player, evd, ev.Respond)
-
-	case *cow_proto.NiuniuPullFriendsListRequest:
-		my.NiuniuPullFriendsListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuPullWantListRequest:
-		my.NiuniuPullWantListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuPullAskListRequest:
-		my.NiuniuPullAskListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuPullBanListRequest:
-		my.NiuniuPullBanListRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuBanFriendRequest:
-		my.NiuniuBanFriendRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuWantFriendRequest:
-		my.NiuniuWantFriendRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuBecomeFriendRequest:
-		my.NiuniuBecomeFriendRequest(player, evd, ev.Respond)
-	case *cow_proto.NiuniuCancelBanFriendRequest:
-		my.NiuniuCancelBanFriendRequest(player, evd, ev.Respond)
-
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) NiuniuGetPayForAnotherRoomListRequest(player *playerT,
+func (my *actorT) NiuniuGetPayForAnotherRoomListRequest(playerId uint64,
 	ev *cow_proto.NiuniuGetPayForAnotherRoomListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuGetPayForAnotherRoomListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	rooms := my.cowRooms.
 		WherePayForAnother().
@@ -51,22 +20,24 @@ func (my *actorT) NiuniuGetPayForAnotherRoomListRequest(player *playerT,
 	respond(&cow_proto.NiuniuGetPayForAnotherRoomListResponse{rooms.NiuniuRoomData1()}, nil)
 }
 
-func (my *actorT) NiuniuGetWarHistoryRequest(player *playerT,
+func (my *actorT) NiuniuGetWarHistoryRequest(playerId uint64,
 	ev *cow_proto.NiuniuGetWarHistoryRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuGetWarHistoryResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	records, err := database.CowQueryWarHistory(player.Player, 20)
+	records, next, err := database.CowQueryWarHistory(player.Player, database.HistoryCursorFromProto(ev.Cursor), ev.Limit)
 	if err != nil {
 		respond(nil, err)
 		return
 	}
 
-	respond(&cow_proto.NiuniuGetWarHistoryResponse{records}, nil)
+	respond(&cow_proto.NiuniuGetWarHistoryResponse{records, next.Proto()}, nil)
 }
 
-func (my *actorT) NiuniuPullFriendsListRequest(player *playerT,
+func (my *actorT) NiuniuPullFriendsListRequest(playerId uint64,
 	ev *cow_proto.NiuniuPullFriendsListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuPullFriendsListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	friends, err := database.QueryFriendList(player.Player)
 	if err != nil {
@@ -92,9 +63,10 @@ func (my *actorT) NiuniuPullFriendsListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) NiuniuPullWantListRequest(player *playerT,
+func (my *actorT) NiuniuPullWantListRequest(playerId uint64,
 	ev *cow_proto.NiuniuPullWantListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuPullWantListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	wants, err := database.QueryWantListSend(player.Player)
 	if err != nil {
@@ -134,9 +106,10 @@ func (my *actorT) NiuniuPullWantListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) NiuniuPullAskListRequest(player *playerT,
+func (my *actorT) NiuniuPullAskListRequest(playerId uint64,
 	ev *cow_proto.NiuniuPullAskListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuPullAskListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	asks, err := database.QueryAskListUndeal(player.Player)
 	if err != nil {
@@ -177,9 +150,10 @@ func (my *actorT) NiuniuPullAskListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) NiuniuPullBanListRequest(player *playerT,
+func (my *actorT) NiuniuPullBanListRequest(playerId uint64,
 	ev *cow_proto.NiuniuPullBanListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuPullBanListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	friends, err := database.QueryBanFriendList(player.Player)
 	if err != nil {
@@ -205,9 +179,10 @@ func (my *actorT) NiuniuPullBanListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) NiuniuBanFriendRequest(player *playerT,
+func (my *actorT) NiuniuBanFriendRequest(playerId uint64,
 	ev *cow_proto.NiuniuBanFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuBanFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	err := database.BanFriend(player.Player, database.Player(ev.GetPlayerId()))
 	if err != nil {
@@ -217,9 +192,10 @@ func (my *actorT) NiuniuBanFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) NiuniuCancelBanFriendRequest(player *playerT,
+func (my *actorT) NiuniuCancelBanFriendRequest(playerId uint64,
 	ev *cow_proto.NiuniuCancelBanFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuCancelBanFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	err := database.CancelBanFriend(player.Player, database.Player(ev.GetPlayerId()))
 	if err != nil {
@@ -229,9 +205,10 @@ func (my *actorT) NiuniuCancelBanFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) NiuniuWantFriendRequest(player *playerT,
+func (my *actorT) NiuniuWantFriendRequest(playerId uint64,
 	ev *cow_proto.NiuniuWantFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*cow_proto.NiuniuWantFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	if err := database.WantFriend(player.Player, database.Player(ev.GetPlayerId())); err != nil {
 		respond(nil, err)
@@ -240,10 +217,9 @@ func (my *actorT) NiuniuWantFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) NiuniuBecomeFriendRequest(player *playerT,
+func (my *actorT) NiuniuBecomeFriendRequest(playerId uint64,
 	ev *cow_proto.NiuniuBecomeFriendRequest,
-	respond func(proto.Message, error)) {
-
+	respond func(*cow_proto.NiuniuBecomeFriendResponse, error)) {
 	if err := database.ReplayAskFriend(ev.GetNumber(), ev.GetOperate()); err != nil {
 		respond(nil, err)
 	} else {
@@ -1,6 +1,10 @@
 package hall
 
 import (
+	"math"
+
+	"github.com/sirupsen/logrus"
+
 	"github.com/liuhan907/waka/waka-cow2/database"
 	"github.com/liuhan907/waka/waka-cow2/proto"
 )
@@ -35,8 +39,10 @@ func (my playerMap) ToSlice() (d []int32) {
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (my *actorT) ToPlayer(player database.Player) (pb *cow_proto.Player) {
-	playerData := player.PlayerData()
+	return my.toPlayer(player, player.PlayerData())
+}
 
+func (my *actorT) toPlayer(player database.Player, playerData *database.PlayerData) (pb *cow_proto.Player) {
 	pb = &cow_proto.Player{
 		Id:       int32(playerData.Id),
 		Nickname: playerData.Nickname,
@@ -56,15 +62,16 @@ func (my *actorT) ToPlayerSecret(player database.Player) (pb *cow_proto.PlayerSe
 	playerData := player.PlayerData()
 
 	pb = &cow_proto.PlayerSecret{
-		Id:        int32(playerData.Id),
-		WechatUid: playerData.UnionId,
-		Nickname:  playerData.Nickname,
-		Head:      playerData.Head,
-		Wechat:    playerData.Wechat,
-		Idcard:    playerData.Idcard,
-		Name:      playerData.Name,
-		CreatedAt: playerData.CreatedAt.Format("2006-01-02 15:04:05"),
-		Diamonds:  playerData.Diamonds,
+		Id:         int32(playerData.Id),
+		WechatUid:  playerData.UnionId,
+		Nickname:   playerData.Nickname,
+		Head:       playerData.Head,
+		Wechat:     playerData.Wechat,
+		Idcard:     playerData.Idcard,
+		Name:       playerData.Name,
+		CreatedAt:  playerData.CreatedAt.Format("2006-01-02 15:04:05"),
+		Diamonds:   clampInt32(playerData.Diamonds),
+		Diamonds64: playerData.Diamonds,
 	}
 
 	localPlayer, being := my.players[player]
@@ -81,3 +88,32 @@ func (my *actorT) ToPlayerMap(players map[database.Player]database.Player) (pb [
 	}
 	return pb
 }
+
+// 一次查询载入多个玩家的数据, 查询失败或不存在的玩家与 PlayerData 一样使用默认值
+func queryPlayers(players []database.Player) map[database.Player]*database.PlayerData {
+	r, err := database.QueryPlayers(players)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"players": players,
+			"err":     err,
+		}).Warnln("query players failed")
+		r = make(map[database.Player]*database.PlayerData, len(players))
+	}
+	for _, player := range players {
+		if _, being := r[player]; !being {
+			r[player] = player.PlayerData()
+		}
+	}
+	return r
+}
+
+// 转换为 int32, 超出范围时取边界值, 用于填充旧协议中的 32 位字段
+func clampInt32(number int64) int32 {
+	if number > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if number < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(number)
+}
@@ -1,6 +1,11 @@
 package hall
 
-import "github.com/AsynkronIT/protoactor-go/actor"
+import (
+	"github.com/AsynkronIT/protoactor-go/actor"
+
+	"github.com/liuhan907/waka/waka-cow2/database"
+	"github.com/liuhan907/waka/waka-cow2/modules/hall/hall_message"
+)
 
 func (my *actorT) ReceiveActor(context actor.Context) bool {
 	switch context.Message().(type) {
@@ -17,4 +22,8 @@ func (my *actorT) ReceiveActor(context actor.Context) bool {
 func (my *actorT) started(context actor.Context) {
 	my.pid = context.Self()
 	my.startClock()
+
+	database.WatchSettings(func(settings *database.Settings) {
+		my.pid.Tell(&hall_message.SettingsChanged{settings})
+	})
 }
@@ -6,7 +6,6 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
 
-	"github.com/liuhan907/waka/waka-cow2/conf"
 	"github.com/liuhan907/waka/waka-cow2/database"
 	"github.com/liuhan907/waka/waka-cow2/proto"
 	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
@@ -44,7 +43,7 @@ func (my *actorT) sendHallEntered(player database.Player) {
 
 func (my *actorT) sendPlayerNumber(player database.Player, number int32) {
 	my.send(player, &cow_proto.PlayerNumber{
-		Number: number + conf.Option.Hall.MinPlayerNumber,
+		Number: number + database.GetSettings().Hall.MinPlayerNumber,
 	})
 }
 
@@ -5,42 +5,11 @@ import (
 
 	"github.com/liuhan907/waka/waka-cow2/database"
 	"github.com/liuhan907/waka/waka-cow2/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
-func (my *actorT) playerTransportedCow(player *playerT, ev *supervisor_message.PlayerTransported) bool {
-	switch evd := ev.Payload.(type) {
-	case *cow_proto.NiuniuCreateRoom:
-		my.NiuniuCreateRoom(player, evd)
-	case *cow_proto.NiuniuJoinRoom:
-		my.NiuniuJoinRoom(player, evd)
-	case *cow_proto.NiuniuLeaveRoom:
-		my.NiuniuLeaveRoom(player, evd)
-	case *cow_proto.NiuniuSwitchReady:
-		my.NiuniuSwitchReady(player, evd)
-	case *cow_proto.NiuniuDismiss:
-		my.NiuniuDismiss(player, evd)
-	case *cow_proto.NiuniuKickPlayer:
-		my.NiuniuKickPlayer(player, evd)
-	case *cow_proto.NiuniuStart:
-		my.NiuniuStart(player, evd)
-	case *cow_proto.NiuniuSpecifyBanker:
-		my.NiuniuSpecifyBanker(player, evd)
-	case *cow_proto.NiuniuGrab:
-		my.NiuniuGrab(player, evd)
-	case *cow_proto.NiuniuSpecifyRate:
-		my.NiuniuSpecifyRate(player, evd)
-	case *cow_proto.NiuniuContinueWith:
-		my.NiuniuContinueWith(player, evd)
-	case *cow_proto.NiuniuPostRoomMessage:
-		my.NiuniuPostRoomMessage(player, evd)
-	default:
-		return false
-	}
-	return true
-}
+func (my *actorT) NiuniuCreateRoom(playerId uint64, ev *cow_proto.NiuniuCreateRoom) {
+	player := my.players[database.Player(playerId)]
 
-func (my *actorT) NiuniuCreateRoom(player *playerT, ev *cow_proto.NiuniuCreateRoom) {
 	if player.InsideCow != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -87,7 +56,9 @@ func (my *actorT) NiuniuCreateRoom(player *playerT, ev *cow_proto.NiuniuCreateRo
 	room.CreateRoom(my, id, ev.GetOption(), player.Player)
 }
 
-func (my *actorT) NiuniuJoinRoom(player *playerT, ev *cow_proto.NiuniuJoinRoom) {
+func (my *actorT) NiuniuJoinRoom(playerId uint64, ev *cow_proto.NiuniuJoinRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -109,7 +80,9 @@ func (my *actorT) NiuniuJoinRoom(player *playerT, ev *cow_proto.NiuniuJoinRoom)
 	room.JoinRoom(player)
 }
 
-func (my *actorT) NiuniuLeaveRoom(player *playerT, ev *cow_proto.NiuniuLeaveRoom) {
+func (my *actorT) NiuniuLeaveRoom(playerId uint64, ev *cow_proto.NiuniuLeaveRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -130,7 +103,9 @@ func (my *actorT) NiuniuLeaveRoom(player *playerT, ev *cow_proto.NiuniuLeaveRoom
 	room.LeaveRoom(player)
 }
 
-func (my *actorT) NiuniuSwitchReady(player *playerT, ev *cow_proto.NiuniuSwitchReady) {
+func (my *actorT) NiuniuSwitchReady(playerId uint64, ev *cow_proto.NiuniuSwitchReady) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -151,7 +126,9 @@ func (my *actorT) NiuniuSwitchReady(player *playerT, ev *cow_proto.NiuniuSwitchR
 	room.SwitchReady(player)
 }
 
-func (my *actorT) NiuniuDismiss(player *playerT, ev *cow_proto.NiuniuDismiss) {
+func (my *actorT) NiuniuDismiss(playerId uint64, ev *cow_proto.NiuniuDismiss) {
+	player := my.players[database.Player(playerId)]
+
 	roomId := int32(0)
 
 	if ev.GetRoomId() != 0 {
@@ -179,7 +156,9 @@ func (my *actorT) NiuniuDismiss(player *playerT, ev *cow_proto.NiuniuDismiss) {
 	room.Dismiss(player)
 }
 
-func (my *actorT) NiuniuKickPlayer(player *playerT, ev *cow_proto.NiuniuKickPlayer) {
+func (my *actorT) NiuniuKickPlayer(playerId uint64, ev *cow_proto.NiuniuKickPlayer) {
+	player := my.players[database.Player(playerId)]
+
 	roomId := int32(0)
 
 	if ev.GetRoomId() != 0 {
@@ -207,7 +186,9 @@ func (my *actorT) NiuniuKickPlayer(player *playerT, ev *cow_proto.NiuniuKickPlay
 	room.KickPlayer(player, database.Player(ev.GetPlayerId()), ev.GetBan())
 }
 
-func (my *actorT) NiuniuStart(player *playerT, ev *cow_proto.NiuniuStart) {
+func (my *actorT) NiuniuStart(playerId uint64, ev *cow_proto.NiuniuStart) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -228,7 +209,9 @@ func (my *actorT) NiuniuStart(player *playerT, ev *cow_proto.NiuniuStart) {
 	room.Start(player)
 }
 
-func (my *actorT) NiuniuSpecifyBanker(player *playerT, ev *cow_proto.NiuniuSpecifyBanker) {
+func (my *actorT) NiuniuSpecifyBanker(playerId uint64, ev *cow_proto.NiuniuSpecifyBanker) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -249,7 +232,9 @@ func (my *actorT) NiuniuSpecifyBanker(player *playerT, ev *cow_proto.NiuniuSpeci
 	room.SpecifyBanker(player, database.Player(ev.GetBanker()))
 }
 
-func (my *actorT) NiuniuGrab(player *playerT, ev *cow_proto.NiuniuGrab) {
+func (my *actorT) NiuniuGrab(playerId uint64, ev *cow_proto.NiuniuGrab) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -270,7 +255,9 @@ func (my *actorT) NiuniuGrab(player *playerT, ev *cow_proto.NiuniuGrab) {
 	room.Grab(player, ev.GetDoing())
 }
 
-func (my *actorT) NiuniuSpecifyRate(player *playerT, ev *cow_proto.NiuniuSpecifyRate) {
+func (my *actorT) NiuniuSpecifyRate(playerId uint64, ev *cow_proto.NiuniuSpecifyRate) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -295,7 +282,9 @@ func (my *actorT) NiuniuSpecifyRate(player *playerT, ev *cow_proto.NiuniuSpecify
 	room.SpecifyRate(player, ev.GetRate())
 }
 
-func (my *actorT) NiuniuContinueWith(player *playerT, ev *cow_proto.NiuniuContinueWith) {
+func (my *actorT) NiuniuContinueWith(playerId uint64, ev *cow_proto.NiuniuContinueWith) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -316,7 +305,9 @@ func (my *actorT) NiuniuContinueWith(player *playerT, ev *cow_proto.NiuniuContin
 	room.ContinueWith(player)
 }
 
-func (my *actorT) NiuniuPostRoomMessage(player *playerT, ev *cow_proto.NiuniuPostRoomMessage) {
+func (my *actorT) NiuniuPostRoomMessage(playerId uint64, ev *cow_proto.NiuniuPostRoomMessage) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideCow == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
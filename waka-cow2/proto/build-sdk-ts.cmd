@@ -0,0 +1,12 @@
+@echo off
+
+set ProjectPath=%GOPATH%\src\github.com\liuhan907\waka
+set SDKPath=%ProjectPath%\sdk\WakaTS
+set ProtoName=waka
+
+if not exist %SDKPath%\Generated mkdir %SDKPath%\Generated
+
+protoc.exe %ProtoName%.proto --waka_out=lang=ts:%SDKPath%\Generated
+
+call pbjs -t static-module -w es6 -o %SDKPath%\Generated\proto.js %ProjectPath%\waka\proto\waka.proto %ProtoName%.proto
+call pbts -o %SDKPath%\Generated\proto.d.ts %SDKPath%\Generated\proto.js
\ No newline at end of file
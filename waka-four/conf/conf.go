@@ -1,12 +1,5 @@
 package conf
 
-import (
-	"fmt"
-	"io/ioutil"
-
-	"github.com/BurntSushi/toml"
-)
-
 type Debug struct {
 	SupervisorLog   bool `toml:"supervisor_log"`
 	SessionLog      bool `toml:"session_log"`
@@ -19,19 +12,36 @@ type Logger struct {
 }
 
 type Install struct {
-	Reset  bool `toml:"reset"`
-	Update bool `toml:"update"`
+	Reset      bool `toml:"reset"`
+	Update     bool `toml:"update"`
+	Production bool `toml:"production"`
 }
 
 type Database struct {
+	Driver   string `toml:"driver"`
 	Host     string `toml:"host"`
 	User     string `toml:"user"`
 	Password string `toml:"password"`
 	Name     string `toml:"name"`
 }
 
+type Cache struct {
+	TTL  int32 `toml:"ttl"`
+	Size int32 `toml:"size"`
+	Poll int32 `toml:"poll"`
+}
+
+type Archive struct {
+	Age       int32 `toml:"age"`
+	Retention int32 `toml:"retention"`
+	Interval  int32 `toml:"interval"`
+	Batch     int32 `toml:"batch"`
+}
+
 type Gateway struct {
-	Listen4 string `toml:"listen4"`
+	Listen4    string   `toml:"listen4"`
+	WebSocket4 string   `toml:"websocket4"`
+	Codecs     []string `toml:"codecs"`
 }
 
 type Backend struct {
@@ -52,6 +62,8 @@ type T struct {
 	Log      Logger   `toml:"log"`
 	Install  Install  `toml:"install"`
 	Database Database `toml:"database"`
+	Cache    Cache    `toml:"cache"`
+	Archive  Archive  `toml:"archive"`
 	Gateway  Gateway  `toml:"gateway"`
 	Backend  Backend  `toml:"backend"`
 	Hall     Hall     `toml:"hall"`
@@ -60,15 +72,3 @@ type T struct {
 var (
 	Option T
 )
-
-func init() {
-	d, err := ioutil.ReadFile("conf.toml")
-	if err != nil {
-		panic(fmt.Sprintf("read config file \"conf.toml\" failed: %s\n", err.Error()))
-	}
-
-	_, err = toml.Decode(string(d), &Option)
-	if err != nil {
-		panic(fmt.Sprintf("decode config file \"conf.toml\" failed: %s\n", err.Error()))
-	}
-}
@@ -0,0 +1,157 @@
+package conf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka/codec"
+)
+
+// 环境变量前缀, 如 FOUR_DATABASE_PASSWORD
+const envPrefix = "FOUR_"
+
+// 可以用环境变量覆盖的配置项, 密钥不应写入配置文件
+var variables = []struct {
+	Name   string
+	Value  func(option *T) *string
+	Secret bool
+}{
+	{"DATABASE_HOST", func(option *T) *string { return &option.Database.Host }, false},
+	{"DATABASE_USER", func(option *T) *string { return &option.Database.User }, false},
+	{"DATABASE_PASSWORD", func(option *T) *string { return &option.Database.Password }, true},
+	{"DATABASE_NAME", func(option *T) *string { return &option.Database.Name }, false},
+	{"HALL_SALT", func(option *T) *string { return &option.Hall.Salt }, true},
+}
+
+// 配置校验错误, 列出所有有问题的配置项
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e, "; ")
+}
+
+// 默认配置, 配置文件中没有出现的项使用默认值
+func Default() T {
+	return T{
+		Log:      Logger{LogLevel: uint32(logrus.InfoLevel)},
+		Install:  Install{Update: true, Production: true},
+		Database: Database{Driver: "mysql", Name: "four"},
+		Cache:    Cache{TTL: 600, Size: 100000, Poll: 1000},
+		Archive:  Archive{Age: 90, Interval: 60, Batch: 500},
+		Gateway:  Gateway{Listen4: "127.0.0.1:9140", Codecs: []string{codec.Protobuf.Name()}},
+		Backend:  Backend{Listen4: "127.0.0.1:8088"},
+		Hall:     Hall{WaterRate: 5, RegisterDiamonds: 1500, BindDiamonds: 20, ShareDiamonds: 10, MinPlayerNumber: 500},
+	}
+}
+
+// 读取配置文件, 依次应用默认值, 配置文件与环境变量, 校验通过后替换 Option
+func Load(path string) error {
+	option, err := Read(path)
+	if err != nil {
+		return err
+	}
+	Option = option
+	return nil
+}
+
+// 读取并校验配置文件, 不替换 Option, 用于重新加载运行时设置
+func Read(path string) (T, error) {
+	option := Default()
+
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return option, fmt.Errorf("read config file %q failed: %v", path, err)
+	}
+	if _, err := toml.Decode(string(d), &option); err != nil {
+		return option, fmt.Errorf("decode config file %q failed: %v", path, err)
+	}
+
+	for _, variable := range variables {
+		if value, being := os.LookupEnv(envPrefix + variable.Name); being {
+			*variable.Value(&option) = value
+		}
+	}
+
+	if err := option.Validate(); err != nil {
+		return option, err
+	}
+	return option, nil
+}
+
+// 校验配置, 返回的 ValidationError 包含所有有问题的项
+func (option *T) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(int(option.Log.LogLevel) < len(logrus.AllLevels), "log.log_level: must be less than %d", len(logrus.AllLevels))
+
+	switch option.Database.Driver {
+	case "", "mysql":
+		check(option.Database.Host != "", "database.host: required for mysql")
+		check(option.Database.User != "", "database.user: required for mysql")
+	case "sqlite3":
+	default:
+		errs = append(errs, fmt.Sprintf("database.driver: unknown driver %q", option.Database.Driver))
+	}
+	check(option.Database.Name != "", "database.name: required")
+
+	check(option.Cache.TTL >= 0, "cache.ttl: must not be negative")
+	check(option.Cache.Size >= 0, "cache.size: must not be negative")
+	check(option.Cache.Poll >= 0, "cache.poll: must not be negative")
+
+	check(option.Archive.Age >= 0, "archive.age: must not be negative")
+	check(option.Archive.Retention >= 0, "archive.retention: must not be negative")
+	check(option.Archive.Retention == 0 || option.Archive.Retention >= option.Archive.Age,
+		"archive.retention: must not be less than archive.age")
+	check(option.Archive.Interval >= 0, "archive.interval: must not be negative")
+	check(option.Archive.Batch >= 0, "archive.batch: must not be negative")
+
+	check(validAddress(option.Gateway.Listen4), "gateway.listen4: invalid address %q", option.Gateway.Listen4)
+	check(option.Gateway.WebSocket4 == "" || validAddress(option.Gateway.WebSocket4), "gateway.websocket4: invalid address %q", option.Gateway.WebSocket4)
+	check(validAddress(option.Backend.Listen4), "backend.listen4: invalid address %q", option.Backend.Listen4)
+	check(len(option.Gateway.Codecs) > 0, "gateway.codecs: required")
+	for _, name := range option.Gateway.Codecs {
+		if _, err := codec.CodecByName(name); err != nil {
+			errs = append(errs, fmt.Sprintf("gateway.codecs: unknown codec %q", name))
+		}
+		check(name != codec.JSON.Name() || !option.Install.Production, "gateway.codecs: json is not allowed in production")
+	}
+
+	check(option.Hall.WaterRate >= 0, "hall.water_rate: must not be negative")
+	check(option.Hall.RegisterDiamonds >= 0, "hall.register_diamonds: must not be negative")
+	check(option.Hall.BindDiamonds >= 0, "hall.bind_diamonds: must not be negative")
+	check(option.Hall.ShareDiamonds >= 0, "hall.share_diamonds: must not be negative")
+	check(option.Hall.MinPlayerNumber >= 0, "hall.min_player_number: must not be negative")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validAddress(address string) bool {
+	_, _, err := net.SplitHostPort(address)
+	return err == nil
+}
+
+// 以 TOML 格式打印生效的配置, 已设置的密钥显示为 ******
+func Print(w io.Writer) error {
+	option := Option
+	for _, variable := range variables {
+		if value := variable.Value(&option); variable.Secret && *value != "" {
+			*value = "******"
+		}
+	}
+	return toml.NewEncoder(w).Encode(option)
+}
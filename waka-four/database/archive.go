@@ -0,0 +1,444 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka-four/proto"
+)
+
+const (
+	// 默认的归档间隔
+	defaultArchiveInterval = time.Hour
+	// 默认每个事务归档的记录数
+	defaultArchiveBatch = 500
+	// 启动后第一次归档的延迟
+	archiveDelay = time.Minute
+)
+
+var (
+	ErrArchiveNotFound      = errors.New("archive not found")
+	ErrUnknownArchiveSource = errors.New("unknown archive source")
+
+	// 关闭时停止归档
+	archiveStop chan struct{}
+)
+
+// 归档的战绩, 原记录以 JSON 编码后 gzip 压缩
+type HistoryArchiveData struct {
+	// 主键
+	Id int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 来源表
+	Source string `gorm:"size:64;unique_index:idx_history_archives_source"`
+	// 原记录的主键
+	SourceId int64 `gorm:"unique_index:idx_history_archives_source"`
+	// 玩家
+	Player Player `gorm:"index"`
+	// 原记录的时间
+	CreatedAt time.Time
+	// 归档时间
+	ArchivedAt time.Time `gorm:"index"`
+	// 压缩后的原记录
+	Payload []byte `gorm:"type:mediumblob"`
+}
+
+func (HistoryArchiveData) TableName() string {
+	return "history_archives"
+}
+
+// 战绩按玩家, 来源表, 类型与天的汇总, 归档时累加, 删除过期归档时保留
+type HistorySummaryData struct {
+	// 主键
+	Id int64 `gorm:"index;unique;primary_key;AUTO_INCREMENT"`
+	// 玩家
+	Player Player `gorm:"unique_index:idx_history_summaries_key"`
+	// 来源表
+	Source string `gorm:"size:64;unique_index:idx_history_summaries_key"`
+	// 类型, 战绩为房间模式, 其它为 0
+	Mode int32 `gorm:"unique_index:idx_history_summaries_key"`
+	// 所在天的开始时间
+	Day time.Time `gorm:"unique_index:idx_history_summaries_key"`
+	// 记录数
+	Total int32
+	// 数额合计, 房卡消费为钻石数, 其它为 0
+	Number int64
+}
+
+func (HistorySummaryData) TableName() string {
+	return "history_summaries"
+}
+
+// 归档的战绩, 用于客服查询
+type ArchivedHistory struct {
+	Source     string
+	Id         int64
+	Player     Player
+	CreatedAt  time.Time
+	ArchivedAt time.Time
+	// 原记录
+	Row interface{}
+	// 原记录中序列化数据解析后的内容, 没有时为 nil
+	Detail proto.Message
+}
+
+// 归档结果
+type ArchiveReport struct {
+	// 各来源表归档的记录数
+	Archived map[string]int
+	// 删除的过期归档数
+	Purged int64
+}
+
+func (report *ArchiveReport) log() {
+	fields := logrus.Fields{
+		"purged": report.Purged,
+	}
+	total := 0
+	for source, count := range report.Archived {
+		fields[source] = count
+		total += count
+	}
+	if total == 0 && report.Purged == 0 {
+		return
+	}
+	log.WithFields(fields).Infoln("histories archived")
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 待归档的记录
+type archiveRow struct {
+	Id        int64
+	Player    Player
+	Mode      int32
+	Number    int64
+	CreatedAt time.Time
+	Row       interface{}
+}
+
+// 归档来源
+type archiveSource struct {
+	// 来源表
+	Table string
+	// 来源表模型
+	Model func() interface{}
+	// 按时间顺序读取一批早于 before 的记录
+	Expired func(s Store, before time.Time, limit int32) ([]*archiveRow, error)
+	// 解析原记录中的序列化数据, 没有时为 nil
+	Detail func(row interface{}) (proto.Message, error)
+}
+
+// 所有需要归档的表
+var archiveSources = []*archiveSource{
+	{
+		Table: "four_war_histories",
+		Model: func() interface{} { return new(FourWarHistory) },
+		Expired: func(s Store, before time.Time, limit int32) ([]*archiveRow, error) {
+			var d []*FourWarHistory
+			if err := s.Archives().FindExpired(&d, before, limit); err != nil {
+				return nil, err
+			}
+			r := make([]*archiveRow, 0, len(d))
+			for _, v := range d {
+				r = append(r, &archiveRow{Id: int64(v.Id), Player: v.Player, Mode: v.Mode, CreatedAt: v.CreatedAt, Row: v})
+			}
+			return r, nil
+		},
+		Detail: func(row interface{}) (proto.Message, error) {
+			pb := &four_proto.FourWarHistory{}
+			return pb, proto.Unmarshal(row.(*FourWarHistory).Payload, pb)
+		},
+	},
+	{
+		Table: "four_order_room_purchase_histories",
+		Model: func() interface{} { return new(FourOrderRoomPurchaseHistory) },
+		Expired: func(s Store, before time.Time, limit int32) ([]*archiveRow, error) {
+			var d []*FourOrderRoomPurchaseHistory
+			if err := s.Archives().FindExpired(&d, before, limit); err != nil {
+				return nil, err
+			}
+			r := make([]*archiveRow, 0, len(d))
+			for _, v := range d {
+				r = append(r, &archiveRow{Id: int64(v.Id), Player: v.Player, Number: v.Number, CreatedAt: v.CreatedAt, Row: v})
+			}
+			return r, nil
+		},
+	},
+	{
+		Table: "four_pay_for_another_room_purchase_histories",
+		Model: func() interface{} { return new(FourPayForAnotherRoomPurchaseHistory) },
+		Expired: func(s Store, before time.Time, limit int32) ([]*archiveRow, error) {
+			var d []*FourPayForAnotherRoomPurchaseHistory
+			if err := s.Archives().FindExpired(&d, before, limit); err != nil {
+				return nil, err
+			}
+			r := make([]*archiveRow, 0, len(d))
+			for _, v := range d {
+				r = append(r, &archiveRow{Id: int64(v.Id), Player: v.Player, Number: v.Number, CreatedAt: v.CreatedAt, Row: v})
+			}
+			return r, nil
+		},
+	},
+}
+
+// 来源表上按时间读取待归档记录的索引
+func archiveIndex(table string) string {
+	return "idx_" + table + "_created_at"
+}
+
+func findArchiveSource(table string) *archiveSource {
+	for _, source := range archiveSources {
+		if source.Table == table {
+			return source
+		}
+	}
+	return nil
+}
+
+func compressRow(row interface{}) ([]byte, error) {
+	d, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(d); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressRow(payload []byte, row interface{}) error {
+	r, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(d, row)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 归档所有来源表中早于 age 的战绩, 并删除早于 retention 的归档, age 为 0 时不归档, retention 为 0 时不删除
+func ArchiveHistories(option Option) (*ArchiveReport, error) {
+	db, err := openGorm(option)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return archiveHistories(&gormStore{db: db}, option, nil)
+}
+
+// 定期归档, ArchiveAge 为 0 时不启动
+func startArchive(s Store, option Option) {
+	if option.ArchiveAge <= 0 {
+		return
+	}
+	interval := option.ArchiveInterval
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+	archiveStop = make(chan struct{})
+	go runArchive(s, option, interval, archiveStop)
+}
+
+func stopArchive() {
+	if archiveStop != nil {
+		close(archiveStop)
+		archiveStop = nil
+	}
+}
+
+func runArchive(s Store, option Option, interval time.Duration, stop chan struct{}) {
+	timer := time.NewTimer(archiveDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		report, err := archiveHistories(s, option, stop)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"err": err,
+			}).Warnln("archive histories failed")
+		}
+		if report != nil {
+			report.log()
+		}
+
+		timer.Reset(interval)
+	}
+}
+
+// 逐表分批归档, 每批在单独的事务中写入归档与汇总并删除原记录
+// 多个进程同时归档时, 重复的归档违反唯一索引, 该批次回滚, 由下次归档继续
+func archiveHistories(s Store, option Option, stop chan struct{}) (*ArchiveReport, error) {
+	report := &ArchiveReport{Archived: make(map[string]int)}
+
+	batch := option.ArchiveBatch
+	if batch <= 0 {
+		batch = defaultArchiveBatch
+	}
+
+	if option.ArchiveAge > 0 {
+		before := time.Now().Add(-option.ArchiveAge)
+		for _, source := range archiveSources {
+			for {
+				select {
+				case <-stop:
+					return report, nil
+				default:
+				}
+
+				count, err := archiveBatch(s, source, before, batch)
+				report.Archived[source.Table] += count
+				if err != nil {
+					return report, errors.WithMessage(err, "archive "+source.Table)
+				}
+				if count < int(batch) {
+					break
+				}
+			}
+		}
+	}
+
+	if option.ArchiveRetention > 0 {
+		purged, err := s.Archives().DeleteBefore(time.Now().Add(-option.ArchiveRetention))
+		if err != nil {
+			return report, err
+		}
+		report.Purged = purged
+	}
+
+	return report, nil
+}
+
+func archiveBatch(s Store, source *archiveSource, before time.Time, limit int32) (int, error) {
+	type summaryKey struct {
+		Player Player
+		Mode   int32
+		Day    int64
+	}
+
+	count := 0
+	err := s.Transaction(func(s Store) error {
+		rows, err := source.Expired(s, before, limit)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		ids := make([]int64, 0, len(rows))
+		summaries := make(map[summaryKey]*HistorySummaryData)
+		for _, row := range rows {
+			payload, err := compressRow(row.Row)
+			if err != nil {
+				return err
+			}
+			if err := s.Archives().Create(&HistoryArchiveData{
+				Source:     source.Table,
+				SourceId:   row.Id,
+				Player:     row.Player,
+				CreatedAt:  row.CreatedAt,
+				ArchivedAt: now,
+				Payload:    payload,
+			}); err != nil {
+				return err
+			}
+			ids = append(ids, row.Id)
+
+			at := row.CreatedAt.In(time.Local)
+			day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.Local)
+			key := summaryKey{row.Player, row.Mode, day.Unix()}
+			summary, being := summaries[key]
+			if !being {
+				summary = &HistorySummaryData{
+					Player: row.Player,
+					Source: source.Table,
+					Mode:   row.Mode,
+					Day:    day,
+				}
+				summaries[key] = summary
+			}
+			summary.Total++
+			summary.Number += row.Number
+		}
+
+		for _, summary := range summaries {
+			if err := s.Archives().AddSummary(summary); err != nil {
+				return err
+			}
+		}
+		if err := s.Archives().DeleteArchived(source.Model(), ids); err != nil {
+			return err
+		}
+
+		count = len(rows)
+		return nil
+	})
+	return count, err
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// 根据来源表与原记录的主键查询归档的战绩
+func QueryArchivedHistory(table string, id int64) (*ArchivedHistory, error) {
+	source := findArchiveSource(table)
+	if source == nil {
+		return nil, ErrUnknownArchiveSource
+	}
+
+	archive, err := store.Archives().Find(table, id)
+	if err != nil {
+		return nil, err
+	}
+	if archive == nil {
+		return nil, ErrArchiveNotFound
+	}
+
+	row := source.Model()
+	if err := decompressRow(archive.Payload, row); err != nil {
+		return nil, err
+	}
+	r := &ArchivedHistory{
+		Source:     archive.Source,
+		Id:         archive.SourceId,
+		Player:     archive.Player,
+		CreatedAt:  archive.CreatedAt,
+		ArchivedAt: archive.ArchivedAt,
+		Row:        row,
+	}
+	if source.Detail != nil {
+		if r.Detail, err = source.Detail(row); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// 查询玩家在 [from, to) 内已归档战绩的汇总, 按天排序
+func QueryHistorySummaries(player Player, from, to time.Time) ([]*HistorySummaryData, error) {
+	return store.Archives().FindSummaries(player, from, to)
+}
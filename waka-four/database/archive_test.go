@@ -0,0 +1,178 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/liuhan907/waka/waka-four/proto"
+)
+
+func countTestRows(t *testing.T, model interface{}) int {
+	var count int
+	if err := store.(*gormStore).db.Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+type testSummary struct {
+	Source string
+	Mode   int32
+	Total  int32
+	Number int64
+}
+
+func assertSummaries(t *testing.T, player Player, day time.Time, want []testSummary) {
+	summaries, err := QueryHistorySummaries(player, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != len(want) {
+		t.Fatalf("player %d summaries %d, want %d", player, len(summaries), len(want))
+	}
+	for i, summary := range summaries {
+		got := testSummary{summary.Source, summary.Mode, summary.Total, summary.Number}
+		if got != want[i] || !summary.Day.Equal(day) {
+			t.Errorf("player %d summary %d = %+v on %v, want %+v on %v", player, i, got, summary.Day, want[i], day)
+		}
+	}
+}
+
+func TestArchiveHistories(t *testing.T) {
+	openTestStore(t)
+	first := createTestPlayer(t, 0)
+	second := createTestPlayer(t, 0)
+
+	at := time.Now().AddDate(0, 0, -3)
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.Local)
+	old := day.Add(time.Hour)
+	detail := &four_proto.FourWarHistory{RoomId: 7, Type: 1, CreatedAt: 1}
+	payload, err := proto.Marshal(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histories, purchases := store.Histories(), store.Purchases()
+	oldWar := &FourWarHistory{Player: first, Mode: 1, Payload: payload, CreatedAt: old}
+	recentWar := &FourWarHistory{Player: first, Mode: 1, Payload: payload, CreatedAt: time.Now()}
+	for _, err := range []error{
+		histories.CreateWar(oldWar),
+		histories.CreateWar(&FourWarHistory{Player: first, Mode: 1, Payload: payload, CreatedAt: old.Add(time.Minute)}),
+		histories.CreateWar(&FourWarHistory{Player: first, Mode: 2, Payload: payload, CreatedAt: old}),
+		histories.CreateWar(recentWar),
+		purchases.CreateOrderRoom(&FourOrderRoomPurchaseHistory{Player: first, Room: 7, Number: 3, CreatedAt: old}),
+		purchases.CreateOrderRoom(&FourOrderRoomPurchaseHistory{Player: first, Room: 8, Number: 6, CreatedAt: old.Add(time.Minute)}),
+		purchases.CreateOrderRoom(&FourOrderRoomPurchaseHistory{Player: second, Room: 9, Number: 2, CreatedAt: old}),
+		purchases.CreatePayForAnotherRoom(&FourPayForAnotherRoomPurchaseHistory{Player: second, Room: 10, Number: 4, CreatedAt: old}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 每批两条, 覆盖分批归档
+	option := Option{ArchiveAge: time.Hour * 24, ArchiveBatch: 2}
+	report, err := archiveHistories(store, option, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := map[string]int{
+		"four_war_histories":                           3,
+		"four_order_room_purchase_histories":           3,
+		"four_pay_for_another_room_purchase_histories": 1,
+	}
+	for source, count := range archived {
+		if report.Archived[source] != count {
+			t.Errorf("archived %d from %s, want %d", report.Archived[source], source, count)
+		}
+	}
+
+	// 原记录删除, 未过期的保留
+	if n := countTestRows(t, new(FourWarHistory)); n != 1 {
+		t.Fatalf("four_war_histories rows %d, want 1", n)
+	}
+	for _, model := range []interface{}{new(FourOrderRoomPurchaseHistory), new(FourPayForAnotherRoomPurchaseHistory)} {
+		if n := countTestRows(t, model); n != 0 {
+			t.Fatalf("%T rows %d, want 0", model, n)
+		}
+	}
+	if n := countTestRows(t, new(HistoryArchiveData)); n != 7 {
+		t.Fatalf("archives %d, want 7", n)
+	}
+
+	firstSummaries := []testSummary{
+		{"four_order_room_purchase_histories", 0, 2, 9},
+		{"four_war_histories", 1, 2, 0},
+		{"four_war_histories", 2, 1, 0},
+	}
+	secondSummaries := []testSummary{
+		{"four_order_room_purchase_histories", 0, 1, 2},
+		{"four_pay_for_another_room_purchase_histories", 0, 1, 4},
+	}
+	assertSummaries(t, first, day, firstSummaries)
+	assertSummaries(t, second, day, secondSummaries)
+
+	// 按来源表与原记录的主键取回归档
+	history, err := QueryArchivedHistory("four_war_histories", int64(oldWar.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, ok := history.Row.(*FourWarHistory)
+	if !ok || row.Id != oldWar.Id || row.Player != first || history.Player != first || !history.CreatedAt.Equal(old) {
+		t.Fatalf("archived history %+v, row %+v", history, history.Row)
+	}
+	if !proto.Equal(history.Detail, detail) {
+		t.Fatalf("archived detail %v, want %v", history.Detail, detail)
+	}
+	if _, err := QueryArchivedHistory("four_war_histories", int64(recentWar.Id)); err != ErrArchiveNotFound {
+		t.Fatalf("query recent history: %v", err)
+	}
+	if _, err := QueryArchivedHistory("players", 1); err != ErrUnknownArchiveSource {
+		t.Fatalf("query unknown source: %v", err)
+	}
+
+	// 再次归档没有可归档的记录
+	report, err = archiveHistories(store, option, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, count := range report.Archived {
+		if count != 0 {
+			t.Errorf("archived %d from %s again", count, source)
+		}
+	}
+
+	// 另一进程已归档同一记录时违反唯一索引, 整批回滚
+	if err := histories.CreateWar(&FourWarHistory{Id: oldWar.Id, Player: first, Mode: 1, CreatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveBatch(store, findArchiveSource("four_war_histories"), time.Now().Add(-option.ArchiveAge), 2); err == nil {
+		t.Fatal("archived a row twice")
+	}
+	if n := countTestRows(t, new(FourWarHistory)); n != 2 {
+		t.Fatalf("four_war_histories rows %d after duplicate, want 2", n)
+	}
+	if n := countTestRows(t, new(HistoryArchiveData)); n != 7 {
+		t.Fatalf("archives %d after duplicate, want 7", n)
+	}
+	assertSummaries(t, first, day, firstSummaries)
+
+	// 删除过期归档时保留汇总
+	if err := store.(*gormStore).db.Model(new(HistoryArchiveData)).UpdateColumn("archived_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	report, err = archiveHistories(store, Option{ArchiveRetention: time.Hour * 24}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Purged != 7 {
+		t.Fatalf("purged %d, want 7", report.Purged)
+	}
+	if _, err := QueryArchivedHistory("four_war_histories", int64(oldWar.Id)); err != ErrArchiveNotFound {
+		t.Fatalf("query purged history: %v", err)
+	}
+	assertSummaries(t, first, day, firstSummaries)
+	assertSummaries(t, second, day, secondSummaries)
+}
@@ -1,8 +1,12 @@
 package database
 
 import (
+	"reflect"
 	"sync"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka-four/conf"
 	"github.com/liuhan907/waka/waka-four/proto"
 )
 
@@ -43,44 +47,87 @@ type Configuration struct {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
+// 运行时设置, 由配置文件的 hall 段与系统配置表组成, 重新加载时整体替换, 读取后不要修改
+type Settings struct {
+	// 配置文件的 hall 段
+	Hall conf.Hall
+	// 客服信息
+	Customers []*four_proto.Welcome_Customer
+	// 附加配置
+	Exts map[string]string
+	// 公告
+	Notices map[string]string
+	// 链接配置
+	Urls map[string]string
+}
+
+// 发送给客户端的欢迎消息
+func (settings *Settings) Welcome() *four_proto.Welcome {
+	return &four_proto.Welcome{
+		Customers: settings.Customers,
+		Exts:      settings.Exts,
+		Notices:   settings.Notices,
+		Urls:      settings.Urls,
+	}
+}
+
 var (
-	lock             sync.RWMutex
-	customerServices []*four_proto.Welcome_Customer
-	ext              map[string]string
-	notices          map[string]string
-	urls             map[string]string
+	lock     sync.RWMutex
+	settings = &Settings{}
+	watchers []func(settings *Settings)
+
+	// 串行化重新加载, 避免同时重新加载时丢失 hall 段的修改
+	reloadLock sync.Mutex
 )
 
-// 获取附加配置
-func GetExt() map[string]string {
+// 获取当前的运行时设置
+func GetSettings() *Settings {
 	lock.RLock()
 	defer lock.RUnlock()
-	return ext
+	return settings
+}
+
+// 注册设置变更通知, 在重新加载的 goroutine 中调用, 不要阻塞
+func WatchSettings(fn func(settings *Settings)) {
+	lock.Lock()
+	defer lock.Unlock()
+	watchers = append(watchers, fn)
+}
+
+// 获取附加配置
+func GetExt() map[string]string {
+	return GetSettings().Exts
 }
 
 // 获取公告
 func GetNotices() map[string]string {
-	lock.RLock()
-	defer lock.RUnlock()
-	return notices
+	return GetSettings().Notices
 }
 
 // 获取链接配置
 func GetUrls() map[string]string {
-	lock.RLock()
-	defer lock.RUnlock()
-	return urls
+	return GetSettings().Urls
 }
 
 // 获取客服信息
 func GetCustomerServices() []*four_proto.Welcome_Customer {
-	lock.RLock()
-	defer lock.RUnlock()
-	return customerServices
+	return GetSettings().Customers
+}
+
+// 使用新的 hall 段并重新读取系统配置表, 设置有变化时通知
+func ReloadSettings(hall conf.Hall) error {
+	return reloadSettings(&hall)
 }
 
-// 刷新配置
+// 重新读取系统配置表, hall 段保持不变
 func RefreshConfiguration() error {
+	return reloadSettings(nil)
+}
+
+func reloadSettings(hall *conf.Hall) error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
 	v1, err := getCustomerServices()
 	if err != nil {
 		return err
@@ -101,15 +148,40 @@ func RefreshConfiguration() error {
 		return err
 	}
 
-	lock.Lock()
-
-	customerServices = v1
-	ext = v2
-	notices = v3
-	urls = v4
+	old := GetSettings()
+	updated := &Settings{
+		Hall:      old.Hall,
+		Customers: v1,
+		Exts:      v2,
+		Notices:   v3,
+		Urls:      v4,
+	}
+	if hall != nil {
+		updated.Hall = *hall
+	}
+	if reflect.DeepEqual(old, updated) {
+		return nil
+	}
 
+	lock.Lock()
+	settings = updated
+	fns := watchers
 	lock.Unlock()
 
+	log.WithFields(logrus.Fields{
+		"register_diamonds": updated.Hall.RegisterDiamonds,
+		"bind_diamonds":     updated.Hall.BindDiamonds,
+		"share_diamonds":    updated.Hall.ShareDiamonds,
+		"customers":         len(updated.Customers),
+		"exts":              len(updated.Exts),
+		"notices":           len(updated.Notices),
+		"urls":              len(updated.Urls),
+	}).Infoln("settings changed")
+
+	for _, fn := range fns {
+		fn(updated)
+	}
+
 	return nil
 }
 
@@ -126,8 +198,8 @@ func getUrls() (map[string]string, error) {
 }
 
 func getCustomerServices() ([]*four_proto.Welcome_Customer, error) {
-	var vals []*Configuration
-	if err := mysql.Where("type = ?", "customer_service").Find(&vals).Error; err != nil {
+	vals, err := store.Configurations().Find("customer_service")
+	if err != nil {
 		return nil, err
 	}
 	var result []*four_proto.Welcome_Customer
@@ -141,8 +213,8 @@ func getCustomerServices() ([]*four_proto.Welcome_Customer, error) {
 }
 
 func getMap(mapType string) (map[string]string, error) {
-	var vals []*Configuration
-	if err := mysql.Where("type = ?", mapType).Find(&vals).Error; err != nil {
+	vals, err := store.Configurations().Find(mapType)
+	if err != nil {
 		return nil, err
 	}
 	r := make(map[string]string, len(vals))
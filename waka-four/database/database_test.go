@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/liuhan907/waka/waka-four/conf"
+)
+
+// 打开内存数据库, 测试结束时关闭
+func openTestStore(t testing.TB) {
+	conf.Option = conf.Default()
+	if err := Open(Option{Driver: DriverSQLite, Name: MemorySource}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+var testPlayerSerial int
+
+func createTestPlayer(t testing.TB, diamonds int64) Player {
+	testPlayerSerial++
+	player := &PlayerData{
+		UnionId:     fmt.Sprintf("test-unionid-%d", testPlayerSerial),
+		Token:       fmt.Sprintf("test-token-%d", testPlayerSerial),
+		Diamonds:    diamonds,
+		VictoryRate: 100,
+		CreatedAt:   time.Now(),
+	}
+	if err := store.Players().Create(player); err != nil {
+		t.Fatal(err)
+	}
+	return player.Id
+}
+
+func testDiamonds(t testing.TB, player Player) int64 {
+	playerData, err := store.Players().Find(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playerData == nil {
+		t.Fatalf("player %d not found", player)
+	}
+	return playerData.Diamonds
+}
+
+// 直接读取表中的记录, 仓库没有提供查询的表使用
+func findTestRows(t testing.TB, out interface{}, where string, args ...interface{}) {
+	if err := store.(*gormStore).db.Where(where, args...).Order("id").Find(out).Error; err != nil {
+		t.Fatal(err)
+	}
+}
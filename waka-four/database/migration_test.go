@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func openTestGorm(t *testing.T) *gorm.DB {
+	db, err := openGorm(Option{Driver: DriverSQLite, Name: MemorySource})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// 检查迁移建立了模型需要的所有表, 列与索引, 修改模型时忘记新增迁移步骤会失败
+func checkSchema(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	dialect := db.Dialect()
+	for _, model := range tables {
+		scope := db.NewScope(model)
+		table := scope.TableName()
+		if !dialect.HasTable(table) {
+			t.Errorf("table %s not created", table)
+			continue
+		}
+		for _, field := range scope.GetModelStruct().StructFields {
+			if !field.IsNormal || field.IsIgnored {
+				continue
+			}
+			if !dialect.HasColumn(table, field.DBName) {
+				t.Errorf("column %s.%s not created", table, field.DBName)
+			}
+			for _, key := range []string{"INDEX", "UNIQUE_INDEX"} {
+				name, being := field.TagSettingsGet(key)
+				if !being {
+					continue
+				}
+				if name == key {
+					name = fmt.Sprintf("idx_%v_%v", table, field.DBName)
+				}
+				if !dialect.HasIndex(table, name) {
+					t.Errorf("index %s on %s not created", name, table)
+				}
+			}
+		}
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	db := openTestGorm(t)
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, db)
+
+	// 已应用的迁移不会重复执行
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db := openTestGorm(t)
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDown(db, len(migrations), true); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("%d migrations still applied", len(applied))
+	}
+	if db.HasTable("players") {
+		t.Fatal("players not dropped")
+	}
+
+	// 逐个版本迁移, 每个步骤只依赖之前步骤建立的表结构
+	for _, m := range migrations {
+		if err := migrateUp(db, m.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkSchema(t, db)
+}
+
+// 生产环境中存在玩家时拒绝回滚删除数据的步骤, 一个步骤也不回滚
+func TestRollbackRefusesNonEmpty(t *testing.T) {
+	db := openTestGorm(t)
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDown(db, len(migrations), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("insert into players (id) values (?)", 200000).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateDown(db, len(migrations), true); err != ErrRollbackNotEmpty {
+		t.Fatalf("rollback non-empty production database: %v", err)
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("%d migrations applied after refused rollback, want %d", len(applied), len(migrations))
+	}
+	checkSchema(t, db)
+
+	// 非生产环境只警告
+	if err := migrateDown(db, len(migrations), false); err != nil {
+		t.Fatal(err)
+	}
+	if db.HasTable("players") {
+		t.Fatal("players not dropped")
+	}
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	db := openTestGorm(t)
+	if err := migrateUp(db, migrations[len(migrations)-1].Version+1); err == nil {
+		t.Fatal("migrate to unknown version succeeded")
+	}
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 所有表, 新增表时同时加入
+var tables = []interface{}{
+	new(PlayerData),
+	new(PlayerChangeData),
+	new(FriendData), new(AskData),
+	new(FourOrderRoomPurchaseHistory), new(FourPayForAnotherRoomPurchaseHistory),
+	new(FourWarHistory),
+	new(Configuration),
+	new(HistoryArchiveData), new(HistorySummaryData),
+}
+
+// 所有迁移, 按版本号顺序追加
+// 每个步骤使用 migrations_schema.go 中该版本冻结的表结构, 按表名修改列与索引, 不引用当前的模型
+var migrations = []*migration{
+	{
+		Version: 1,
+		Name:    "create_tables",
+		Up: func(db *gorm.DB) error {
+			// 兼容引入迁移之前由 AutoMigrate 建立的表
+			if err := db.AutoMigrate(
+				new(playerV1),
+				new(friendV1), new(askV1),
+				new(orderRoomPurchaseV1), new(payForAnotherRoomPurchaseV1),
+				new(warHistoryV1),
+				new(configurationV1),
+			).Error; err != nil {
+				return err
+			}
+			if db.Dialect().GetName() == DriverMySQL {
+				return db.Exec("alter table players AUTO_INCREMENT = 100000;").Error
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(
+				new(playerV1),
+				new(friendV1), new(askV1),
+				new(orderRoomPurchaseV1), new(payForAnotherRoomPurchaseV1),
+				new(warHistoryV1),
+				new(configurationV1),
+			).Error
+		},
+		DropsData: true,
+	},
+	{
+		Version: 2,
+		Name:    "widen_diamonds",
+		// 新版本的代码同时兼容 32 位与 64 位的列, 可以先发布再在低峰期执行迁移
+		// MySQL 修改列类型时会复制表并阻塞写入, 大表可先用在线变更工具改为 bigint, 迁移会跳过已是 bigint 的列
+		Up: func(db *gorm.DB) error {
+			if err := widenColumn(db, "players", "diamonds"); err != nil {
+				return err
+			}
+			if err := widenColumn(db, "four_order_room_purchase_histories", "number"); err != nil {
+				return err
+			}
+			return widenColumn(db, "four_pay_for_another_room_purchase_histories", "number")
+		},
+		Down: func(db *gorm.DB) error {
+			if err := narrowColumn(db, "players", "diamonds"); err != nil {
+				return err
+			}
+			if err := narrowColumn(db, "four_order_room_purchase_histories", "number"); err != nil {
+				return err
+			}
+			return narrowColumn(db, "four_pay_for_another_room_purchase_histories", "number")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create_player_changes",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(new(playerChangeV3)).Error; err != nil {
+				return err
+			}
+			return createChangeTriggers(db)
+		},
+		Down: func(db *gorm.DB) error {
+			if err := dropChangeTriggers(db); err != nil {
+				return err
+			}
+			return db.DropTableIfExists(new(playerChangeV3)).Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_history_cursor",
+		Up: func(db *gorm.DB) error {
+			// 战绩分页的索引, 列顺序与查询条件和排序一致
+			return db.Table("four_war_histories").AddIndex(historyCursorIndex, "player", "created_at", "id").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Table("four_war_histories").RemoveIndex(historyCursorIndex).Error
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_history_archives",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(new(historyArchiveV5), new(historySummaryV5)).Error; err != nil {
+				return err
+			}
+			// 按时间读取待归档的记录
+			for _, table := range archiveTablesV5 {
+				if err := db.Table(table).AddIndex(archiveIndex(table), "created_at").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			for _, table := range archiveTablesV5 {
+				if err := db.Table(table).RemoveIndex(archiveIndex(table)).Error; err != nil {
+					return err
+				}
+			}
+			return db.DropTableIfExists(new(historyArchiveV5), new(historySummaryV5)).Error
+		},
+		DropsData: true,
+	},
+}
+
+const historyCursorIndex = "idx_four_war_histories_cursor"
+
+// 版本 5 时的归档来源表, 之后新增的来源表在新的迁移步骤中建立索引
+var archiveTablesV5 = []string{
+	"four_war_histories",
+	"four_order_room_purchase_histories",
+	"four_pay_for_another_room_purchase_histories",
+}
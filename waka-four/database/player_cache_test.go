@@ -0,0 +1,340 @@
+package database
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCachedPlayer(id Player) *PlayerData {
+	return &PlayerData{
+		Id:       id,
+		Token:    fmt.Sprintf("token-%d", id),
+		UnionId:  fmt.Sprintf("unionid-%d", id),
+		Nickname: "player",
+		Diamonds: int64(id),
+	}
+}
+
+// 将缓存项的加载时间提前到刚好过期
+func expireCached(c *playerCache, id Player) {
+	shard := c.shard(id)
+	shard.Lock()
+	if entry, being := shard.players[id]; being {
+		shard.players[id] = &cacheEntry{player: entry.player, loaded: time.Now().Add(-c.ttl)}
+	}
+	shard.Unlock()
+}
+
+func cachedCount(c *playerCache) (players int, keys int) {
+	for i := range c.shards {
+		players += len(c.shards[i].players)
+		keys += len(c.keys[i].keys)
+	}
+	return players, keys
+}
+
+func TestPlayerCacheCopies(t *testing.T) {
+	c := newPlayerCache(time.Minute, 100)
+
+	player := newCachedPlayer(100001)
+	c.put(player)
+	player.Diamonds = 0
+
+	got, being := c.get(100001)
+	if !being || got.Diamonds != 100001 {
+		t.Fatalf("get = %+v, %v", got, being)
+	}
+	got.Diamonds = 0
+	if again, _ := c.get(100001); again.Diamonds != 100001 {
+		t.Fatalf("cached player modified by caller: %+v", again)
+	}
+
+	c.update(100001, func(player *PlayerData) { player.Diamonds += 1 })
+	if again, _ := c.get(100001); again.Diamonds != 100002 {
+		t.Fatalf("update not applied: %+v", again)
+	}
+	// 不在缓存中时 update 什么也不做
+	c.update(100002, func(player *PlayerData) { t.Fatal("update called for missing player") })
+}
+
+func TestPlayerCacheTTL(t *testing.T) {
+	c := newPlayerCache(time.Minute, 100)
+	c.put(newCachedPlayer(100001))
+	c.put(newCachedPlayer(100002))
+
+	expireCached(c, 100001)
+	if _, being := c.get(100001); being {
+		t.Fatal("expired player returned")
+	}
+	if _, being := c.getByKey(tokenKey("token-100001")); being {
+		t.Fatal("expired player returned by key")
+	}
+	if _, being := c.get(100002); !being {
+		t.Fatal("unexpired player missing")
+	}
+
+	// update 保留原来的加载时间, 不会延长有效期
+	c.update(100001, func(player *PlayerData) { player.Diamonds = 1 })
+	if _, being := c.get(100001); being {
+		t.Fatal("update refreshed expired player")
+	}
+
+	// 重新写入后有效
+	c.put(newCachedPlayer(100001))
+	if _, being := c.get(100001); !being {
+		t.Fatal("player missing after put")
+	}
+}
+
+func TestPlayerCacheEvictsWhenFull(t *testing.T) {
+	// 每个分片最多 2 个玩家
+	c := newPlayerCache(time.Minute, cacheShards*2)
+
+	ids := []Player{100001, 100001 + cacheShards, 100001 + cacheShards*2, 100001 + cacheShards*3}
+	for _, id := range ids {
+		c.put(newCachedPlayer(id))
+	}
+	// 其他分片不受影响
+	c.put(newCachedPlayer(100002))
+
+	players, keys := cachedCount(c)
+	if players != 3 || keys != 6 {
+		t.Fatalf("cached %d players and %d keys, want 3 and 6", players, keys)
+	}
+	if _, being := c.get(ids[len(ids)-1]); !being {
+		t.Fatal("last put player evicted")
+	}
+	if _, being := c.get(100002); !being {
+		t.Fatal("player in another shard evicted")
+	}
+
+	// 被淘汰的玩家同时删除索引
+	hits := 0
+	for _, id := range ids {
+		_, byId := c.get(id)
+		_, byToken := c.getByKey(tokenKey(fmt.Sprintf("token-%d", id)))
+		_, byUnionId := c.getByKey(unionIdKey(fmt.Sprintf("unionid-%d", id)))
+		if byId != byToken || byId != byUnionId {
+			t.Fatalf("player %d: id %v, token %v, unionid %v", id, byId, byToken, byUnionId)
+		}
+		if byId {
+			hits++
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("%d players left in full shard, want 2", hits)
+	}
+
+	// 替换已缓存的玩家不淘汰其他玩家
+	c.put(newCachedPlayer(ids[len(ids)-1]))
+	if players, _ := cachedCount(c); players != 3 {
+		t.Fatalf("cached %d players after replace, want 3", players)
+	}
+}
+
+func TestPlayerCacheKeyIndex(t *testing.T) {
+	c := newPlayerCache(time.Minute, 100)
+	c.put(newCachedPlayer(100001))
+
+	if player, being := c.getByKey(tokenKey("token-100001")); !being || player.Id != 100001 {
+		t.Fatalf("get by token = %+v, %v", player, being)
+	}
+	if player, being := c.getByKey(unionIdKey("unionid-100001")); !being || player.Id != 100001 {
+		t.Fatalf("get by unionid = %+v, %v", player, being)
+	}
+	// 不同类型的键不会互相命中
+	if _, being := c.getByKey(unionIdKey("token-100001")); being {
+		t.Fatal("token matched as unionid")
+	}
+
+	// 修改令牌后旧令牌失效
+	c.update(100001, func(player *PlayerData) { player.Token = "renewed" })
+	if _, being := c.getByKey(tokenKey("token-100001")); being {
+		t.Fatal("old token still indexed after update")
+	}
+	if player, being := c.getByKey(tokenKey("renewed")); !being || player.Id != 100001 {
+		t.Fatalf("get by renewed token = %+v, %v", player, being)
+	}
+
+	// 令牌转移给另一个玩家后, 旧玩家的替换不会删除新玩家的索引
+	other := newCachedPlayer(100002)
+	other.Token = "renewed"
+	c.put(other)
+	c.put(newCachedPlayer(100001))
+	if player, being := c.getByKey(tokenKey("renewed")); !being || player.Id != 100002 {
+		t.Fatalf("get by transferred token = %+v, %v", player, being)
+	}
+
+	// 索引落后于玩家时校验后视为不存在
+	c.keyShard(tokenKey("stale")).keys[tokenKey("stale")] = 100001
+	if _, being := c.getByKey(tokenKey("stale")); being {
+		t.Fatal("stale key returned player")
+	}
+
+	c.remove(100002)
+	if _, being := c.getByKey(tokenKey("renewed")); being {
+		t.Fatal("removed player returned by token")
+	}
+	if _, being := c.getByKey(unionIdKey("unionid-100002")); being {
+		t.Fatal("removed player returned by unionid")
+	}
+
+	c.reset()
+	if players, keys := cachedCount(c); players != 0 || keys != 0 {
+		t.Fatalf("cached %d players and %d keys after reset", players, keys)
+	}
+}
+
+func TestPlayerCacheGetMany(t *testing.T) {
+	c := newPlayerCache(time.Minute, 100)
+	c.put(newCachedPlayer(100001))
+	c.put(newCachedPlayer(100003))
+	expireCached(c, 100003)
+
+	hits, missing := c.getMany([]Player{100001, 100002, 100003})
+	if len(hits) != 1 || hits[100001] == nil || hits[100001].Diamonds != 100001 {
+		t.Fatalf("hits = %v", hits)
+	}
+	if len(missing) != 2 || missing[0] != 100002 || missing[1] != 100003 {
+		t.Fatalf("missing = %v", missing)
+	}
+}
+
+func createTestChange(t *testing.T, s *gormStore, id int64, player Player) {
+	if err := s.db.Create(&PlayerChangeData{Id: id, Player: player, CreatedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 主键较小的通知在较大的主键读取之后才提交时仍然删除缓存, 已处理的通知不重复删除
+func TestPollChangesOutOfOrder(t *testing.T) {
+	db := openTestGorm(t)
+	if err := migrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	s := &gormStore{db: db}
+
+	cache.reset()
+	defer cache.reset()
+	for _, id := range []Player{100001, 100002, 100003} {
+		cache.put(newCachedPlayer(id))
+	}
+
+	// 事务 A 先分配了主键 1, 事务 B 分配主键 2 后先提交
+	createTestChange(t, s, 2, 100002)
+	cursor := newChangeCursor(0)
+	if err := pollChanges(s, cursor); err != nil {
+		t.Fatal(err)
+	}
+	if _, being := cache.get(100002); being {
+		t.Fatal("changed player still cached")
+	}
+	if cursor.After != 2 {
+		t.Fatalf("after = %d, want 2", cursor.After)
+	}
+
+	// 玩家重新加载后事务 A 才提交
+	cache.put(newCachedPlayer(100002))
+	createTestChange(t, s, 1, 100001)
+	if err := pollChanges(s, cursor); err != nil {
+		t.Fatal(err)
+	}
+	if _, being := cache.get(100001); being {
+		t.Fatal("late committed change skipped")
+	}
+	if _, being := cache.get(100002); !being {
+		t.Fatal("handled change removed player again")
+	}
+	if _, being := cache.get(100003); !being {
+		t.Fatal("unchanged player removed")
+	}
+	if cursor.After != 2 {
+		t.Fatalf("after = %d, want 2", cursor.After)
+	}
+
+	// 移出窗口的通知不再保留
+	createTestChange(t, s, 2+changeWindow, 100003)
+	if err := pollChanges(s, cursor); err != nil {
+		t.Fatal(err)
+	}
+	if _, being := cache.get(100003); being {
+		t.Fatal("changed player still cached")
+	}
+	if cursor.Seen[1] || cursor.Seen[2] || !cursor.Seen[2+changeWindow] {
+		t.Fatalf("seen %v", cursor.Seen)
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+const benchmarkPlayers = 10000
+
+func newBenchmarkCache() *playerCache {
+	c := newPlayerCache(time.Hour, benchmarkPlayers*2)
+	for i := 0; i < benchmarkPlayers; i++ {
+		c.put(newCachedPlayer(Player(100000 + i)))
+	}
+	return c
+}
+
+func BenchmarkPlayerCacheGet(b *testing.B) {
+	c := newBenchmarkCache()
+	var seed int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := atomic.AddInt64(&seed, 7919)
+		for pb.Next() {
+			i++
+			if _, being := c.get(Player(100000 + i%benchmarkPlayers)); !being {
+				b.Error("player missing")
+				return
+			}
+		}
+	})
+}
+
+// 大厅广播时按房间内的玩家批量查询
+func BenchmarkPlayerCacheGetMany(b *testing.B) {
+	c := newBenchmarkCache()
+	var seed int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := atomic.AddInt64(&seed, 7919)
+		ids := make([]Player, 8)
+		for pb.Next() {
+			i++
+			for j := range ids {
+				ids[j] = Player(100000 + (i*8+int64(j))%benchmarkPlayers)
+			}
+			if _, missing := c.getMany(ids); len(missing) > 0 {
+				b.Error("players missing")
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkPlayerCachePut(b *testing.B) {
+	c := newBenchmarkCache()
+	players := make([]*PlayerData, benchmarkPlayers)
+	for i := range players {
+		players[i] = newCachedPlayer(Player(100000 + i))
+	}
+	var seed int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := atomic.AddInt64(&seed, 7919)
+		for pb.Next() {
+			i++
+			c.put(players[i%benchmarkPlayers])
+		}
+	})
+}
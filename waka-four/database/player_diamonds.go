@@ -1,7 +1,6 @@
 package database
 
 import (
-	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 )
 
@@ -11,27 +10,27 @@ var (
 
 type modifyDiamondsAction struct {
 	Player Player
-	Number int32
-	Before func(ts *gorm.DB, self *modifyDiamondsAction) error
-	After  func(ts *gorm.DB, self *modifyDiamondsAction) error
+	Number int64
+	Before func(s Store, self *modifyDiamondsAction) error
+	After  func(s Store, self *modifyDiamondsAction) error
 }
 
-func applyModifyDiamondsAction(ts *gorm.DB, modifies []*modifyDiamondsAction) error {
+func applyModifyDiamondsAction(s Store, modifies []*modifyDiamondsAction) error {
 	for _, modify := range modifies {
 		zeroCheck := false
 		if modify.Number < 0 {
 			zeroCheck = true
 		}
 		if modify.Before != nil {
-			if err := modify.Before(ts, modify); err != nil {
+			if err := modify.Before(s, modify); err != nil {
 				return err
 			}
 		}
-		if err := modifyDiamonds(ts, modify.Player, modify.Number, zeroCheck); err != nil {
+		if err := modifyDiamonds(s, modify.Player, modify.Number, zeroCheck); err != nil {
 			return err
 		}
 		if modify.After != nil {
-			if err := modify.After(ts, modify); err != nil {
+			if err := modify.After(s, modify); err != nil {
 				return err
 			}
 		}
@@ -40,7 +39,7 @@ func applyModifyDiamondsAction(ts *gorm.DB, modifies []*modifyDiamondsAction) er
 	return nil
 }
 
-func modifyDiamonds(ts *gorm.DB, player Player, diamonds int32, zeroCheck bool) error {
+func modifyDiamonds(s Store, player Player, diamonds int64, zeroCheck bool) error {
 	if diamonds == 0 {
 		return nil
 	}
@@ -48,22 +47,19 @@ func modifyDiamonds(ts *gorm.DB, player Player, diamonds int32, zeroCheck bool)
 		return nil
 	}
 
-	if err := ts.Model(&PlayerData{}).Where("id = ?", player).Updates(
-		map[string]interface{}{
-			"diamonds": gorm.Expr("diamonds + ?", diamonds),
-		},
-	).Error; err != nil {
+	if err := s.Players().AddDiamonds(player, diamonds); err != nil {
 		return err
 	}
 	if zeroCheck {
-		player := PlayerData{
-			Id: player,
-		}
-		if err := ts.First(&player).Error; err != nil {
+		playerData, err := s.Players().Find(player)
+		if err != nil {
 			return err
 		}
+		if playerData == nil {
+			return ErrPlayerNotFound
+		}
 
-		if player.Diamonds < 0 {
+		if playerData.Diamonds < 0 {
 			return ErrDiamondsNotEnough
 		}
 	}
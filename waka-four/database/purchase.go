@@ -2,14 +2,12 @@ package database
 
 import (
 	"time"
-
-	"github.com/jinzhu/gorm"
 )
 
 // 场费结算数据
 type FourPlayerRoomCost struct {
 	Player Player
-	Number int32
+	Number int64
 }
 
 // 四张约战房间场费结算
@@ -23,14 +21,14 @@ func FourOrderRoomSettle(room int32, players []*FourPlayerRoomCost) error {
 		modifies = append(modifies, &modifyDiamondsAction{
 			Player: player.Player,
 			Number: player.Number * (-1),
-			After: func(ts *gorm.DB, self *modifyDiamondsAction) error {
+			After: func(s Store, self *modifyDiamondsAction) error {
 				consume := &FourOrderRoomPurchaseHistory{
 					Player:    player.Player,
 					Room:      room,
 					Number:    player.Number,
 					CreatedAt: time.Now(),
 				}
-				if err := ts.Create(consume).Error; err != nil {
+				if err := s.Purchases().CreateOrderRoom(consume); err != nil {
 					return err
 				}
 				return nil
@@ -39,26 +37,16 @@ func FourOrderRoomSettle(room int32, players []*FourPlayerRoomCost) error {
 		changed = append(changed, player.Player)
 	}
 
-	ts := mysql.Begin()
-
-	err := applyModifyDiamondsAction(ts, modifies)
+	err := store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionID, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
+		cache.remove(player)
 	}
-	playersLock.Unlock()
 
 	return nil
 }
@@ -71,14 +59,14 @@ func FourPayForAnotherRoomSettle(room int32, player *FourPlayerRoomCost) error {
 	modifies = append(modifies, &modifyDiamondsAction{
 		Player: player.Player,
 		Number: player.Number * (-1),
-		After: func(ts *gorm.DB, self *modifyDiamondsAction) error {
+		After: func(s Store, self *modifyDiamondsAction) error {
 			consume := &FourPayForAnotherRoomPurchaseHistory{
 				Player:    player.Player,
 				Room:      room,
 				Number:    player.Number,
 				CreatedAt: time.Now(),
 			}
-			if err := ts.Create(consume).Error; err != nil {
+			if err := s.Purchases().CreatePayForAnotherRoom(consume); err != nil {
 				return err
 			}
 			return nil
@@ -86,26 +74,16 @@ func FourPayForAnotherRoomSettle(room int32, player *FourPlayerRoomCost) error {
 	})
 	changed = append(changed, player.Player)
 
-	ts := mysql.Begin()
-
-	err := applyModifyDiamondsAction(ts, modifies)
+	err := store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionID, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
+		cache.remove(player)
 	}
-	playersLock.Unlock()
 
 	return nil
 }
@@ -121,14 +99,14 @@ func FourAARoomSettle(room int32, players []*FourPlayerRoomCost) error {
 		modifies = append(modifies, &modifyDiamondsAction{
 			Player: player.Player,
 			Number: player.Number * (-1),
-			After: func(ts *gorm.DB, self *modifyDiamondsAction) error {
+			After: func(s Store, self *modifyDiamondsAction) error {
 				consume := &FourOrderRoomPurchaseHistory{
 					Player:    player.Player,
 					Room:      room,
 					Number:    player.Number,
 					CreatedAt: time.Now(),
 				}
-				if err := ts.Create(consume).Error; err != nil {
+				if err := s.Purchases().CreateOrderRoom(consume); err != nil {
 					return err
 				}
 				return nil
@@ -137,26 +115,16 @@ func FourAARoomSettle(room int32, players []*FourPlayerRoomCost) error {
 		changed = append(changed, player.Player)
 	}
 
-	ts := mysql.Begin()
-
-	err := applyModifyDiamondsAction(ts, modifies)
+	err := store.Transaction(func(s Store) error {
+		return applyModifyDiamondsAction(s, modifies)
+	})
 	if err != nil {
-		ts.Rollback()
 		return err
 	}
 
-	ts.Commit()
-
-	playersLock.Lock()
 	for _, player := range changed {
-		playerData, being := playersByPlayer[player]
-		if being {
-			delete(playersByPlayer, player)
-			delete(playersByUnionID, playerData.UnionId)
-			delete(playersByToken, playerData.Token)
-		}
+		cache.remove(player)
 	}
-	playersLock.Unlock()
 
 	return nil
-}
\ No newline at end of file
+}
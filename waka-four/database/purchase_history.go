@@ -11,7 +11,7 @@ type FourOrderRoomPurchaseHistory struct {
 	// 房间 ID
 	Room int32
 	// 金币数
-	Number int32
+	Number int64
 	// 时间
 	CreatedAt time.Time
 }
@@ -27,7 +27,7 @@ type FourPayForAnotherRoomPurchaseHistory struct {
 	// 房间 ID
 	Room int32
 	// 金币数
-	Number int32
+	Number int64
 	// 时间
 	CreatedAt time.Time
 }
@@ -0,0 +1,411 @@
+package database
+
+import (
+	"time"
+
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const (
+	// MySQL 驱动
+	DriverMySQL = "mysql"
+	// SQLite 驱动, 需要 cgo, Name 为数据库文件路径, 为 MemorySource 时使用内存数据库
+	DriverSQLite = "sqlite3"
+
+	// SQLite 内存数据库
+	MemorySource = ":memory:"
+)
+
+// 基于 gorm 的存储, 支持 MySQL 与 SQLite
+type gormStore struct {
+	db *gorm.DB
+	ts bool
+}
+
+// 按选项打开 gorm 存储, 需要时重建所有表并执行迁移
+func openGormStore(option Option) (*gormStore, error) {
+	db, err := openGorm(option)
+	if err != nil {
+		return nil, err
+	}
+
+	if option.Reset || option.memory() {
+		if err := resetTables(db, option.Production); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if option.Reset || option.Migrate || option.memory() {
+		if err := migrateUp(db, 0); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &gormStore{db: db}, nil
+}
+
+// 按选项连接数据库
+func openGorm(option Option) (*gorm.DB, error) {
+	driver, source := option.Driver, ""
+	switch driver {
+	case "", DriverMySQL:
+		driver = DriverMySQL
+		source = fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local`,
+			option.User, option.Password, option.Host, option.Name)
+	case DriverSQLite:
+		source = option.Name
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	db, err := gorm.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if option.memory() {
+		// 每个连接都是独立的内存数据库
+		db.DB().SetMaxOpenConns(1)
+	}
+	db.LogMode(option.EnableLog)
+
+	return db, nil
+}
+
+func (s *gormStore) Players() PlayerRepository {
+	return gormPlayers{s.db}
+}
+
+func (s *gormStore) PlayerChanges() PlayerChangeRepository {
+	return gormPlayerChanges{s.db}
+}
+
+func (s *gormStore) Purchases() PurchaseRepository {
+	return gormPurchases{s.db}
+}
+
+func (s *gormStore) Histories() HistoryRepository {
+	return gormHistories{s.db}
+}
+
+func (s *gormStore) Archives() ArchiveRepository {
+	return gormArchives{s.db}
+}
+
+func (s *gormStore) Configurations() ConfigurationRepository {
+	return gormConfigurations{s.db}
+}
+
+func (s *gormStore) Friends() FriendRepository {
+	return gormFriends{s.db}
+}
+
+func (s *gormStore) Transaction(fn func(store Store) error) error {
+	if s.ts {
+		return fn(s)
+	}
+
+	ts := s.db.Begin()
+	if ts.Error != nil {
+		return ts.Error
+	}
+
+	if err := fn(&gormStore{db: ts, ts: true}); err != nil {
+		ts.Rollback()
+		return err
+	}
+
+	return ts.Commit().Error
+}
+
+func (s *gormStore) Close() error {
+	return s.db.Close()
+}
+
+// in 查询每批的最大参数个数
+const batchSize = 500
+
+// 查询单条记录, 不存在时返回 false
+func first(db *gorm.DB, out interface{}) (bool, error) {
+	if err := db.First(out).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormPlayers struct {
+	db *gorm.DB
+}
+
+func (r gormPlayers) Create(player *PlayerData) error {
+	return r.db.Create(player).Error
+}
+
+func (r gormPlayers) Find(id Player) (*PlayerData, error) {
+	return r.find(r.db.Where("id = ?", id))
+}
+
+func (r gormPlayers) FindByToken(token string) (*PlayerData, error) {
+	return r.find(r.db.Where("token = ?", token))
+}
+
+func (r gormPlayers) FindByUnionID(unionID string) (*PlayerData, error) {
+	return r.find(r.db.Where("union_id = ?", unionID))
+}
+
+func (r gormPlayers) find(db *gorm.DB) (*PlayerData, error) {
+	player := &PlayerData{}
+	being, err := first(db, player)
+	if err != nil || !being {
+		return nil, err
+	}
+	return player, nil
+}
+
+func (r gormPlayers) Update(id Player, fields *PlayerData) error {
+	return r.db.Model(&PlayerData{}).Where("id = ?", id).Updates(fields).Error
+}
+
+func (r gormPlayers) AddDiamonds(id Player, number int64) error {
+	return r.db.Model(&PlayerData{}).Where("id = ?", id).Updates(
+		map[string]interface{}{
+			"diamonds": gorm.Expr("diamonds + ?", number),
+		},
+	).Error
+}
+
+func (r gormPlayers) FindIn(ids []Player) ([]*PlayerData, error) {
+	var players []*PlayerData
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > batchSize {
+			n = batchSize
+		}
+		var batch []*PlayerData
+		if err := r.db.Where("id in (?)", ids[:n]).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		players = append(players, batch...)
+		ids = ids[n:]
+	}
+	return players, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormPlayerChanges struct {
+	db *gorm.DB
+}
+
+func (r gormPlayerChanges) Last() (*PlayerChangeData, error) {
+	change := &PlayerChangeData{}
+	being, err := first(r.db.Order("id desc"), change)
+	if err != nil || !being {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (r gormPlayerChanges) FindAfter(after int64, limit int32) ([]*PlayerChangeData, error) {
+	var changes []*PlayerChangeData
+	if err := r.db.Where("id > ?", after).Order("id").Limit(limit).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r gormPlayerChanges) DeleteBefore(before time.Time) error {
+	return r.db.Where("created_at < ?", before).Delete(PlayerChangeData{}).Error
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormPurchases struct {
+	db *gorm.DB
+}
+
+func (r gormPurchases) CreateOrderRoom(history *FourOrderRoomPurchaseHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormPurchases) CreatePayForAnotherRoom(history *FourPayForAnotherRoomPurchaseHistory) error {
+	return r.db.Create(history).Error
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormHistories struct {
+	db *gorm.DB
+}
+
+func (r gormHistories) CreateWar(history *FourWarHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r gormHistories) FindWar(player Player, cursor *HistoryCursor, limit int32) ([]*FourWarHistory, error) {
+	var d []*FourWarHistory
+	db := r.db.Where("player = ?", player)
+	if cursor != nil {
+		db = db.Where("created_at < ? or (created_at = ? and id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.Id)
+	}
+	if err := db.Order("created_at desc").Order("id desc").Limit(limit).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormArchives struct {
+	db *gorm.DB
+}
+
+func (r gormArchives) FindExpired(out interface{}, before time.Time, limit int32) error {
+	return r.db.Where("created_at < ?", before).Order("created_at").Order("id").Limit(limit).Find(out).Error
+}
+
+func (r gormArchives) DeleteArchived(model interface{}, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Where("id in (?)", ids).Delete(model).Error
+}
+
+func (r gormArchives) Create(archive *HistoryArchiveData) error {
+	return r.db.Create(archive).Error
+}
+
+func (r gormArchives) Find(source string, id int64) (*HistoryArchiveData, error) {
+	archive := &HistoryArchiveData{}
+	being, err := first(r.db.Where("source = ? and source_id = ?", source, id), archive)
+	if err != nil || !being {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (r gormArchives) DeleteBefore(before time.Time) (int64, error) {
+	db := r.db.Where("archived_at < ?", before).Delete(new(HistoryArchiveData))
+	return db.RowsAffected, db.Error
+}
+
+func (r gormArchives) AddSummary(summary *HistorySummaryData) error {
+	existing := &HistorySummaryData{}
+	being, err := first(r.db.Where("player = ? and source = ? and mode = ? and day = ?",
+		summary.Player, summary.Source, summary.Mode, summary.Day), existing)
+	if err != nil {
+		return err
+	}
+	if !being {
+		return r.db.Create(summary).Error
+	}
+	return r.db.Model(existing).UpdateColumns(map[string]interface{}{
+		"total":  gorm.Expr("total + ?", summary.Total),
+		"number": gorm.Expr("number + ?", summary.Number),
+	}).Error
+}
+
+func (r gormArchives) FindSummaries(player Player, from, to time.Time) ([]*HistorySummaryData, error) {
+	var d []*HistorySummaryData
+	if err := r.db.Where("player = ? and day >= ? and day < ?", player, from, to).Order("day").Order("source").Order("mode").Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormConfigurations struct {
+	db *gorm.DB
+}
+
+func (r gormConfigurations) Find(configurationType string) ([]*Configuration, error) {
+	var values []*Configuration
+	if err := r.db.Where("type = ?", configurationType).Find(&values).Error; err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type gormFriends struct {
+	db *gorm.DB
+}
+
+func (r gormFriends) FindFriends(player Player, ban bool) ([]*FriendData, error) {
+	var d []*FriendData
+	if err := r.db.Where("player = ? and ban = ?", player, ban).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormFriends) FindFriend(player, friend Player) (*FriendData, error) {
+	d := &FriendData{}
+	being, err := first(r.db.Where("player = ? and friend = ?", player, friend), d)
+	if err != nil || !being {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormFriends) CreateFriend(friend *FriendData) error {
+	return r.db.Create(friend).Error
+}
+
+func (r gormFriends) UpdateBan(player, friend Player, ban bool) error {
+	return r.db.Model(new(FriendData)).Where("player = ? and friend = ?", player, friend).Updates(map[string]interface{}{
+		"ban": ban,
+	}).Error
+}
+
+func (r gormFriends) CreateAsk(ask *AskData) error {
+	return r.db.Create(ask).Error
+}
+
+func (r gormFriends) FindAsk(id int32) (*AskData, error) {
+	d := &AskData{}
+	being, err := first(r.db.Where("id = ?", id), d)
+	if err != nil || !being {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r gormFriends) SaveAsk(ask *AskData) error {
+	return r.db.Save(ask).Error
+}
+
+func (r gormFriends) FindAsksBySender(sender Player, dealt bool, limit int32) ([]*AskData, error) {
+	return r.findAsks(r.db.Where("sender = ?", sender), dealt, limit)
+}
+
+func (r gormFriends) FindAsksByPlayer(player Player, dealt bool, limit int32) ([]*AskData, error) {
+	return r.findAsks(r.db.Where("player = ?", player), dealt, limit)
+}
+
+func (r gormFriends) findAsks(db *gorm.DB, dealt bool, limit int32) ([]*AskData, error) {
+	if dealt {
+		db = db.Where("status <> ?", 0)
+	} else {
+		db = db.Where("status = ?", 0)
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	var d []*AskData
+	if err := db.Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
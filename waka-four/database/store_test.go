@@ -0,0 +1,218 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/liuhan907/waka/waka-four/proto"
+)
+
+func TestRegisterAndQueryPlayer(t *testing.T) {
+	openTestStore(t)
+
+	player, err := RegisterPlayer("unionid", "nickname", "head", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.Id <= systemPlayer || player.Diamonds != int64(GetSettings().Hall.RegisterDiamonds) {
+		t.Fatalf("registered %+v", player)
+	}
+
+	byToken, being, err := QueryPlayerByToken("token")
+	if err != nil || !being || byToken.Id != player.Id {
+		t.Fatalf("query by token = %+v, %v, %v", byToken, being, err)
+	}
+	byWechat, being, err := QueryPlayerByWechatUID("unionid")
+	if err != nil || !being || byWechat.Id != player.Id {
+		t.Fatalf("query by unionid = %+v, %v, %v", byWechat, being, err)
+	}
+	if _, being, err := QueryPlayerByToken("missing"); err != nil || being {
+		t.Fatalf("query missing token = %v, %v", being, err)
+	}
+
+	// 重新登录后旧令牌失效
+	if err := UpdatePlayerLogin(player.Id, "renamed", "head", "renewed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, being, err := QueryPlayerByToken("token"); err != nil || being {
+		t.Fatalf("query old token = %v, %v", being, err)
+	}
+	if renewed, being, err := QueryPlayerByToken("renewed"); err != nil || !being || renewed.Nickname != "renamed" {
+		t.Fatalf("query renewed token = %+v, %v, %v", renewed, being, err)
+	}
+
+	if err := UpdatePlayerSupervisor(player.Id, systemPlayer); err != nil {
+		t.Fatal(err)
+	}
+	players, err := QueryPlayers([]Player{player.Id, systemPlayer, player.Id + 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 2 || players[player.Id] == nil || players[systemPlayer] == nil {
+		t.Fatalf("query players = %v", players)
+	}
+	if players[player.Id].Supervisor != systemPlayer {
+		t.Fatalf("supervisor = %d, want %d", players[player.Id].Supervisor, systemPlayer)
+	}
+}
+
+// 每天只有第一次分享送钻石
+func TestPlayerShared(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 0)
+
+	number, err := PlayerShared(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number != GetSettings().Hall.ShareDiamonds || testDiamonds(t, player) != int64(number) {
+		t.Fatalf("shared %d, diamonds %d", number, testDiamonds(t, player))
+	}
+
+	number, err = PlayerShared(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number != 0 || testDiamonds(t, player) != int64(GetSettings().Hall.ShareDiamonds) {
+		t.Fatalf("shared again %d, diamonds %d", number, testDiamonds(t, player))
+	}
+
+	if _, err := PlayerShared(player + 1000); err != ErrPlayerNotFound {
+		t.Fatalf("share missing player: %v", err)
+	}
+}
+
+func TestFourOrderRoomSettle(t *testing.T) {
+	openTestStore(t)
+	a := createTestPlayer(t, 100)
+	b := createTestPlayer(t, 100)
+
+	if err := FourOrderRoomSettle(7, []*FourPlayerRoomCost{{Player: a, Number: 30}, {Player: b, Number: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	// AA 房间同样记为约战消费
+	if err := FourAARoomSettle(8, []*FourPlayerRoomCost{{Player: a, Number: 10}, {Player: b, Number: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if diamonds := testDiamonds(t, a); diamonds != 60 {
+		t.Errorf("a diamonds = %d, want 60", diamonds)
+	}
+	if diamonds := testDiamonds(t, b); diamonds != 40 {
+		t.Errorf("b diamonds = %d, want 40", diamonds)
+	}
+
+	var histories []*FourOrderRoomPurchaseHistory
+	findTestRows(t, &histories, "room = ?", 7)
+	if len(histories) != 2 ||
+		histories[0].Player != a || histories[0].Number != 30 ||
+		histories[1].Player != b || histories[1].Number != 50 {
+		t.Fatalf("purchase histories %+v", histories)
+	}
+	histories = nil
+	findTestRows(t, &histories, "room = ?", 8)
+	if len(histories) != 2 {
+		t.Fatalf("aa purchase histories %+v", histories)
+	}
+
+	// 任一玩家的钻石不够时整个结算回滚
+	err := FourOrderRoomSettle(9, []*FourPlayerRoomCost{{Player: a, Number: 10}, {Player: b, Number: 41}})
+	if err != ErrDiamondsNotEnough {
+		t.Fatalf("settle with diamonds not enough: %v", err)
+	}
+	if diamonds := testDiamonds(t, a); diamonds != 60 {
+		t.Errorf("a diamonds after rollback = %d, want 60", diamonds)
+	}
+	if diamonds := testDiamonds(t, b); diamonds != 40 {
+		t.Errorf("b diamonds after rollback = %d, want 40", diamonds)
+	}
+	histories = nil
+	findTestRows(t, &histories, "room = ?", 9)
+	if len(histories) != 0 {
+		t.Fatalf("purchase histories after rollback %+v", histories)
+	}
+}
+
+func TestFourPayForAnotherRoomSettle(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 100)
+
+	if err := FourPayForAnotherRoomSettle(7, &FourPlayerRoomCost{Player: player, Number: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if diamonds := testDiamonds(t, player); diamonds != 0 {
+		t.Errorf("diamonds = %d, want 0", diamonds)
+	}
+	if err := FourPayForAnotherRoomSettle(8, &FourPlayerRoomCost{Player: player, Number: 1}); err != ErrDiamondsNotEnough {
+		t.Fatalf("settle with diamonds not enough: %v", err)
+	}
+	if diamonds := testDiamonds(t, player); diamonds != 0 {
+		t.Errorf("diamonds after rollback = %d, want 0", diamonds)
+	}
+
+	var histories []*FourPayForAnotherRoomPurchaseHistory
+	findTestRows(t, &histories, "player = ?", player)
+	if len(histories) != 1 || histories[0].Room != 7 || histories[0].Number != 100 {
+		t.Fatalf("purchase histories %+v", histories)
+	}
+}
+
+func TestFourWarHistory(t *testing.T) {
+	openTestStore(t)
+	player := createTestPlayer(t, 0)
+	other := createTestPlayer(t, 0)
+
+	finally := func(score int32) *four_proto.FourFinallySettle {
+		return &four_proto.FourFinallySettle{
+			Players: []*four_proto.FourFinallySettle_Player{
+				{PlayerId: int32(player), Score: score, VictoryNumber: 1},
+				{PlayerId: int32(other), Score: -score},
+			},
+		}
+	}
+	for room := int32(1); room <= 5; room++ {
+		add := FourAddOrderRoomWarHistory
+		if room%2 == 0 {
+			add = FourAddPayForAnotherRoomWarHistory
+		}
+		if err := add(player, room, finally(room*10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := FourAddAARoomWarHistory(other, 100, finally(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 从新到旧翻页, 最后一页不足一页时游标为 nil
+	var rooms []int32
+	var cursor *HistoryCursor
+	for page := 0; ; page++ {
+		histories, next, err := FourQueryWarHistory(player, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, history := range histories {
+			if history.Type != (history.RoomId+1)%2 ||
+				len(history.Finally.GetPlayers()) != 2 || history.Finally.Players[0].Score != history.RoomId*10 {
+				t.Fatalf("history %+v", history)
+			}
+			rooms = append(rooms, history.RoomId)
+		}
+		if next == nil {
+			break
+		}
+		if page > 5 {
+			t.Fatal("too many pages")
+		}
+		cursor = next
+	}
+	if len(rooms) != 5 || rooms[0] != 5 || rooms[4] != 1 {
+		t.Fatalf("rooms %v, want [5 4 3 2 1]", rooms)
+	}
+
+	histories, next, err := FourQueryWarHistory(other, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(histories) != 1 || histories[0].RoomId != 100 || histories[0].Type != 0 || next != nil {
+		t.Fatalf("other histories %+v, %v", histories, next)
+	}
+}
@@ -7,6 +7,52 @@ import (
 	"github.com/liuhan907/waka/waka-four/proto"
 )
 
+const (
+	// 每页最多的战绩数
+	maxHistoryLimit = 50
+)
+
+// 战绩分页游标, 按 (created_at, id) 从新到旧翻页, 为 nil 时从最新的记录开始
+type HistoryCursor struct {
+	CreatedAt time.Time
+	Id        int64
+}
+
+// 从客户端传入的游标转换, 为空时返回 nil
+func HistoryCursorFromProto(pb *four_proto.HistoryCursor) *HistoryCursor {
+	if pb == nil || (pb.CreatedAt == 0 && pb.Id == 0) {
+		return nil
+	}
+	return &HistoryCursor{
+		CreatedAt: time.Unix(0, pb.CreatedAt),
+		Id:        pb.Id,
+	}
+}
+
+// 转换为返回给客户端的游标, nil 表示没有更多记录
+func (cursor *HistoryCursor) Proto() *four_proto.HistoryCursor {
+	if cursor == nil {
+		return nil
+	}
+	return &four_proto.HistoryCursor{
+		CreatedAt: cursor.CreatedAt.UnixNano(),
+		Id:        cursor.Id,
+	}
+}
+
+// 每页的数量, 为 0 时使用默认数量, 不超过 maxHistoryLimit
+func historyLimit(limit, defaultLimit int32) int32 {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxHistoryLimit {
+		return maxHistoryLimit
+	}
+	return limit
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
 type FourWarHistory struct {
 	// 主键
 	Id int32 `gorm:"index;primary_key;AUTO_INCREMENT"`
@@ -22,21 +68,26 @@ type FourWarHistory struct {
 	CreatedAt time.Time
 }
 
-// 查询四张战绩
-func FourQueryWarHistory(player Player, limit int32) ([]*four_proto.FourWarHistory, error) {
-	var d []*FourWarHistory
-	if err := mysql.Where("player = ?", player).Order("created_at desc").Limit(limit).Find(&d).Error; err != nil {
-		return nil, err
+// 查询四张战绩, 同时返回下一页的游标
+func FourQueryWarHistory(player Player, cursor *HistoryCursor, limit int32) ([]*four_proto.FourWarHistory, *HistoryCursor, error) {
+	limit = historyLimit(limit, 20)
+	d, err := store.Histories().FindWar(player, cursor, limit)
+	if err != nil {
+		return nil, nil, err
 	}
 	var x []*four_proto.FourWarHistory
 	for _, v := range d {
 		k := &four_proto.FourWarHistory{}
 		if err := proto.Unmarshal(v.Payload, k); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		x = append(x, k)
 	}
-	return x, nil
+	if len(d) < int(limit) {
+		return x, nil, nil
+	}
+	last := d[len(d)-1]
+	return x, &HistoryCursor{CreatedAt: last.CreatedAt, Id: int64(last.Id)}, nil
 }
 
 // 添加四张约战场战绩
@@ -66,12 +117,12 @@ func fourAddRoomWarHistory(mode int32, player Player, room int32, finally *four_
 	if err != nil {
 		return err
 	}
-	if err := mysql.Create(&FourWarHistory{
+	if err := store.Histories().CreateWar(&FourWarHistory{
 		Mode:      mode,
 		Player:    player,
 		Payload:   d,
 		CreatedAt: time.Now(),
-	}).Error; err != nil {
+	}); err != nil {
 		return err
 	}
 	return nil
@@ -1,18 +1,14 @@
 package log
 
 import (
-	"github.com/sirupsen/logrus"
-
 	"github.com/liuhan907/waka/waka/modules/logger"
 )
 
 func init() {
 	loggerOption := logger.Option{
-		Prefix: "four",
+		Prefix:  "four",
+		Console: true,
 	}
-	viewer := logger.Spawn(loggerOption)
 
-	logrus.AddHook(&logger.LogHook{
-		Target: viewer,
-	})
+	logger.Install(loggerOption)
 }
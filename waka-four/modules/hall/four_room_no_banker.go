@@ -408,7 +408,7 @@ func (r *fourNoBankerRoomT) CreateRoom(hall *actorT, id int32, option *four_prot
 		r.King = append(r.King, creator)
 	}
 
-	if creator.PlayerData().Diamonds < r.CreateDiamonds() {
+	if creator.PlayerData().Diamonds < int64(r.CreateDiamonds()) {
 		r.Hall.sendFourCreateRoomFailed(creator, 1)
 		return nil
 	} else {
@@ -441,7 +441,7 @@ func (r *fourNoBankerRoomT) JoinRoom(player *playerT) {
 		return
 	}
 
-	if player.Player.PlayerData().Diamonds < r.EnterDiamonds() {
+	if player.Player.PlayerData().Diamonds < int64(r.EnterDiamonds()) {
 		r.Hall.sendFourJoinRoomFailed(player.Player, 2)
 		return
 	}
@@ -548,19 +548,19 @@ func (r *fourNoBankerRoomT) Start(player *playerT) {
 			if r.Option.GetPayMode() == 1 {
 				playerRoomCost = append(playerRoomCost, &database.FourPlayerRoomCost{
 					Player: r.Owner,
-					Number: r.CostDiamonds(),
+					Number: int64(r.CostDiamonds()),
 				})
 			} else if r.Option.GetPayMode() == 2 {
 				for _, player := range r.Players {
 					playerRoomCost = append(playerRoomCost, &database.FourPlayerRoomCost{
 						Player: player.Player,
-						Number: r.CostDiamonds(),
+						Number: int64(r.CostDiamonds()),
 					})
 				}
 			} else if r.Option.GetPayMode() == 3 {
 				playerRoomCost = append(playerRoomCost, &database.FourPlayerRoomCost{
 					Player: r.Owner,
-					Number: r.CostDiamonds(),
+					Number: int64(r.CostDiamonds()),
 				})
 			}
 			var err error
@@ -3,60 +3,29 @@ package hall
 import (
 	"sort"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/liuhan907/waka/waka-four/database"
 	"github.com/liuhan907/waka/waka-four/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"gopkg.in/ahmetb/go-linq.v3"
 )
 
-func (my *actorT) playerFutureRequestedFour(player *playerT, ev *supervisor_message.PlayerFutureRequested) bool {
-	switch evd := ev.Payload.(type) {
-	case *four_proto.PullPlayerRequest:
-		my.PullPlayerRequest(player, evd, ev.Respond)
-	case *four_proto.PullPlayerSecretRequest:
-		my.PullPlayerSecretRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullFriendsListRequest:
-		my.FourPullFriendsListRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullWantListRequest:
-		my.FourPullWantListRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullAskListRequest:
-		my.FourPullAskListRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullBanListRequest:
-		my.FourPullBanListRequest(player, evd, ev.Respond)
-	case *four_proto.FourBanFriendRequest:
-		my.FourBanFriendRequest(player, evd, ev.Respond)
-	case *four_proto.FourWantFriendRequest:
-		my.FourWantFriendRequest(player, evd, ev.Respond)
-	case *four_proto.FourBecomeFriendRequest:
-		my.FourBecomeFriendRequest(player, evd, ev.Respond)
-	case *four_proto.FourCancelBanFriendRequest:
-		my.FourCancelBanFriendRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullPayForAnotherRoomListRequest:
-		my.FourPullPayForAnotherRoomListRequest(player, evd, ev.Respond)
-	case *four_proto.FourPullWarHistoryListRequest:
-		my.FourPullWarHistoryListRequest(player, evd, ev.Respond)
-	default:
-		return false
-	}
-	return true
-}
-
-func (my *actorT) PullPlayerRequest(player *playerT,
+func (my *actorT) PullPlayerRequest(playerId uint64,
 	ev *four_proto.PullPlayerRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.PullPlayerResponse, error)) {
 	respond(&four_proto.PullPlayerResponse{my.ToPlayer(database.Player(ev.GetPlayerId()))}, nil)
 }
 
-func (my *actorT) PullPlayerSecretRequest(player *playerT,
+func (my *actorT) PullPlayerSecretRequest(playerId uint64,
 	ev *four_proto.PullPlayerSecretRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.PullPlayerSecretResponse, error)) {
+	player := my.players[database.Player(playerId)]
+
 	respond(&four_proto.PullPlayerSecretResponse{my.ToPlayerSecret(database.Player(player.Player))}, nil)
 }
 
-func (my *actorT) FourPullFriendsListRequest(player *playerT,
+func (my *actorT) FourPullFriendsListRequest(playerId uint64,
 	ev *four_proto.FourPullFriendsListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullFriendsListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	friends, err := database.QueryFriendList(player.Player)
 	if err != nil {
@@ -64,6 +33,12 @@ func (my *actorT) FourPullFriendsListRequest(player *playerT,
 		return
 	}
 
+	ids := make([]database.Player, 0, len(friends))
+	for _, x := range friends {
+		ids = append(ids, x.Friend)
+	}
+	data := queryPlayers(ids)
+
 	var d []*four_proto.FourPullFriendsListResponse_FourFriend
 	linq.From(friends).SelectT(func(x *database.FriendData) *four_proto.FourPullFriendsListResponse_FourFriend {
 		online := true
@@ -72,7 +47,7 @@ func (my *actorT) FourPullFriendsListRequest(player *playerT,
 		}
 		return &four_proto.FourPullFriendsListResponse_FourFriend{
 			PlayerId: int32(x.Friend),
-			Nickname: x.Friend.PlayerData().Nickname,
+			Nickname: data[x.Friend].Nickname,
 			Online:   online,
 		}
 	}).ToSlice(&d)
@@ -82,9 +57,10 @@ func (my *actorT) FourPullFriendsListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) FourPullWantListRequest(player *playerT,
+func (my *actorT) FourPullWantListRequest(playerId uint64,
 	ev *four_proto.FourPullWantListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullWantListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	wants, err := database.QueryWantListSend(player.Player)
 	if err != nil {
@@ -105,6 +81,12 @@ func (my *actorT) FourPullWantListRequest(player *playerT,
 		return wants[j].CreatedAt.Unix() < wants[i].CreatedAt.Unix()
 	})
 
+	ids := make([]database.Player, 0, len(wants))
+	for _, x := range wants {
+		ids = append(ids, x.Player)
+	}
+	data := queryPlayers(ids)
+
 	var d []*four_proto.FourPullWantListResponse_FourFriend
 	linq.From(wants).SelectT(func(x *database.AskData) *four_proto.FourPullWantListResponse_FourFriend {
 		online := true
@@ -113,7 +95,7 @@ func (my *actorT) FourPullWantListRequest(player *playerT,
 		}
 		return &four_proto.FourPullWantListResponse_FourFriend{
 			PlayerId: int32(x.Player),
-			Nickname: x.Player.PlayerData().Nickname,
+			Nickname: data[x.Player].Nickname,
 			Online:   online,
 			Status:   x.Status,
 		}
@@ -124,9 +106,10 @@ func (my *actorT) FourPullWantListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) FourPullAskListRequest(player *playerT,
+func (my *actorT) FourPullAskListRequest(playerId uint64,
 	ev *four_proto.FourPullAskListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullAskListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	asks, err := database.QueryAskListUndeal(player.Player)
 	if err != nil {
@@ -147,6 +130,12 @@ func (my *actorT) FourPullAskListRequest(player *playerT,
 		return asks[j].CreatedAt.Unix() < asks[i].CreatedAt.Unix()
 	})
 
+	ids := make([]database.Player, 0, len(asks))
+	for _, x := range asks {
+		ids = append(ids, x.Sender)
+	}
+	data := queryPlayers(ids)
+
 	var d []*four_proto.FourPullAskListResponse_FourFriend
 	linq.From(asks).SelectT(func(x *database.AskData) *four_proto.FourPullAskListResponse_FourFriend {
 		online := true
@@ -155,7 +144,7 @@ func (my *actorT) FourPullAskListRequest(player *playerT,
 		}
 		return &four_proto.FourPullAskListResponse_FourFriend{
 			PlayerId: int32(x.Sender),
-			Nickname: x.Sender.PlayerData().Nickname,
+			Nickname: data[x.Sender].Nickname,
 			Online:   online,
 			Status:   x.Status,
 			Number:   x.Id,
@@ -167,9 +156,10 @@ func (my *actorT) FourPullAskListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) FourPullBanListRequest(player *playerT,
+func (my *actorT) FourPullBanListRequest(playerId uint64,
 	ev *four_proto.FourPullBanListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullBanListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	friends, err := database.QueryBanFriendList(player.Player)
 	if err != nil {
@@ -177,6 +167,12 @@ func (my *actorT) FourPullBanListRequest(player *playerT,
 		return
 	}
 
+	ids := make([]database.Player, 0, len(friends))
+	for _, x := range friends {
+		ids = append(ids, x.Friend)
+	}
+	data := queryPlayers(ids)
+
 	var d []*four_proto.FourPullBanListResponse_FourFriend
 	linq.From(friends).SelectT(func(x *database.FriendData) *four_proto.FourPullBanListResponse_FourFriend {
 		online := true
@@ -185,7 +181,7 @@ func (my *actorT) FourPullBanListRequest(player *playerT,
 		}
 		return &four_proto.FourPullBanListResponse_FourFriend{
 			PlayerId: int32(x.Friend),
-			Nickname: x.Friend.PlayerData().Nickname,
+			Nickname: data[x.Friend].Nickname,
 			Online:   online,
 		}
 	}).ToSlice(&d)
@@ -195,9 +191,10 @@ func (my *actorT) FourPullBanListRequest(player *playerT,
 	}, nil)
 }
 
-func (my *actorT) FourBanFriendRequest(player *playerT,
+func (my *actorT) FourBanFriendRequest(playerId uint64,
 	ev *four_proto.FourBanFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourBanFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	err := database.BanFriend(player.Player, database.Player(ev.GetPlayerId()))
 	if err != nil {
@@ -207,9 +204,10 @@ func (my *actorT) FourBanFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) FourCancelBanFriendRequest(player *playerT,
+func (my *actorT) FourCancelBanFriendRequest(playerId uint64,
 	ev *four_proto.FourCancelBanFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourCancelBanFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	err := database.CancelBanFriend(player.Player, database.Player(ev.GetPlayerId()))
 	if err != nil {
@@ -219,9 +217,10 @@ func (my *actorT) FourCancelBanFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) FourWantFriendRequest(player *playerT,
+func (my *actorT) FourWantFriendRequest(playerId uint64,
 	ev *four_proto.FourWantFriendRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourWantFriendResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	if err := database.WantFriend(player.Player, database.Player(ev.GetPlayerId())); err != nil {
 		respond(nil, err)
@@ -230,10 +229,9 @@ func (my *actorT) FourWantFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) FourBecomeFriendRequest(player *playerT,
+func (my *actorT) FourBecomeFriendRequest(playerId uint64,
 	ev *four_proto.FourBecomeFriendRequest,
-	respond func(proto.Message, error)) {
-
+	respond func(*four_proto.FourBecomeFriendResponse, error)) {
 	if err := database.ReplayAskFriend(ev.GetNumber(), ev.GetOperate()); err != nil {
 		respond(nil, err)
 	} else {
@@ -241,21 +239,23 @@ func (my *actorT) FourBecomeFriendRequest(player *playerT,
 	}
 }
 
-func (my *actorT) FourPullPayForAnotherRoomListRequest(player *playerT,
+func (my *actorT) FourPullPayForAnotherRoomListRequest(playerId uint64,
 	ev *four_proto.FourPullPayForAnotherRoomListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullPayForAnotherRoomListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
 	respond(&four_proto.FourPullPayForAnotherRoomListResponse{my.fourRooms.WherePayForAnother().WhereCreator(player.Player).FourRoom1()}, nil)
 }
 
-func (my *actorT) FourPullWarHistoryListRequest(player *playerT,
+func (my *actorT) FourPullWarHistoryListRequest(playerId uint64,
 	ev *four_proto.FourPullWarHistoryListRequest,
-	respond func(proto.Message, error)) {
+	respond func(*four_proto.FourPullWarHistoryListResponse, error)) {
+	player := my.players[database.Player(playerId)]
 
-	histories, err := database.FourQueryWarHistory(player.Player, 20)
+	histories, next, err := database.FourQueryWarHistory(player.Player, database.HistoryCursorFromProto(ev.Cursor), ev.Limit)
 	if err != nil {
 		respond(nil, err)
 	} else {
-		respond(&four_proto.FourPullWarHistoryListResponse{histories}, nil)
+		respond(&four_proto.FourPullWarHistoryListResponse{histories, next.Proto()}, nil)
 	}
 }
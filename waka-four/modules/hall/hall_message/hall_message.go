@@ -17,3 +17,8 @@ type GetTotalRoom struct {
 type UpdatePlayerSecret struct {
 	Player database.Player
 }
+
+// 运行时设置已变更
+type SettingsChanged struct {
+	Settings *database.Settings
+}
@@ -1,6 +1,10 @@
 package hall
 
 import (
+	"math"
+
+	"github.com/sirupsen/logrus"
+
 	"github.com/liuhan907/waka/waka-four/database"
 	"github.com/liuhan907/waka/waka-four/proto"
 )
@@ -63,7 +67,8 @@ func (my *actorT) ToPlayerSecret(player database.Player) (pb *four_proto.PlayerS
 	pb.Wechat = playerData.Wechat
 	pb.Idcard = playerData.Idcard
 	pb.Name = playerData.Name
-	pb.Diamonds = playerData.Diamonds
+	pb.Diamonds = clampInt32(playerData.Diamonds)
+	pb.Diamonds64 = playerData.Diamonds
 	pb.Supervisor = int32(playerData.Supervisor)
 	pb.CreatedAt = playerData.CreatedAt.Unix()
 
@@ -81,3 +86,32 @@ func (my *actorT) ToPlayerMap(players map[database.Player]database.Player) (pb [
 	}
 	return pb
 }
+
+// 一次查询载入多个玩家的数据, 查询失败或不存在的玩家与 PlayerData 一样使用默认值
+func queryPlayers(players []database.Player) map[database.Player]*database.PlayerData {
+	r, err := database.QueryPlayers(players)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"players": players,
+			"err":     err,
+		}).Warnln("query players failed")
+		r = make(map[database.Player]*database.PlayerData, len(players))
+	}
+	for _, player := range players {
+		if _, being := r[player]; !being {
+			r[player] = player.PlayerData()
+		}
+	}
+	return r
+}
+
+// 转换为 int32, 超出范围时取边界值, 用于填充旧协议中的 32 位字段
+func clampInt32(number int64) int32 {
+	if number > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if number < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(number)
+}
@@ -10,6 +10,8 @@ func (my *actorT) ReceiveExt(context actor.Context) bool {
 	switch ev := context.Message().(type) {
 	case *hall_message.UpdatePlayerSecret:
 		my.UpdatePlayerSecret(ev)
+	case *hall_message.SettingsChanged:
+		my.SettingsChanged(ev)
 	default:
 		return false
 	}
@@ -21,3 +23,11 @@ func (my *actorT) ReceiveExt(context actor.Context) bool {
 func (my *actorT) UpdatePlayerSecret(ev *hall_message.UpdatePlayerSecret) {
 	my.sendPlayerSecret(ev.Player)
 }
+
+// 向在线玩家推送新的欢迎消息
+func (my *actorT) SettingsChanged(ev *hall_message.SettingsChanged) {
+	welcome := ev.Settings.Welcome()
+	for player := range my.players.SelectOnline() {
+		my.send(player, welcome)
+	}
+}
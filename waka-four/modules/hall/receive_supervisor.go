@@ -9,9 +9,16 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/liuhan907/waka/waka-four/database"
+	"github.com/liuhan907/waka/waka-four/proto"
 	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 )
 
+// hall 通过生成的分发函数处理玩家消息
+var (
+	_ four_proto.HallTransportHandler = (*actorT)(nil)
+	_ four_proto.HallFutureHandler    = (*actorT)(nil)
+)
+
 func (my *actorT) ReceiveSupervisor(context actor.Context) bool {
 	switch ev := context.Message().(type) {
 	case *supervisor_message.PlayerEntered:
@@ -131,8 +138,7 @@ func (my *actorT) playerTransported(ev *supervisor_message.PlayerTransported) {
 
 	player := database.Player(ev.Player)
 
-	playerData, being := my.players[player]
-	if !being {
+	if _, being := my.players[player]; !being {
 		log.WithFields(logrus.Fields{
 			"player":  ev.Player,
 			"type":    reflect.TypeOf(ev.Payload).Elem().Name(),
@@ -141,7 +147,7 @@ func (my *actorT) playerTransported(ev *supervisor_message.PlayerTransported) {
 		return
 	}
 
-	if my.playerTransportedFour(playerData, ev) {
+	if four_proto.DispatchHallTransport(my, ev.Player, ev.Payload) {
 		return
 	}
 
@@ -163,8 +169,7 @@ func (my *actorT) playerFutureRequested(ev *supervisor_message.PlayerFutureReque
 
 	player := database.Player(ev.Player)
 
-	playerData, being := my.players[player]
-	if !being {
+	if _, being := my.players[player]; !being {
 		log.WithFields(logrus.Fields{
 			"player":  ev.Player,
 			"type":    reflect.TypeOf(ev.Payload).Elem().Name(),
@@ -173,7 +178,7 @@ func (my *actorT) playerFutureRequested(ev *supervisor_message.PlayerFutureReque
 		return
 	}
 
-	if my.playerFutureRequestedFour(playerData, ev) {
+	if four_proto.DispatchHallFuture(my, ev.Player, ev.Payload, ev.Respond) {
 		return
 	}
 
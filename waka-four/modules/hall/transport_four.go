@@ -1,52 +1,14 @@
 package hall
 
 import (
+	"github.com/liuhan907/waka/waka-four/database"
 	"github.com/liuhan907/waka/waka-four/proto"
-	"github.com/liuhan907/waka/waka/modules/supervisor/supervisor_message"
 	"github.com/sirupsen/logrus"
 )
 
-func (my *actorT) playerTransportedFour(player *playerT, ev *supervisor_message.PlayerTransported) bool {
-	switch evd := ev.Payload.(type) {
-	case *four_proto.FourCreateRoom:
-		my.FourCreateRoom(player, evd)
-	case *four_proto.FourJoinRoom:
-		my.FourJoinRoom(player, evd)
-	case *four_proto.FourSwitchReady:
-		my.FourSwitchReady(player, evd)
-	case *four_proto.FourLeaveRoom:
-		my.FourLeaveRoom(player, evd)
-	case *four_proto.FourDismiss:
-		my.FourDismiss(player, evd)
-	case *four_proto.FourDismissVote:
-		my.FourDismissVote(player, evd)
-	case *four_proto.FourStart:
-		my.FourStart(player, evd)
-	case *four_proto.FourCut:
-		my.FourCut(player, evd)
-	case *four_proto.FourCommitPokers:
-		my.FourCommitPokers(player, evd)
-	case *four_proto.FourSendMessage:
-		my.FourSendMessage(player, evd)
-	case *four_proto.FourContinueWith:
-		my.FourContinueWith(player, evd)
-	case *four_proto.FourSwitchToBackground:
-		my.FourSwitchToBackground(player, evd)
-	case *four_proto.FourSwitchToForeground:
-		my.FourSwitchToForeground(player, evd)
-	case *four_proto.FourGrab:
-		my.FourGarb(player, evd)
-	case *four_proto.FourGrabFixedBanker:
-		my.FourGarbOfFixedBanker(player, evd)
-	case *four_proto.FourSetMultiple:
-		my.FourSetMultiple(player, evd)
-	default:
-		return false
-	}
-	return true
-}
+func (my *actorT) FourCreateRoom(playerId uint64, ev *four_proto.FourCreateRoom) {
+	player := my.players[database.Player(playerId)]
 
-func (my *actorT) FourCreateRoom(player *playerT, ev *four_proto.FourCreateRoom) {
 	if player.InsideFour != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -97,7 +59,9 @@ func (my *actorT) FourCreateRoom(player *playerT, ev *four_proto.FourCreateRoom)
 	room.CreateRoom(my, id, ev.GetOption(), player.Player)
 }
 
-func (my *actorT) FourJoinRoom(player *playerT, ev *four_proto.FourJoinRoom) {
+func (my *actorT) FourJoinRoom(playerId uint64, ev *four_proto.FourJoinRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour != 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -119,7 +83,9 @@ func (my *actorT) FourJoinRoom(player *playerT, ev *four_proto.FourJoinRoom) {
 	room.JoinRoom(player)
 }
 
-func (my *actorT) FourSwitchReady(player *playerT, ev *four_proto.FourSwitchReady) {
+func (my *actorT) FourSwitchReady(playerId uint64, ev *four_proto.FourSwitchReady) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -140,7 +106,9 @@ func (my *actorT) FourSwitchReady(player *playerT, ev *four_proto.FourSwitchRead
 	room.SwitchReady(player)
 }
 
-func (my *actorT) FourLeaveRoom(player *playerT, ev *four_proto.FourLeaveRoom) {
+func (my *actorT) FourLeaveRoom(playerId uint64, ev *four_proto.FourLeaveRoom) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -161,7 +129,9 @@ func (my *actorT) FourLeaveRoom(player *playerT, ev *four_proto.FourLeaveRoom) {
 	room.LeaveRoom(player)
 }
 
-func (my *actorT) FourDismiss(player *playerT, ev *four_proto.FourDismiss) {
+func (my *actorT) FourDismiss(playerId uint64, ev *four_proto.FourDismiss) {
+	player := my.players[database.Player(playerId)]
+
 	roomId := int32(0)
 
 	if ev.GetRoomId() != 0 {
@@ -189,7 +159,9 @@ func (my *actorT) FourDismiss(player *playerT, ev *four_proto.FourDismiss) {
 	room.Dismiss(player)
 }
 
-func (my *actorT) FourDismissVote(player *playerT, ev *four_proto.FourDismissVote) {
+func (my *actorT) FourDismissVote(playerId uint64, ev *four_proto.FourDismissVote) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -210,7 +182,9 @@ func (my *actorT) FourDismissVote(player *playerT, ev *four_proto.FourDismissVot
 	room.DismissVote(player, ev.GetPassing())
 }
 
-func (my *actorT) FourStart(player *playerT, ev *four_proto.FourStart) {
+func (my *actorT) FourStart(playerId uint64, ev *four_proto.FourStart) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -231,7 +205,9 @@ func (my *actorT) FourStart(player *playerT, ev *four_proto.FourStart) {
 	room.Start(player)
 }
 
-func (my *actorT) FourCut(player *playerT, ev *four_proto.FourCut) {
+func (my *actorT) FourCut(playerId uint64, ev *four_proto.FourCut) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -252,7 +228,9 @@ func (my *actorT) FourCut(player *playerT, ev *four_proto.FourCut) {
 	room.Cut(player, ev.GetPos())
 }
 
-func (my *actorT) FourCommitPokers(player *playerT, ev *four_proto.FourCommitPokers) {
+func (my *actorT) FourCommitPokers(playerId uint64, ev *four_proto.FourCommitPokers) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -273,7 +251,9 @@ func (my *actorT) FourCommitPokers(player *playerT, ev *four_proto.FourCommitPok
 	room.CommitPokers(player, ev.GetFront(), ev.GetBehind())
 }
 
-func (my *actorT) FourSendMessage(player *playerT, ev *four_proto.FourSendMessage) {
+func (my *actorT) FourSendMessage(playerId uint64, ev *four_proto.FourSendMessage) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -294,7 +274,9 @@ func (my *actorT) FourSendMessage(player *playerT, ev *four_proto.FourSendMessag
 	room.SendMessage(player, ev.GetMessage().GetType(), ev.GetMessage().GetText())
 }
 
-func (my *actorT) FourContinueWith(player *playerT, ev *four_proto.FourContinueWith) {
+func (my *actorT) FourContinueWith(playerId uint64, ev *four_proto.FourContinueWith) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -315,7 +297,9 @@ func (my *actorT) FourContinueWith(player *playerT, ev *four_proto.FourContinueW
 	room.ContinueWith(player)
 }
 
-func (my *actorT) FourSwitchToBackground(player *playerT, ev *four_proto.FourSwitchToBackground) {
+func (my *actorT) FourSwitchToBackground(playerId uint64, ev *four_proto.FourSwitchToBackground) {
+	player := my.players[database.Player(playerId)]
+
 	log.WithFields(logrus.Fields{
 		"player": player.Player,
 	}).Debugln("player to background")
@@ -347,7 +331,9 @@ func (my *actorT) FourSwitchToBackground(player *playerT, ev *four_proto.FourSwi
 	}
 }
 
-func (my *actorT) FourSwitchToForeground(player *playerT, ev *four_proto.FourSwitchToForeground) {
+func (my *actorT) FourSwitchToForeground(playerId uint64, ev *four_proto.FourSwitchToForeground) {
+	player := my.players[database.Player(playerId)]
+
 	playerData, being := my.players[player.Player]
 	if !being {
 		log.WithFields(logrus.Fields{
@@ -380,7 +366,9 @@ func (my *actorT) FourSwitchToForeground(player *playerT, ev *four_proto.FourSwi
 	}
 }
 
-func (my *actorT) FourGarb(player *playerT, ev *four_proto.FourGrab) {
+func (my *actorT) FourGrab(playerId uint64, ev *four_proto.FourGrab) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -397,16 +385,12 @@ func (my *actorT) FourGarb(player *playerT, ev *four_proto.FourGrab) {
 		player.InsideFour = 0
 		return
 	}
-	if ev.Number != 0 || ev.Number != 1 || ev.Number != 2 || ev.Number != 4 || ev.Number != 8 {
-		log.WithFields(logrus.Fields{
-			"player": player.Player,
-			"Number": ev.Number,
-		}).Warnln("Number is  illegal")
-	}
 	room.FourGrabBanker(player, ev.Doing, ev.Number)
 }
 
-func (my *actorT) FourGarbOfFixedBanker(player *playerT, ev *four_proto.FourGrabFixedBanker) {
+func (my *actorT) FourGrabFixedBanker(playerId uint64, ev *four_proto.FourGrabFixedBanker) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -427,7 +411,9 @@ func (my *actorT) FourGarbOfFixedBanker(player *playerT, ev *four_proto.FourGrab
 	room.FourGrabOfFixedBanker(player, ev.Doing)
 }
 
-func (my *actorT) FourSetMultiple(player *playerT, ev *four_proto.FourSetMultiple) {
+func (my *actorT) FourSetMultiple(playerId uint64, ev *four_proto.FourSetMultiple) {
+	player := my.players[database.Player(playerId)]
+
 	if player.InsideFour == 0 {
 		log.WithFields(logrus.Fields{
 			"player": player.Player,
@@ -444,11 +430,5 @@ func (my *actorT) FourSetMultiple(player *playerT, ev *four_proto.FourSetMultipl
 		player.InsideFour = 0
 		return
 	}
-	if ev.Multiple != 1 || ev.Multiple != 2 || ev.Multiple != 3 || ev.Multiple != 5 {
-		log.WithFields(logrus.Fields{
-			"player":   player.Player,
-			"Multiple": ev.Multiple,
-		}).Warnln("Multiple is  illegal")
-	}
 	room.FourSetMultiple(player, ev.Multiple)
 }
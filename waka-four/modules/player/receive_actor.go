@@ -3,7 +3,6 @@ package player
 import (
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/liuhan907/waka/waka-four/database"
-	"github.com/liuhan907/waka/waka-four/proto"
 	"github.com/liuhan907/waka/waka/modules/session/session_message"
 	"github.com/sirupsen/logrus"
 )
@@ -26,10 +25,5 @@ func (my *actorT) started(context actor.Context) {
 	})
 	my.pid = context.Self()
 
-	my.conn.Tell(&session_message.Send{&four_proto.Welcome{
-		Customers: database.GetCustomerServices(),
-		Exts:      database.GetExt(),
-		Notices:   database.GetNotices(),
-		Urls:      database.GetUrls(),
-	}})
+	my.conn.Tell(&session_message.Send{database.GetSettings().Welcome()})
 }
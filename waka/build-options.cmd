@@ -0,0 +1,5 @@
+@echo off
+
+set ProjectPath=%GOPATH%\src\github.com\liuhan907\waka
+
+protoc -I%ProjectPath% %ProjectPath%\waka\options.proto --go_out=%GOPATH%\src
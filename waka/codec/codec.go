@@ -13,40 +13,43 @@ var (
 )
 
 func Encode(m proto.Message) ([]byte, uint32, string, error) {
+	return EncodeBy(Protobuf, m)
+}
+
+func Decode(id uint32, data []byte) (proto.Message, string, error) {
+	return DecodeBy(Protobuf, id, data)
+}
+
+// 使用指定的负载编码编码消息
+func EncodeBy(c Codec, m proto.Message) ([]byte, uint32, string, error) {
 	meta := cellnet.MessageMetaByType(reflect.TypeOf(m))
 	if meta == nil {
 		return nil, 0, "", cellnet.ErrMessageNotFound
 	}
-	if meta.Codec == nil {
-		return nil, 0, "", cellnet.ErrCodecNotFound
-	}
 
-	data, err := meta.Codec.Encode(m)
+	data, err := c.Encode(m)
 	if err != nil {
 		return nil, 0, "", err
 	}
 	return data, meta.ID, meta.Name, nil
 }
 
-func Decode(id uint32, data []byte) (proto.Message, string, error) {
+// 使用指定的负载编码解码消息
+func DecodeBy(c Codec, id uint32, data []byte) (proto.Message, string, error) {
 	meta := cellnet.MessageMetaByID(id)
 	if meta == nil {
 		return nil, "", cellnet.ErrMessageNotFound
 	}
-	if meta.Codec == nil {
-		return nil, "", cellnet.ErrCodecNotFound
+
+	m, ok := reflect.New(meta.Type).Interface().(proto.Message)
+	if !ok {
+		return nil, "", ErrNotIllegalMessage
 	}
 
-	payload := reflect.New(meta.Type).Interface()
-	err := meta.Codec.Decode(data, payload)
+	err := c.Decode(data, m)
 	if err != nil {
 		return nil, "", err
 	}
 
-	m, ok := payload.(proto.Message)
-	if !ok {
-		return nil, "", ErrNotIllegalMessage
-	}
-
 	return m, meta.Name, nil
 }
@@ -0,0 +1,148 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/davyxu/cellnet"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/pkg/errors"
+
+	"github.com/liuhan907/waka/waka/proto"
+)
+
+// 生成代码中所有消息都实现的描述符接口
+type DescribedMessage interface {
+	proto.Message
+	Descriptor() ([]byte, []int)
+}
+
+// 已注册消息的元数据
+type Meta struct {
+	// 完整名称
+	Name string `json:"name"`
+	// 消息 ID
+	ID uint32 `json:"id"`
+	// Go 类型
+	Type string `json:"type"`
+	// 所属包
+	Package string `json:"package"`
+}
+
+var (
+	registryLock sync.Mutex
+	packages     = make(map[string][]string)
+)
+
+func init() {
+	if err := RegisterPackage(&waka_proto.Heart{}); err != nil {
+		panic(err)
+	}
+}
+
+// 注册消息所在 proto 文件中的全部消息, 供启动校验与查询使用
+func RegisterPackage(m DescribedMessage) error {
+	gz, _ := m.Descriptor()
+
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return errors.Wrap(err, "open file descriptor failed")
+	}
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "read file descriptor failed")
+	}
+
+	f := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(d, f); err != nil {
+		return errors.Wrap(err, "unmarshal file descriptor failed")
+	}
+
+	// 与 protoc-gen-msg 生成的注册代码一致, 只有顶层消息注册到 cellnet, 嵌套消息不单独收发
+	var names []string
+	for _, message := range f.GetMessageType() {
+		names = append(names, f.GetPackage()+"."+message.GetName())
+	}
+
+	registryLock.Lock()
+	packages[f.GetPackage()] = names
+	registryLock.Unlock()
+
+	return nil
+}
+
+// 校验全部已注册包中的消息都已注册到 cellnet 且 ID 互不冲突
+func Validate() error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	var problems []string
+	owners := make(map[uint32]string)
+
+	for _, pkg := range sortedPackages() {
+		for _, name := range packages[pkg] {
+			meta := cellnet.MessageMetaByName(name)
+			if meta == nil {
+				problems = append(problems, fmt.Sprintf("%s: not registered", name))
+				continue
+			}
+			if owner, being := owners[meta.ID]; being {
+				problems = append(problems, fmt.Sprintf("%s: id %d collides with %s", name, meta.ID, owner))
+				continue
+			}
+			owners[meta.ID] = name
+
+			if byID := cellnet.MessageMetaByID(meta.ID); byID == nil || byID.Name != name {
+				other := "<nil>"
+				if byID != nil {
+					other = byID.Name
+				}
+				problems = append(problems, fmt.Sprintf("%s: id %d resolves to %s", name, meta.ID, other))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("message registry invalid: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// 列出全部已注册包中的消息, 按包名与消息名排序
+func Registry() []*Meta {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	var r []*Meta
+	for _, pkg := range sortedPackages() {
+		for _, name := range packages[pkg] {
+			meta := &Meta{
+				Name:    name,
+				Package: pkg,
+			}
+			if m := cellnet.MessageMetaByName(name); m != nil {
+				meta.ID = m.ID
+				if m.Type != nil {
+					meta.Type = m.Type.String()
+				}
+			}
+			r = append(r, meta)
+		}
+	}
+	return r
+}
+
+func sortedPackages() []string {
+	var r []string
+	for pkg := range packages {
+		r = append(r, pkg)
+	}
+	sort.Strings(r)
+	return r
+}
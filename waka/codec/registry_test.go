@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+
+	"github.com/davyxu/cellnet"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// 只提供文件描述符的消息, 模拟生成代码
+type describedMessage struct {
+	gz []byte
+}
+
+func (m *describedMessage) Reset()         {}
+func (m *describedMessage) String() string { return "" }
+func (m *describedMessage) ProtoMessage()  {}
+
+func (m *describedMessage) Descriptor() ([]byte, []int) {
+	return m.gz, []int{0}
+}
+
+func newDescribedMessage(t *testing.T, f *descriptor.FileDescriptorProto) *describedMessage {
+	d, err := proto.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(d)
+	w.Close()
+	return &describedMessage{b.Bytes()}
+}
+
+// 嵌套消息与 map 的条目不注册到 cellnet, 校验时不应当报告未注册
+func TestRegisterPackageNested(t *testing.T) {
+	defer func(origin map[string][]string) { packages = origin }(packages)
+	packages = make(map[string][]string)
+
+	m := newDescribedMessage(t, &descriptor.FileDescriptorProto{
+		Name:    proto.String("codec_test.proto"),
+		Package: proto.String("codec_test"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Welcome"),
+				NestedType: []*descriptor.DescriptorProto{
+					{
+						Name:       proto.String("Customer"),
+						NestedType: []*descriptor.DescriptorProto{{Name: proto.String("Contact")}},
+					},
+					{
+						Name:    proto.String("LabelsEntry"),
+						Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+					},
+				},
+			},
+			{Name: proto.String("Heart")},
+		},
+	})
+	if err := RegisterPackage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"codec_test.Welcome", "codec_test.Heart"}
+	if !reflect.DeepEqual(packages["codec_test"], want) {
+		t.Fatalf("registered %v, want %v", packages["codec_test"], want)
+	}
+
+	if err := Validate(); err == nil {
+		t.Fatal("validate passed without cellnet metas")
+	}
+
+	cellnet.RegisterMessageMeta("pb", "codec_test.Welcome", reflect.TypeOf((*describedMessage)(nil)).Elem(), 0x7e570001)
+	cellnet.RegisterMessageMeta("pb", "codec_test.Heart", reflect.TypeOf((*describedMessage)(nil)).Elem(), 0x7e570002)
+	if err := Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := Registry()
+	if len(registry) != 2 || registry[0].Name != "codec_test.Welcome" || registry[0].ID != 0x7e570001 {
+		t.Fatalf("registry %+v", registry)
+	}
+}
@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrCodecNotFound = errors.New("codec not found")
+)
+
+// 负载编码
+type Codec interface {
+	// 编码名称, 用于会话协商
+	Name() string
+	Encode(m proto.Message) ([]byte, error)
+	Decode(data []byte, m proto.Message) error
+}
+
+var (
+	// 默认编码
+	Protobuf Codec = protobufCodec{}
+	// 调试用的 JSON 编码
+	JSON Codec = jsonCodec{
+		marshaler:   jsonpb.Marshaler{OrigName: true},
+		unmarshaler: jsonpb.Unmarshaler{AllowUnknownFields: true},
+	}
+
+	codecsLock sync.RWMutex
+	codecs     = map[string]Codec{
+		Protobuf.Name(): Protobuf,
+		JSON.Name():     JSON,
+	}
+)
+
+// 注册负载编码
+func RegisterCodec(c Codec) {
+	codecsLock.Lock()
+	codecs[c.Name()] = c
+	codecsLock.Unlock()
+}
+
+// 根据名称获取负载编码
+func CodecByName(name string) (Codec, error) {
+	codecsLock.RLock()
+	c, being := codecs[name]
+	codecsLock.RUnlock()
+	if !being {
+		return nil, ErrCodecNotFound
+	}
+	return c, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (protobufCodec) Encode(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Decode(data []byte, m proto.Message) error {
+	return proto.Unmarshal(data, m)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type jsonCodec struct {
+	marshaler   jsonpb.Marshaler
+	unmarshaler jsonpb.Unmarshaler
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (c jsonCodec) Encode(m proto.Message) ([]byte, error) {
+	w := bytes.NewBuffer(make([]byte, 0, 256))
+	if err := c.marshaler.Marshal(w, m); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func (c jsonCodec) Decode(data []byte, m proto.Message) error {
+	return c.unmarshaler.Unmarshal(bytes.NewReader(data), m)
+}
@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"net"
 	"os"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
@@ -20,8 +21,20 @@ var (
 	})
 )
 
+// 客户端连接, TCP 与 WebSocket 连接都以此交给会话
+type Conn interface {
+	// 连接 ID
+	ID() int64
+	// 远端地址
+	RemoteAddr() string
+	// 发送消息
+	Send(msg interface{})
+	// 关闭连接
+	Close()
+}
+
 // 消息转发目标创建器
-type TargetCreator func(conn cellnet.Session) *actor.PID
+type TargetCreator func(conn Conn) *actor.PID
 
 // 配置
 type Option struct {
@@ -30,6 +43,17 @@ type Option struct {
 
 	// 监听地址
 	Address string
+	// WebSocket 监听地址, 为空时不监听
+	WebSocketAddress string
+}
+
+// cellnet TCP 会话
+type socketConn struct {
+	cellnet.Session
+}
+
+func (conn socketConn) RemoteAddr() string {
+	return conn.RawConn().(*net.TCPConn).RemoteAddr().String()
 }
 
 // 启动
@@ -52,32 +76,44 @@ func Start(option Option) {
 
 	cellnet.RegisterMessage(peer, "coredef.SessionAccepted",
 		func(ev *cellnet.Event) {
-			ev.Ses.SetTag(option.TargetCreator(ev.Ses))
+			ev.Ses.SetTag(option.TargetCreator(socketConn{ev.Ses}))
 		})
 	cellnet.RegisterMessage(peer, "coredef.SessionClosed",
 		func(ev *cellnet.Event) {
 			pid := ev.Ses.Tag().(*actor.PID)
 			pid.Tell(&gateway_message.Closed{})
 		})
-	cellnet.RegisterMessage(peer, "waka_proto.Heart",
-		func(ev *cellnet.Event) {
-			pid := ev.Ses.Tag().(*actor.PID)
-			pid.Tell(&gateway_message.Heart{})
-		})
-	cellnet.RegisterMessage(peer, "waka_proto.Transport",
-		func(ev *cellnet.Event) {
-			evd := ev.Msg.(*waka_proto.Transport)
-			pid := ev.Ses.Tag().(*actor.PID)
-			pid.Tell(&gateway_message.Transport{evd.GetId(), evd.GetPayload()})
-		})
-	cellnet.RegisterMessage(peer, "waka_proto.FutureRequest",
-		func(ev *cellnet.Event) {
-			evd := ev.Msg.(*waka_proto.FutureRequest)
-			pid := ev.Ses.Tag().(*actor.PID)
-			pid.Tell(&gateway_message.FutureRequest{evd.GetId(), evd.GetPayload(), evd.GetNumber()})
-		})
+	for _, name := range []string{
+		"waka_proto.Heart",
+		"waka_proto.Negotiate",
+		"waka_proto.Transport",
+		"waka_proto.FutureRequest",
+	} {
+		cellnet.RegisterMessage(peer, name,
+			func(ev *cellnet.Event) {
+				forward(ev.Ses.Tag().(*actor.PID), ev.Msg)
+			})
+	}
 
 	log.WithFields(logrus.Fields{
 		"address": option.Address,
 	}).Infoln("listen started")
+
+	if option.WebSocketAddress != "" {
+		startWebSocket(option)
+	}
+}
+
+// 把客户端消息转发给会话
+func forward(pid *actor.PID, msg interface{}) {
+	switch evd := msg.(type) {
+	case *waka_proto.Heart:
+		pid.Tell(&gateway_message.Heart{})
+	case *waka_proto.Negotiate:
+		pid.Tell(&gateway_message.Negotiate{evd.GetCodec()})
+	case *waka_proto.Transport:
+		pid.Tell(&gateway_message.Transport{evd.GetId(), evd.GetPayload()})
+	case *waka_proto.FutureRequest:
+		pid.Tell(&gateway_message.FutureRequest{evd.GetId(), evd.GetPayload(), evd.GetNumber()})
+	}
 }
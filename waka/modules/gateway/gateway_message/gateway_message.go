@@ -18,3 +18,8 @@ type FutureRequest struct {
 	Payload []byte
 	Number  uint64
 }
+
+// 负载编码协商
+type Negotiate struct {
+	Codec string
+}
@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+
+	"github.com/liuhan907/waka/waka/codec"
+	"github.com/liuhan907/waka/waka/modules/gateway/gateway_message"
+)
+
+// WebSocket 连接的发送队列长度, 队列满时断开连接
+const webSocketSendQueue = 1024
+
+var (
+	ErrIllegalFrame = errors.New("gateway: illegal frame")
+)
+
+var webSocketSerial int64
+
+// WebSocket 连接, 每个二进制帧承载一个与 TCP 相同的 TLV 封包:
+// 消息 ID (4 字节) + 负载长度 (4 字节) + 负载, 小端
+type webSocketConn struct {
+	id   int64
+	ws   *websocket.Conn
+	send chan []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (conn *webSocketConn) ID() int64 {
+	return conn.id
+}
+
+func (conn *webSocketConn) RemoteAddr() string {
+	return conn.ws.Request().RemoteAddr
+}
+
+func (conn *webSocketConn) Send(msg interface{}) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+
+	data, id, _, err := codec.Encode(m)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"session_id": conn.id,
+			"err":        err,
+		}).Warnln("websocket encode failed")
+		return
+	}
+
+	select {
+	case conn.send <- encodeFrame(id, data):
+	case <-conn.closed:
+	default:
+		log.WithFields(logrus.Fields{
+			"session_id": conn.id,
+		}).Warnln("websocket send queue full")
+		conn.Close()
+	}
+}
+
+// 关闭连接, 已经放入队列的消息会先写出
+func (conn *webSocketConn) Close() {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+	})
+}
+
+func (conn *webSocketConn) write() {
+	defer conn.ws.Close()
+
+	for {
+		select {
+		case frame := <-conn.send:
+			if err := websocket.Message.Send(conn.ws, frame); err != nil {
+				conn.Close()
+				return
+			}
+		case <-conn.closed:
+			for {
+				select {
+				case frame := <-conn.send:
+					if err := websocket.Message.Send(conn.ws, frame); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (conn *webSocketConn) read(pid *actor.PID) {
+	defer pid.Tell(&gateway_message.Closed{})
+	defer conn.Close()
+
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(conn.ws, &frame); err != nil {
+			return
+		}
+
+		id, data, err := decodeFrame(frame)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"session_id": conn.id,
+				"err":        err,
+			}).Warnln("websocket read failed")
+			return
+		}
+		msg, _, err := codec.Decode(id, data)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"session_id": conn.id,
+				"id":         id,
+				"err":        err,
+			}).Warnln("websocket decode failed")
+			return
+		}
+
+		forward(pid, msg)
+	}
+}
+
+func encodeFrame(id uint32, data []byte) []byte {
+	frame := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint32(frame[0:], id)
+	binary.LittleEndian.PutUint32(frame[4:], uint32(len(data)))
+	copy(frame[8:], data)
+	return frame
+}
+
+func decodeFrame(frame []byte) (uint32, []byte, error) {
+	if len(frame) < 8 {
+		return 0, nil, ErrIllegalFrame
+	}
+	id := binary.LittleEndian.Uint32(frame[0:])
+	size := binary.LittleEndian.Uint32(frame[4:])
+	if int(size) != len(frame)-8 {
+		return 0, nil, ErrIllegalFrame
+	}
+	return id, frame[8:], nil
+}
+
+// 启动 WebSocket 监听, 连接与 TCP 连接一样交给 TargetCreator
+func startWebSocket(option Option) {
+	l, err := net.Listen("tcp", option.WebSocketAddress)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"address": option.WebSocketAddress,
+			"err":     err,
+		}).Fatalln("websocket listen failed")
+	}
+
+	// 不设置 Handshake, 不校验 Origin, 非浏览器客户端也可以连接
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+
+			conn := &webSocketConn{
+				id:     atomic.AddInt64(&webSocketSerial, 1),
+				ws:     ws,
+				send:   make(chan []byte, webSocketSendQueue),
+				closed: make(chan struct{}),
+			}
+			go conn.write()
+			conn.read(option.TargetCreator(conn))
+		},
+	}
+
+	go func() {
+		if err := http.Serve(l, server); err != nil {
+			log.WithFields(logrus.Fields{
+				"address": option.WebSocketAddress,
+				"err":     err,
+			}).Fatalln("websocket listen failed")
+		}
+	}()
+
+	log.WithFields(logrus.Fields{
+		"address": option.WebSocketAddress,
+	}).Infoln("websocket listen started")
+}
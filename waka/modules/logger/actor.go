@@ -4,15 +4,22 @@ import (
 	"bytes"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/sirupsen/logrus"
 )
 
 type actorT struct {
 	option Option
+	hook   *LogHook
 
 	name string
 	pid  *actor.PID
 
-	w *bytes.Buffer
+	formatter logrus.JSONFormatter
+	console   logrus.TextFormatter
+	batch     []*logrus.Entry
+	w         *bytes.Buffer
+	cw        *bytes.Buffer
+	dropped   uint64
 }
 
 func (my *actorT) Receive(context actor.Context) {
@@ -27,19 +34,31 @@ func (my *actorT) Receive(context actor.Context) {
 	}
 }
 
-// 会话配置
+// 日志配置
 type Option struct {
 	// 日志文件名前缀
 	Prefix string
+
+	// 环形缓冲容量, 写满后丢弃新日志
+	Capacity int
+	// 缓冲中积压多少条日志时提前写入
+	BatchEntries int
+	// 单次写入文件的字节数
+	BatchBytes int
+
+	// 同时以文本格式写到标准错误
+	Console bool
 }
 
-// 创建会话
-func Spawn(option Option) *actor.PID {
+func spawn(option Option, hook *LogHook) *actor.PID {
 	return actor.Spawn(
 		actor.FromInstance(
 			&actorT{
 				option: option,
-				w:      bytes.NewBuffer(make([]byte, 0, 1024*4*64)),
+				hook:   hook,
+				batch:  make([]*logrus.Entry, 0, option.BatchEntries),
+				w:      bytes.NewBuffer(make([]byte, 0, option.BatchBytes)),
+				cw:     bytes.NewBuffer(nil),
 			},
 		),
 	)
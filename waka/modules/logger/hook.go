@@ -1,17 +1,81 @@
 package logger
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/AsynkronIT/protoactor-go/actor"
-	"github.com/liuhan907/waka/waka/modules/logger/logger_message"
 	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka/modules/logger/logger_message"
+)
+
+const (
+	// 致命日志等待写入的最长时间
+	syncTimeout = time.Second
+)
+
+var (
+	entryPool = sync.Pool{
+		New: func() interface{} {
+			return &logrus.Entry{
+				Data: make(logrus.Fields, 8),
+			}
+		},
+	}
 )
 
 type LogHook struct {
 	Target *actor.PID
 
+	ring     *ring
+	batch    int
+	flushing int32
+
 	logrus.Hook
 }
 
+// 调用者一侧的格式化器, 什么也不输出
+type discardFormatter struct{}
+
+func (discardFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// 安装到标准 logger, 调用者不再格式化与输出, 所有日志都经过钩子由日志写入者完成
+func Install(option Option) *LogHook {
+	hook := NewLogHook(option)
+	logrus.SetFormatter(discardFormatter{})
+	logrus.SetOutput(ioutil.Discard)
+	logrus.AddHook(hook)
+	return hook
+}
+
+// 创建日志钩子并启动日志写入者
+func NewLogHook(option Option) *LogHook {
+	if option.Capacity <= 0 {
+		option.Capacity = 1024 * 64
+	}
+	if option.BatchEntries <= 0 {
+		option.BatchEntries = 1024
+	}
+	if option.BatchBytes <= 0 {
+		option.BatchBytes = 1024 * 4 * 64
+	}
+
+	hook := &LogHook{
+		ring:  newRing(option.Capacity),
+		batch: option.BatchEntries,
+	}
+	hook.Target = spawn(option, hook)
+	return hook
+}
+
 func (hook *LogHook) Levels() []logrus.Level {
 	return []logrus.Level{
 		logrus.DebugLevel,
@@ -23,14 +87,91 @@ func (hook *LogHook) Levels() []logrus.Level {
 	}
 }
 
+// 只复制日志内容, 格式化与写入都在日志 actor 中完成
+// 指针, 切片等值在调用者一侧转为字符串, 避免日志 actor 格式化时调用者仍在修改
+// Fatal 与 Panic 之后进程通常会退出, 等待写入完成后再返回
 func (hook *LogHook) Fire(entry *logrus.Entry) error {
-	formatter := logrus.JSONFormatter{}
-	d, err := formatter.Format(entry)
-	if err != nil {
-		return err
+	copied := entryPool.Get().(*logrus.Entry)
+	copied.Logger = entry.Logger
+	copied.Time = entry.Time
+	copied.Level = entry.Level
+	copied.Message = entry.Message
+	for k, v := range entry.Data {
+		copied.Data[k] = freezeValue(v)
+	}
+
+	size, ok := hook.ring.Push(copied)
+	if !ok {
+		releaseEntry(copied)
 	}
 
-	hook.Target.Tell(&logger_message.LogData{d})
+	if entry.Level <= logrus.FatalLevel {
+		hook.sync()
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	if size >= hook.batch && atomic.CompareAndSwapInt32(&hook.flushing, 0, 1) {
+		hook.Target.Tell(&logger_message.Flush{})
+	}
 
 	return nil
 }
+
+// 标量与时间原样保留, 其它值转为字符串, 能编码为 JSON 时使用 JSON 以保留内容
+func freezeValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, time.Time, time.Duration:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.String:
+		return v
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if rv.IsNil() {
+			return fmt.Sprint(v)
+		}
+	}
+	switch x := v.(type) {
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	}
+	if d, err := json.Marshal(v); err == nil {
+		return string(d)
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+// 请求立即写入并等待, 最多等待 syncTimeout
+func (hook *LogHook) sync() {
+	done := make(chan struct{})
+	hook.Target.Tell(&logger_message.Flush{Done: done})
+	select {
+	case <-done:
+	case <-time.After(syncTimeout):
+	}
+}
+
+// 因缓冲已满而丢弃的日志数量
+func (hook *LogHook) Dropped() uint64 {
+	return hook.ring.Dropped()
+}
+
+func releaseEntry(entry *logrus.Entry) {
+	for k := range entry.Data {
+		delete(entry.Data, k)
+	}
+	entry.Logger = nil
+	entry.Message = ""
+	entry.Buffer = nil
+	entryPool.Put(entry)
+}
@@ -0,0 +1,263 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 不启动日志 actor 的钩子, 积压不会触发提前写入
+func newTestHook(capacity int) *LogHook {
+	return &LogHook{
+		ring:  newRing(capacity),
+		batch: capacity + 1,
+	}
+}
+
+// 直接调用 flush 的日志写入者
+func newTestWriter(t *testing.T, hook *LogHook) *actorT {
+	return &actorT{
+		option: Option{Prefix: filepath.Join(t.TempDir(), "test"), BatchBytes: 1024},
+		hook:   hook,
+		name:   "test.log",
+		batch:  make([]*logrus.Entry, 0, 16),
+		w:      bytes.NewBuffer(nil),
+		cw:     bytes.NewBuffer(nil),
+	}
+}
+
+func newTestEntry(message string) *logrus.Entry {
+	entry := logrus.WithFields(logrus.Fields{
+		"player": 100001,
+		"type":   "NiuniuJoinRoom",
+	})
+	entry.Time = time.Now()
+	entry.Level = logrus.DebugLevel
+	entry.Message = message
+	return entry
+}
+
+func readLines(t *testing.T, writer *actorT) []string {
+	d, err := ioutil.ReadFile(writer.option.Prefix + "_" + writer.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(d)), "\n")
+}
+
+func TestRingDropsWhenFull(t *testing.T) {
+	r := newRing(4)
+	for i := 0; i < 6; i++ {
+		entry := &logrus.Entry{Message: string(rune('a' + i))}
+		size, ok := r.Push(entry)
+		if ok != (i < 4) {
+			t.Fatalf("push %d: ok = %v", i, ok)
+		}
+		if want := i + 1; i < 4 && size != want {
+			t.Fatalf("push %d: size = %d, want %d", i, size, want)
+		}
+	}
+	if r.Dropped() != 2 {
+		t.Fatalf("dropped = %d, want 2", r.Dropped())
+	}
+
+	batch := r.Drain(nil)
+	if len(batch) != 4 {
+		t.Fatalf("drained %d entries, want 4", len(batch))
+	}
+	for i, entry := range batch {
+		if want := string(rune('a' + i)); entry.Message != want {
+			t.Fatalf("entry %d = %q, want %q", i, entry.Message, want)
+		}
+	}
+
+	// 取出后可以继续放入, 丢弃数量只增不减
+	if _, ok := r.Push(&logrus.Entry{}); !ok {
+		t.Fatal("push after drain failed")
+	}
+	if r.Dropped() != 2 {
+		t.Fatalf("dropped = %d after drain, want 2", r.Dropped())
+	}
+}
+
+func TestLogHookCopiesEntry(t *testing.T) {
+	hook := newTestHook(4)
+	entry := newTestEntry("copied")
+	hook.Fire(entry)
+
+	// 调用者在 Fire 返回后可以继续修改自己的日志
+	entry.Message = "changed"
+	entry.Data["player"] = 0
+
+	batch := hook.ring.Drain(nil)
+	if len(batch) != 1 || batch[0].Message != "copied" || batch[0].Data["player"] != 100001 {
+		t.Fatalf("unexpected entry %+v", batch)
+	}
+}
+
+type testCost struct {
+	Player int32
+	Number int64
+}
+
+// 指针, 切片与映射在 Fire 中转为字符串, 调用者随后的修改不影响日志
+func TestLogHookFreezesValues(t *testing.T) {
+	hook := newTestHook(4)
+	costs := []*testCost{{100001, 30}}
+	counts := map[string]int{"round": 1}
+	var missing *testCost
+	entry := newTestEntry("frozen").WithFields(logrus.Fields{
+		"costs":   costs,
+		"counts":  counts,
+		"cost":    costs[0],
+		"missing": missing,
+		"err":     errors.New("money not enough"),
+		"at":      time.Unix(0, 0),
+		"ratio":   0.5,
+	})
+	entry.Level = logrus.DebugLevel
+	hook.Fire(entry)
+
+	costs[0].Number = 0
+	counts["round"] = 2
+
+	batch := hook.ring.Drain(nil)
+	if len(batch) != 1 {
+		t.Fatalf("unexpected entries %+v", batch)
+	}
+	want := logrus.Fields{
+		"player":  100001,
+		"type":    "NiuniuJoinRoom",
+		"costs":   `[{"Player":100001,"Number":30}]`,
+		"counts":  `{"round":1}`,
+		"cost":    `{"Player":100001,"Number":30}`,
+		"missing": "<nil>",
+		"err":     "money not enough",
+		"at":      time.Unix(0, 0),
+		"ratio":   0.5,
+	}
+	if !reflect.DeepEqual(batch[0].Data, want) {
+		t.Fatalf("data %#v, want %#v", batch[0].Data, want)
+	}
+}
+
+func TestLogHookDropsAndCounts(t *testing.T) {
+	hook := newTestHook(4)
+	writer := newTestWriter(t, hook)
+
+	for i := 0; i < 7; i++ {
+		hook.Fire(newTestEntry("first"))
+	}
+	if hook.Dropped() != 3 {
+		t.Fatalf("dropped = %d, want 3", hook.Dropped())
+	}
+
+	writer.flush()
+	lines := readLines(t, writer)
+	if len(lines) != 5 {
+		t.Fatalf("wrote %d lines, want 4 entries and a warning: %q", len(lines), lines)
+	}
+	for _, line := range lines[:4] {
+		if !strings.Contains(line, `"msg":"first"`) {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+	if !strings.Contains(lines[4], "3 entries dropped") {
+		t.Fatalf("unexpected warning %q", lines[4])
+	}
+
+	// 没有新日志也没有新的丢弃时不写入
+	writer.flush()
+	if lines := readLines(t, writer); len(lines) != 5 {
+		t.Fatalf("empty flush wrote %d lines", len(lines)-5)
+	}
+
+	// 警告只报告上次写入之后新丢弃的数量
+	for i := 0; i < 5; i++ {
+		hook.Fire(newTestEntry("second"))
+	}
+	writer.flush()
+	lines = readLines(t, writer)
+	if len(lines) != 10 || !strings.Contains(lines[9], "1 entries dropped") {
+		t.Fatalf("unexpected lines after second flush: %q", lines[5:])
+	}
+	if hook.Dropped() != 4 {
+		t.Fatalf("dropped = %d, want 4", hook.Dropped())
+	}
+}
+
+func TestLogHookSyncOnFatal(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "test")
+	hook := NewLogHook(Option{Prefix: prefix})
+
+	// 只有 Fatal 与 Panic 等待写入, Fire 返回时已经写入文件
+	entry := newTestEntry("open database failed")
+	entry.Level = logrus.FatalLevel
+	hook.Fire(entry)
+
+	matches, err := filepath.Glob(prefix + "_*.log")
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("log files %v, err %v", matches, err)
+	}
+	d, err := ioutil.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(d), `"msg":"open database failed"`) {
+		t.Fatalf("fatal entry not written: %q", d)
+	}
+}
+
+// 调用者记录一条日志的开销, ring 为缓冲未满时, full 为缓冲已满全部丢弃时
+func BenchmarkLogHookFire(b *testing.B) {
+	const capacity = 1024 * 64
+
+	b.Run("ring", func(b *testing.B) {
+		hook := newTestHook(capacity)
+		entry := newTestEntry("player transport")
+		var batch []*logrus.Entry
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			hook.Fire(entry)
+			if i%capacity == capacity-1 {
+				b.StopTimer()
+				batch = hook.ring.Drain(batch[:0])
+				for _, entry := range batch {
+					releaseEntry(entry)
+				}
+				b.StartTimer()
+			}
+		}
+		b.StopTimer()
+		if hook.Dropped() != 0 {
+			b.Fatalf("dropped %d entries", hook.Dropped())
+		}
+	})
+
+	b.Run("full", func(b *testing.B) {
+		hook := newTestHook(capacity)
+		entry := newTestEntry("player transport")
+		for i := 0; i < capacity; i++ {
+			hook.Fire(entry)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			hook.Fire(entry)
+		}
+		b.StopTimer()
+		if hook.Dropped() != uint64(b.N) {
+			b.Fatalf("dropped %d entries, want %d", hook.Dropped(), b.N)
+		}
+	})
+}
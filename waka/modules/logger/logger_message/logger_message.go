@@ -1,5 +1,6 @@
 package logger_message
 
-type LogData struct {
-	Payload []byte
+// 缓冲积压或进程即将退出, 请求立即写入, Done 不为 nil 时写入后关闭
+type Flush struct {
+	Done chan struct{}
 }
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
@@ -28,7 +29,16 @@ func (my *actorT) clock1() {
 		time.AfterFunc(time.Second, func() { my.pid.Tell(&clock1{}) })
 	}()
 
-	if my.w.Len() == 0 {
+	my.flush()
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (my *actorT) flush() {
+	my.batch = my.hook.ring.Drain(my.batch[:0])
+
+	dropped := my.hook.ring.Dropped()
+	if len(my.batch) == 0 && dropped == my.dropped {
 		return
 	}
 
@@ -37,19 +47,73 @@ func (my *actorT) clock1() {
 	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0755)
 	if err != nil {
 		log.Printf("open log file \"%s\" failed: %v\n", fileName, err)
+		my.releaseBatch()
 		return
 	}
 	defer fd.Close()
 
-	_, err = fd.Write(my.w.Bytes())
+	scratch := bytes.NewBuffer(make([]byte, 0, 1024))
+	for _, entry := range my.batch {
+		scratch.Reset()
+		entry.Buffer = scratch
+		d, err := my.formatter.Format(entry)
+		if err != nil {
+			log.Printf("format log failed: %v\n", err)
+			continue
+		}
+		my.w.Write(d)
+
+		if my.option.Console {
+			scratch.Reset()
+			if d, err := my.console.Format(entry); err == nil {
+				my.cw.Write(d)
+			}
+		}
+
+		if my.w.Len() >= my.option.BatchBytes {
+			my.write(fd)
+		}
+	}
+	my.releaseBatch()
+
+	if dropped != my.dropped {
+		fmt.Fprintf(my.w, "{\"level\":\"warning\",\"msg\":\"log buffer full, %d entries dropped\",\"time\":\"%s\"}\n",
+			dropped-my.dropped, time.Now().Format(time.RFC3339))
+		if my.option.Console {
+			fmt.Fprintf(my.cw, "log buffer full, %d entries dropped\n", dropped-my.dropped)
+		}
+		my.dropped = dropped
+	}
+
+	my.write(fd)
+}
+
+func (my *actorT) write(fd *os.File) {
+	if my.cw.Len() > 0 {
+		os.Stderr.Write(my.cw.Bytes())
+		my.cw.Reset()
+	}
+
+	if my.w.Len() == 0 {
+		return
+	}
+
+	_, err := fd.Write(my.w.Bytes())
 	if err != nil {
 		log.Printf("write log file failed: %v\n", err)
-		return
 	}
 
 	my.w.Reset()
 }
 
+func (my *actorT) releaseBatch() {
+	for i, entry := range my.batch {
+		releaseEntry(entry)
+		my.batch[i] = nil
+	}
+	my.batch = my.batch[:0]
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (my *actorT) startClock() {
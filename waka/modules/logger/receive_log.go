@@ -1,16 +1,17 @@
 package logger
 
 import (
-	"log"
+	"sync/atomic"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
+
 	"github.com/liuhan907/waka/waka/modules/logger/logger_message"
 )
 
 func (my *actorT) ReceiveLog(context actor.Context) bool {
-	switch evd := context.Message().(type) {
-	case *logger_message.LogData:
-		my.logData(evd)
+	switch ev := context.Message().(type) {
+	case *logger_message.Flush:
+		my.flushRequested(ev)
 	default:
 		return false
 	}
@@ -19,9 +20,11 @@ func (my *actorT) ReceiveLog(context actor.Context) bool {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-func (my *actorT) logData(ev *logger_message.LogData) {
-	_, err := my.w.Write(ev.Payload)
-	if err != nil {
-		log.Println("write log cache failed: ", err)
+func (my *actorT) flushRequested(ev *logger_message.Flush) {
+	my.flush()
+	if ev.Done != nil {
+		close(ev.Done)
+		return
 	}
+	atomic.StoreInt32(&my.hook.flushing, 0)
 }
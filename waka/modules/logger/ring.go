@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 有界环形缓冲, 写满后丢弃新日志并计数
+type ring struct {
+	lock    sync.Mutex
+	entries []*logrus.Entry
+	head    int
+	size    int
+
+	dropped uint64
+}
+
+func newRing(capacity int) *ring {
+	return &ring{
+		entries: make([]*logrus.Entry, capacity),
+	}
+}
+
+// 放入日志, 缓冲已满时返回 false
+func (r *ring) Push(entry *logrus.Entry) (int, bool) {
+	r.lock.Lock()
+	if r.size == len(r.entries) {
+		r.lock.Unlock()
+		atomic.AddUint64(&r.dropped, 1)
+		return r.size, false
+	}
+	r.entries[(r.head+r.size)%len(r.entries)] = entry
+	r.size++
+	size := r.size
+	r.lock.Unlock()
+	return size, true
+}
+
+// 取出全部日志, 追加到 batch 后返回
+func (r *ring) Drain(batch []*logrus.Entry) []*logrus.Entry {
+	r.lock.Lock()
+	for ; r.size > 0; r.size-- {
+		batch = append(batch, r.entries[r.head])
+		r.entries[r.head] = nil
+		r.head = (r.head + 1) % len(r.entries)
+	}
+	r.lock.Unlock()
+	return batch
+}
+
+// 累计丢弃数量
+func (r *ring) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
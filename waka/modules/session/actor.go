@@ -5,8 +5,11 @@ import (
 	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
-	"github.com/davyxu/cellnet"
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
+
+	"github.com/liuhan907/waka/waka/codec"
+	"github.com/liuhan907/waka/waka/modules/gateway"
 )
 
 var (
@@ -18,13 +21,16 @@ var (
 
 type actorT struct {
 	option Option
-	conn   cellnet.Session
+	conn   gateway.Conn
 
 	log    *logrus.Entry
 	pid    *actor.PID
 	target *actor.PID
 
 	heart time.Time
+
+	codec      codec.Codec
+	negotiable bool
 }
 
 func (my *actorT) Receive(context actor.Context) {
@@ -45,6 +51,9 @@ func (my *actorT) Receive(context actor.Context) {
 // 消息转发目标创建器
 type TargetCreator func(remote string, pid *actor.PID) *actor.PID
 
+// 消息拦截器, 返回错误时消息不再转发给接收者
+type Interceptor func(m proto.Message) error
+
 // 会话配置
 type Option struct {
 	// 消息接收者创建者
@@ -57,6 +66,12 @@ type Option struct {
 	// 启用心跳日志
 	EnableHeartLog bool
 
+	// 允许客户端协商的负载编码, 为空时只使用 protobuf
+	Codecs []string
+
+	// 客户端消息拦截器, 按顺序执行
+	Interceptors []Interceptor
+
 	// 心跳周期
 	HeartPeriod time.Duration
 	// 死亡时长
@@ -64,12 +79,14 @@ type Option struct {
 }
 
 // 创建会话
-func Spawn(option Option, conn cellnet.Session) *actor.PID {
+func Spawn(option Option, conn gateway.Conn) *actor.PID {
 	return actor.Spawn(
 		actor.FromInstance(
 			&actorT{
-				option: option,
-				conn:   conn,
+				option:     option,
+				conn:       conn,
+				codec:      codec.Protobuf,
+				negotiable: true,
 			},
 		),
 	)
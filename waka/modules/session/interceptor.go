@@ -0,0 +1,24 @@
+package session
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// 校验拦截器, 调用消息的 Validate 方法, 通常由 protoc-gen-waka 按 @validate 注解生成
+func ValidateInterceptor(m proto.Message) error {
+	if v, ok := m.(interface {
+		Validate() error
+	}); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func (my *actorT) intercept(m proto.Message) error {
+	for _, interceptor := range my.option.Interceptors {
+		if err := interceptor(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
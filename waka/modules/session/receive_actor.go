@@ -1,8 +1,6 @@
 package session
 
 import (
-	"net"
-
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/sirupsen/logrus"
 )
@@ -24,7 +22,7 @@ func (my *actorT) started(context actor.Context) {
 		"session_id": my.conn.ID(),
 	})
 	my.pid = context.Self()
-	my.target = my.option.TargetCreator(my.conn.RawConn().(*net.TCPConn).RemoteAddr().String(), my.pid)
+	my.target = my.option.TargetCreator(my.conn.RemoteAddr(), my.pid)
 
 	if my.option.EnableHeart {
 		my.startHeartbeat()
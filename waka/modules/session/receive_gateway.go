@@ -25,6 +25,8 @@ func (my *actorT) ReceiveGateway(context actor.Context) bool {
 		my.heartbeat()
 	case *gateway_message.Closed:
 		my.closed()
+	case *gateway_message.Negotiate:
+		my.negotiate(ev)
 	case *gateway_message.Transport:
 		my.transport(ev)
 	case *gateway_message.FutureRequest:
@@ -56,8 +58,56 @@ func (my *actorT) closed() {
 	my.target.Tell(&session_message.Closed{})
 }
 
+func (my *actorT) negotiate(ev *gateway_message.Negotiate) {
+	if !my.negotiable {
+		if my.option.EnableLog {
+			log.WithFields(logrus.Fields{
+				"pid":   my.pid.String(),
+				"codec": ev.Codec,
+			}).Warnln("negotiate codec after transport started")
+		}
+		my.conn.Send(&waka_proto.Negotiated{my.codec.Name()})
+		return
+	}
+	my.negotiable = false
+
+	allowed := false
+	for _, name := range my.option.Codecs {
+		if name == ev.Codec {
+			allowed = true
+			break
+		}
+	}
+
+	c, err := codec.CodecByName(ev.Codec)
+	if !allowed || err != nil {
+		if my.option.EnableLog {
+			log.WithFields(logrus.Fields{
+				"pid":   my.pid.String(),
+				"codec": ev.Codec,
+				"err":   err,
+			}).Warnln("negotiate codec not allowed")
+		}
+		my.conn.Send(&waka_proto.Negotiated{my.codec.Name()})
+		return
+	}
+
+	my.codec = c
+
+	if my.option.EnableLog {
+		log.WithFields(logrus.Fields{
+			"pid":   my.pid.String(),
+			"codec": c.Name(),
+		}).Debugln("codec negotiated")
+	}
+
+	my.conn.Send(&waka_proto.Negotiated{c.Name()})
+}
+
 func (my *actorT) transport(ev *gateway_message.Transport) {
-	m, name, err := codec.Decode(ev.Id, ev.Payload)
+	my.negotiable = false
+
+	m, name, err := codec.DecodeBy(my.codec, ev.Id, ev.Payload)
 	if err != nil {
 		if my.option.EnableLog {
 			log.WithFields(logrus.Fields{
@@ -69,6 +119,18 @@ func (my *actorT) transport(ev *gateway_message.Transport) {
 		return
 	}
 
+	if err := my.intercept(m); err != nil {
+		if my.option.EnableLog {
+			log.WithFields(logrus.Fields{
+				"id":      ev.Id,
+				"name":    name,
+				"payload": m.String(),
+				"err":     err,
+			}).Warnln("transport rejected")
+		}
+		return
+	}
+
 	if my.option.EnableLog {
 		log.WithFields(logrus.Fields{
 			"id":      ev.Id,
@@ -81,7 +143,9 @@ func (my *actorT) transport(ev *gateway_message.Transport) {
 }
 
 func (my *actorT) futureRequest(ev *gateway_message.FutureRequest) {
-	m, name, err := codec.Decode(ev.Id, ev.Payload)
+	my.negotiable = false
+
+	m, name, err := codec.DecodeBy(my.codec, ev.Id, ev.Payload)
 	if err != nil {
 		if my.option.EnableLog {
 			log.WithFields(logrus.Fields{
@@ -94,6 +158,24 @@ func (my *actorT) futureRequest(ev *gateway_message.FutureRequest) {
 		return
 	}
 
+	if err := my.intercept(m); err != nil {
+		if my.option.EnableLog {
+			log.WithFields(logrus.Fields{
+				"id":      ev.Id,
+				"name":    name,
+				"payload": m.String(),
+				"number":  ev.Number,
+				"err":     err,
+			}).Warnln("future request rejected")
+		}
+
+		my.conn.Send(&waka_proto.FutureResponse{
+			Status: fmt.Sprintf("failed: invalid request: %v", err),
+			Number: ev.Number,
+		})
+		return
+	}
+
 	if my.option.EnableLog {
 		log.WithFields(logrus.Fields{
 			"id":      ev.Id,
@@ -132,7 +214,7 @@ func (my *actorT) futureRequest(ev *gateway_message.FutureRequest) {
 			Number: ev.Number,
 		})
 	} else if m, ok := response.(proto.Message); ok {
-		d, id, name, err := codec.Encode(m)
+		d, id, name, err := codec.EncodeBy(my.codec, m)
 		if err != nil {
 			if my.option.EnableLog {
 				log.WithFields(logrus.Fields{
@@ -34,7 +34,7 @@ func (my *actorT) close() {
 }
 
 func (my *actorT) send(ev *session_message.Send) {
-	d, id, name, err := codec.Encode(ev.Payload)
+	d, id, name, err := codec.EncodeBy(my.codec, ev.Payload)
 	if err != nil {
 		if my.option.EnableLog {
 			log.WithFields(logrus.Fields{